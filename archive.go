@@ -0,0 +1,95 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"fmt"
+	"time"
+
+	r "github.com/go-redis/redis"
+)
+
+const archivedPrefixKey = "archived-sessions:"
+
+// ArchivedSession is a session handed to an Archiver
+type ArchivedSession struct {
+	Id string
+	// Values all fields of the session hash, internal ones included
+	Values map[string]string
+	// Expiring whether the session is about to expire rather than being deleted
+	Expiring bool
+}
+
+// Archiver persist sessions to cold storage before they are destroyed.
+//
+// Deleted sessions are archived right before deletion. Expiring sessions are archived by
+// the cleaning pass preceding their expiry, so a session refreshed afterwards gets
+// archived again when it finally expires.
+type Archiver interface {
+	Archive(session *ArchivedSession) error
+}
+
+// ArchivePurger is implemented by archivers able to erase the archived copies of a user, used by Purge
+type ArchivePurger interface {
+	PurgeUser(userId string) (int, error)
+}
+
+// getArchivedKey return the key claiming the archival of session id before its expiry,
+// so only one instance archives it
+func (p *provider) getArchivedKey(id string) string {
+	return archivedPrefixKey + p.keyPrefix + id
+}
+
+// archive hand the fields of session id to the archiver
+func (p *provider) archive(id string, expiring bool) {
+	if p.archiver == nil {
+		return
+	}
+	values, err := p.client.HGetAll(p.getRedisKey(id)).Result()
+	if err != nil {
+		p.report(err)
+		return
+	}
+	if len(values) == 0 {
+		return
+	}
+	if err = p.archiver.Archive(&ArchivedSession{Id: id, Values: values, Expiring: expiring}); err != nil {
+		p.report(err)
+	}
+}
+
+// archiveExpiring archive sessions expiring before the next cleaning pass
+func (p *provider) archiveExpiring(now time.Time) {
+	if p.archiver == nil {
+		return
+	}
+	expiring, err := p.client.ZRangeByScoreWithScores(p.getExpiryKey(), r.ZRangeBy{
+		Min: "(" + formatTime(now),
+		Max: formatTime(now.Add(p.cleanEvery() * 2)),
+	}).Result()
+	if err != nil {
+		p.report(err)
+		return
+	}
+	for _, z := range expiring {
+		id, _ := z.Member.(string)
+		claim := parseTime(fmt.Sprintf("%.0f", z.Score)).Sub(now) + p.cleanEvery()
+		claimed, err := p.client.SetNX(p.getArchivedKey(id), z.Score, claim).Result()
+		if err != nil {
+			p.report(err)
+			continue
+		}
+		if claimed {
+			p.archive(id, true)
+		}
+	}
+}
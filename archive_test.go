@@ -0,0 +1,74 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	s "github.com/go-the-way/anoweb/session"
+
+	"github.com/stretchr/testify/require"
+)
+
+type _archiver struct {
+	archived []*ArchivedSession
+}
+
+func (a *_archiver) Archive(session *ArchivedSession) error {
+	a.archived = append(a.archived, session)
+	return nil
+}
+
+func (a *_archiver) PurgeUser(userId string) (int, error) {
+	purged := 0
+	kept := a.archived[:0]
+	for _, session := range a.archived {
+		if session.Values[defaultFieldPrefix+userIdName] == userId {
+			purged++
+			continue
+		}
+		kept = append(kept, session)
+	}
+	a.archived = kept
+	return purged, nil
+}
+
+func TestProviderArchiver(t *testing.T) {
+	archiver := &_archiver{}
+	p := ProviderWithOptions(redisOptions, WithPrefixKey("_archive_:"), WithArchiver(archiver))
+	p.Clear()
+	archiver.archived = nil
+
+	deleted := p.New(&s.Config{Valid: time.Hour}, nil).(Session)
+	deleted.Set("cart", "apple")
+	require.Nil(t, deleted.BindUser("55"))
+	p.Del(deleted.Id())
+	require.Equal(t, 1, len(archiver.archived))
+	require.Equal(t, deleted.Id(), archiver.archived[0].Id)
+	require.Equal(t, "apple", archiver.archived[0].Values["cart"])
+	require.False(t, archiver.archived[0].Expiring)
+
+	expiring := p.New(&s.Config{Valid: time.Minute}, nil)
+	_ = p.New(&s.Config{Valid: time.Hour}, nil)
+	p.cleanSession(nil)
+	p.cleanSession(nil)
+	require.Equal(t, 2, len(archiver.archived))
+	require.Equal(t, expiring.Id(), archiver.archived[1].Id)
+	require.True(t, archiver.archived[1].Expiring)
+
+	report, err := p.Purge(context.Background(), "55")
+	require.Nil(t, err)
+	require.Equal(t, 1, report.ArchivedCopies)
+	require.Equal(t, 1, len(archiver.archived))
+}
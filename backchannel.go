@@ -0,0 +1,129 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// ErrInvalidLogoutToken returned when a logout token misses the claims OIDC back-channel logout requires
+var ErrInvalidLogoutToken = errors.New("rsn: invalid logout token")
+
+// ErrSidLogoutUnsupported returned for a logout token naming sessions by sid alone when no sid field is set
+var ErrSidLogoutUnsupported = errors.New("rsn: logout by sid without sid field")
+
+// LogoutTokenVerifier check the signature, issuer, audience and expiry of the logout token of an OIDC
+// back-channel logout request, as for an id token, and return its claims
+type LogoutTokenVerifier func(token string) (map[string]interface{}, error)
+
+const (
+	backChannelLogoutEvent = "http://schemas.openid.net/event/backchannel-logout"
+	logoutJtiPrefixKey     = "logout-jti-sessions:"
+)
+
+// logoutJtiWindow how long the ids of handled logout tokens are kept to refuse replays
+const logoutJtiWindow = 10 * time.Minute
+
+// BackChannelLogout return the http.Handler of OIDC back-channel logout requests, destroying the
+// sessions the identity provider logged out. The logout token is checked by verify, then its claims
+// are checked and its jti refused when replayed. With a sid claim and sidField, the field sessions
+// store the sid of the provider in, registered with WithIndex, the sessions of that sid are destroyed,
+// only those bound to sub when the token holds one; otherwise every session bound to sub is.
+// A token holding a sid alone is answered 501 without sidField, its jti left to be retried.
+func (p *provider) BackChannelLogout(verify LogoutTokenVerifier, sidField string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		claims, err := verify(r.PostFormValue("logout_token"))
+		if err == nil {
+			err = checkLogoutClaims(claims)
+		}
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = fmt.Fprintf(w, `{"error":"invalid_request","error_description":%q}`, err.Error())
+			return
+		}
+		jtiKey := logoutJtiPrefixKey + p.keyPrefix + claims["jti"].(string)
+		fresh, err := p.client.SetNX(jtiKey, 1, logoutJtiWindow).Result()
+		if err == nil && !fresh {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		sub, _ := claims["sub"].(string)
+		sid, _ := claims["sid"].(string)
+		if err == nil {
+			// a failed logout forgets the jti, so the identity provider may retry it
+			if err = p.logout(r, sub, sid, sidField); err != nil {
+				p.client.Del(jtiKey)
+			}
+		}
+		if err == ErrSidLogoutUnsupported {
+			w.WriteHeader(http.StatusNotImplemented)
+			return
+		}
+		if err != nil {
+			p.report(err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+// checkLogoutClaims return ErrInvalidLogoutToken unless claims are those of a logout token
+func checkLogoutClaims(claims map[string]interface{}) error {
+	events, _ := claims["events"].(map[string]interface{})
+	if _, have := events[backChannelLogoutEvent]; !have {
+		return ErrInvalidLogoutToken
+	}
+	if _, have := claims["nonce"]; have {
+		return ErrInvalidLogoutToken
+	}
+	sub, _ := claims["sub"].(string)
+	sid, _ := claims["sid"].(string)
+	if sub == "" && sid == "" {
+		return ErrInvalidLogoutToken
+	}
+	if jti, _ := claims["jti"].(string); jti == "" {
+		return ErrInvalidLogoutToken
+	}
+	return nil
+}
+
+// logout destroy the sessions of sid, of sub when given, or every session of sub,
+// or return ErrSidLogoutUnsupported for a sid alone without sidField
+func (p *provider) logout(r *http.Request, sub, sid, sidField string) error {
+	if sid == "" || sidField == "" {
+		if sub == "" {
+			return ErrSidLogoutUnsupported
+		}
+		return p.InvalidateUser(r.Context(), sub)
+	}
+	sessions, err := p.Find(r.Context(), sidField, sid)
+	if err != nil {
+		return err
+	}
+	for _, currentSession := range sessions {
+		if rs, ok := currentSession.(Session); ok && sub != "" && rs.UserId() != sub {
+			continue
+		}
+		p.destroy(currentSession.Id())
+	}
+	return nil
+}
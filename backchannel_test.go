@@ -0,0 +1,94 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	s "github.com/go-the-way/anoweb/session"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProviderBackChannelLogout(t *testing.T) {
+	p := ProviderWithOptions(redisOptions, WithPrefixKey("_backchannel_:"), WithIndex("idpSid"))
+	// the test verifier takes the claims as plain json
+	handler := p.BackChannelLogout(func(token string) (map[string]interface{}, error) {
+		claims := map[string]interface{}{}
+		return claims, json.Unmarshal([]byte(token), &claims)
+	}, "idpSid")
+	post := func(token string) int {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/logout", strings.NewReader(url.Values{"logout_token": {token}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		handler.ServeHTTP(w, req)
+		return w.Code
+	}
+	events := `"events":{"http://schemas.openid.net/event/backchannel-logout":{}}`
+
+	laptop := p.New(&s.Config{Valid: time.Minute}, nil).(Session)
+	require.NoError(t, laptop.BindUser("alice"))
+	laptop.Set("idpSid", "sid-1")
+	phone := p.New(&s.Config{Valid: time.Minute}, nil).(Session)
+	require.NoError(t, phone.BindUser("alice"))
+	phone.Set("idpSid", "sid-2")
+
+	require.Equal(t, http.StatusBadRequest, post(`{"sub":"alice","jti":"j0"}`))
+	require.Equal(t, http.StatusBadRequest, post(`{`+events+`,"sub":"alice","jti":"j0","nonce":"n"}`))
+	require.Equal(t, http.StatusOK, post(`{`+events+`,"sub":"alice","sid":"sid-1","jti":"j1"}`))
+	require.True(t, laptop.Invalidated())
+	require.False(t, phone.Invalidated())
+	require.Equal(t, http.StatusBadRequest, post(`{`+events+`,"sub":"alice","jti":"j1"}`))
+
+	require.Equal(t, http.StatusOK, post(`{`+events+`,"sub":"alice","jti":"j2"}`))
+	require.True(t, phone.Invalidated())
+	p.client.Del("logout-jti-sessions:_backchannel_:j1", "logout-jti-sessions:_backchannel_:j2")
+}
+
+func TestProviderBackChannelLogoutRetry(t *testing.T) {
+	p := ProviderWithOptions(redisOptions, WithPrefixKey("_backchannel_retry_:"), WithIndex("idpSid"))
+	verify := func(token string) (map[string]interface{}, error) {
+		claims := map[string]interface{}{}
+		return claims, json.Unmarshal([]byte(token), &claims)
+	}
+	post := func(handler http.Handler, token string) int {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/logout", strings.NewReader(url.Values{"logout_token": {token}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		handler.ServeHTTP(w, req)
+		return w.Code
+	}
+	token := `{"events":{"http://schemas.openid.net/event/backchannel-logout":{}},"sub":"alice","sid":"sid-1","jti":"j1"}`
+
+	laptop := p.New(&s.Config{Valid: time.Minute}, nil).(Session)
+	require.NoError(t, laptop.BindUser("alice"))
+	laptop.Set("idpSid", "sid-1")
+
+	// Find fails on a field that isn't indexed
+	require.Equal(t, http.StatusInternalServerError, post(p.BackChannelLogout(verify, "notIndexed"), token))
+	require.False(t, laptop.Invalidated())
+	require.Zero(t, p.client.Exists("logout-jti-sessions:_backchannel_retry_:j1").Val())
+	require.Equal(t, http.StatusOK, post(p.BackChannelLogout(verify, "idpSid"), token))
+	require.True(t, laptop.Invalidated())
+	require.Equal(t, http.StatusBadRequest, post(p.BackChannelLogout(verify, "idpSid"), token))
+
+	sidOnly := `{"events":{"http://schemas.openid.net/event/backchannel-logout":{}},"sid":"sid-1","jti":"j2"}`
+	require.Equal(t, http.StatusNotImplemented, post(p.BackChannelLogout(verify, ""), sidOnly))
+	require.Zero(t, p.client.Exists("logout-jti-sessions:_backchannel_retry_:j2").Val())
+	p.client.Del("logout-jti-sessions:_backchannel_retry_:j1")
+}
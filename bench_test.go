@@ -0,0 +1,82 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	s "github.com/go-the-way/anoweb/session"
+)
+
+func BenchmarkNewSID(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = newSID()
+	}
+}
+
+func BenchmarkGetRedisKey(b *testing.B) {
+	p := ProviderWithPrefixKey(redisOptions, "_bench_:")
+	id := newSID()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = p.getRedisKey(id)
+	}
+}
+
+func BenchmarkGetId(b *testing.B) {
+	p := ProviderWithPrefixKey(redisOptions, "_bench_:")
+	req, _ := http.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(&http.Cookie{Name: p.CookieName(), Value: newSID()})
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = p.GetId(req)
+	}
+}
+
+func BenchmarkProviderGet(b *testing.B) {
+	p := ProviderWithPrefixKey(redisOptions, "_bench_:")
+	currSession := p.New(&s.Config{Valid: time.Minute}, nil)
+	defer p.Del(currSession.Id())
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = p.Get(currSession.Id())
+	}
+}
+
+func BenchmarkSessionSet(b *testing.B) {
+	p := ProviderWithPrefixKey(redisOptions, "_bench_:")
+	currSession := p.New(&s.Config{Valid: time.Minute}, nil)
+	defer p.Del(currSession.Id())
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		currSession.Set("name", "value")
+	}
+}
+
+func BenchmarkProviderRefresh(b *testing.B) {
+	p := ProviderWithPrefixKey(redisOptions, "_bench_:")
+	config := &s.Config{Valid: time.Minute}
+	currSession := p.New(config, nil)
+	defer p.Del(currSession.Id())
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		p.Refresh(currSession, config, nil)
+	}
+}
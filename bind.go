@@ -0,0 +1,160 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"errors"
+	"net"
+	"net/http"
+
+	r "github.com/go-redis/redis"
+)
+
+// BindingMode decide what Validate does when a request no longer matches its session
+type BindingMode int
+
+const (
+	// FlagMismatch report the mismatch and keep the session
+	FlagMismatch BindingMode = iota
+	// RejectMismatch report the mismatch and destroy the session
+	RejectMismatch
+)
+
+var (
+	// ErrIPMismatch returned by Validate when the request ip is outside the network bound to session
+	ErrIPMismatch = errors.New("rsn: request ip does not match session")
+	// ErrFingerprintMismatch returned by Validate when the request fingerprint differs from the one bound to session
+	ErrFingerprintMismatch = errors.New("rsn: request fingerprint does not match session")
+)
+
+type ipBinding struct {
+	ipv4Bits int
+	ipv6Bits int
+	mode     BindingMode
+}
+
+// network return the CIDR of ip masked by the configured bits
+func (b *ipBinding) network(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ip
+	}
+	if v4 := parsed.To4(); v4 != nil {
+		return (&net.IPNet{IP: v4.Mask(net.CIDRMask(b.ipv4Bits, 32)), Mask: net.CIDRMask(b.ipv4Bits, 32)}).String()
+	}
+	return (&net.IPNet{IP: parsed.Mask(net.CIDRMask(b.ipv6Bits, 128)), Mask: net.CIDRMask(b.ipv6Bits, 128)}).String()
+}
+
+type fingerprintBinding struct {
+	fn   func(r *http.Request) string
+	mode BindingMode
+}
+
+func (b *fingerprintBinding) fingerprint(r *http.Request) string {
+	if b.fn != nil {
+		return b.fn(r)
+	}
+	return tmd5(r.UserAgent())
+}
+
+// contains report whether ip belongs to the bound network
+func contains(network, ip string) bool {
+	_, ipNet, err := net.ParseCIDR(network)
+	if err != nil {
+		return network == ip
+	}
+	parsed := net.ParseIP(ip)
+	return parsed != nil && ipNet.Contains(parsed)
+}
+
+// Validate check r against the client bound to session.
+//
+// The network and fingerprint of the first validated request are bound when the
+// session was not created by NewWithRequest. On mismatch ErrIPMismatch or
+// ErrFingerprintMismatch is returned, and under RejectMismatch the session is
+// destroyed as well.
+func (s *session) Validate(req *http.Request) error {
+	if binding := s.provider.ipBinding; binding != nil {
+		ip := s.provider.clientIP(req)
+		matched, err := s.checkBound(s.provider.field(boundNetworkName), binding.network(ip), func(bound string) bool {
+			return contains(bound, ip)
+		})
+		if err != nil {
+			return err
+		}
+		if !matched {
+			s.mismatch(binding.mode)
+			return ErrIPMismatch
+		}
+	}
+	if binding := s.provider.fingerprintBinding; binding != nil {
+		fingerprint := binding.fingerprint(req)
+		matched, err := s.checkBound(s.provider.field(fingerprintName), fingerprint, func(bound string) bool {
+			return bound == fingerprint
+		})
+		if err != nil {
+			return err
+		}
+		if !matched {
+			if l := s.provider.listener; l != nil && l.FingerprintMismatch != nil {
+				l.FingerprintMismatch(s, req)
+			}
+			s.mismatch(binding.mode)
+			return ErrFingerprintMismatch
+		}
+	}
+	return nil
+}
+
+// bindScript return the value bound under ARGV[1] in the session hash KEYS[1], binding ARGV[2]
+// if none is bound yet, or nil when the session is gone, so a dead session isn't recreated
+var bindScript = newScript(`
+local bound = redis.call('HGET', KEYS[1], ARGV[1])
+if bound then
+	return bound
+end
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return false
+end
+redis.call('HSET', KEYS[1], ARGV[1], ARGV[2])
+return ARGV[2]
+`)
+
+// checkBound report whether the value bound under name matches, binding value if none is bound yet.
+// ErrSessionNotFound is returned when the session is gone.
+func (s *session) checkBound(name, value string, matches func(bound string) bool) (bool, error) {
+	bound, err := bindScript.Run(s.client, []string{s.key}, name, value).String()
+	if err == r.Nil {
+		return false, ErrSessionNotFound
+	}
+	if err != nil {
+		return false, wrapErr(err)
+	}
+	return matches(bound), nil
+}
+
+// mismatchMode return the mode of the binding whose mismatch err reports, false for the other errors
+func (p *provider) mismatchMode(err error) (BindingMode, bool) {
+	switch {
+	case err == ErrIPMismatch && p.ipBinding != nil:
+		return p.ipBinding.mode, true
+	case err == ErrFingerprintMismatch && p.fingerprintBinding != nil:
+		return p.fingerprintBinding.mode, true
+	}
+	return FlagMismatch, false
+}
+
+func (s *session) mismatch(mode BindingMode) {
+	if mode == RejectMismatch {
+		s.provider.destroy(s.id)
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	s "github.com/go-the-way/anoweb/session"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newRequestFrom(remoteAddr string) *http.Request {
+	req, _ := http.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = remoteAddr
+	return req
+}
+
+func TestSessionValidateIP(t *testing.T) {
+	config := &s.Config{Valid: time.Minute}
+	{
+		p := ProviderWithOptions(redisOptions, WithPrefixKey("_bind_:"), WithIPBinding(24, 64, FlagMismatch))
+		currSession := p.NewWithRequest(newRequestFrom("10.0.0.1:5000"), config, nil).(Session)
+		require.Nil(t, currSession.Validate(newRequestFrom("10.0.0.200:5001")))
+		require.Equal(t, ErrIPMismatch, currSession.Validate(newRequestFrom("10.0.1.1:5000")))
+		require.True(t, p.Exists(currSession.Id()))
+
+		lazy := p.New(config, nil).(Session)
+		require.Nil(t, lazy.Validate(newRequestFrom("[2001:db8::1]:5000")))
+		require.Nil(t, lazy.Validate(newRequestFrom("[2001:db8::2]:5000")))
+		require.Equal(t, ErrIPMismatch, lazy.Validate(newRequestFrom("[2001:db9::1]:5000")))
+	}
+	{
+		p := ProviderWithOptions(redisOptions, WithPrefixKey("_bind_:"), WithIPBinding(32, 128, RejectMismatch),
+			WithClientIP(func(r *http.Request) string { return r.Header.Get("X-Real-IP") }))
+		req := newRequestFrom("127.0.0.1:5000")
+		req.Header.Set("X-Real-IP", "10.0.0.1")
+		currSession := p.NewWithRequest(req, config, nil).(Session)
+		require.Nil(t, currSession.Validate(req))
+		require.Equal(t, ErrIPMismatch, currSession.Validate(newRequestFrom("10.0.0.1:5000")))
+		require.False(t, p.Exists(currSession.Id()))
+		require.True(t, currSession.Invalidated())
+	}
+	{
+		p := ProviderWithPrefixKey(redisOptions, "_bind_:")
+		currSession := p.New(config, nil).(Session)
+		require.Nil(t, currSession.Validate(newRequestFrom("10.0.0.1:5000")))
+		p.Del(currSession.Id())
+	}
+}
+
+func TestSessionValidateFingerprint(t *testing.T) {
+	config := &s.Config{Valid: time.Minute}
+	mismatchCh := make(chan string, 1)
+	p := ProviderWithOptions(redisOptions, WithPrefixKey("_bind_:"), WithFingerprint(nil, FlagMismatch),
+		WithListener(&Listener{FingerprintMismatch: func(session s.Session, r *http.Request) {
+			mismatchCh <- r.UserAgent()
+		}}))
+	req := newRequestFrom("10.0.0.1:5000")
+	req.Header.Set("User-Agent", "agent-a")
+	currSession := p.NewWithRequest(req, config, nil).(Session)
+	require.Nil(t, currSession.Validate(req))
+
+	other := newRequestFrom("10.0.0.1:5000")
+	other.Header.Set("User-Agent", "agent-b")
+	require.Equal(t, ErrFingerprintMismatch, currSession.Validate(other))
+	require.Equal(t, "agent-b", <-mismatchCh)
+	require.True(t, p.Exists(currSession.Id()))
+	p.Del(currSession.Id())
+}
+
+func TestSessionValidateExpired(t *testing.T) {
+	p := ProviderWithOptions(redisOptions, WithPrefixKey("_bind_:"), WithIPBinding(24, 64, FlagMismatch), WithFingerprint(nil, FlagMismatch))
+	currSession := p.New(&s.Config{Valid: time.Minute}, nil).(*session)
+	require.NoError(t, p.client.Del(currSession.key).Err())
+	require.Equal(t, ErrSessionNotFound, currSession.Validate(newRequestFrom("10.0.0.1:5000")))
+	require.Zero(t, p.client.Exists(currSession.key).Val())
+	p.Del(currSession.Id())
+}
@@ -0,0 +1,292 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	r "github.com/go-redis/redis"
+
+	s "github.com/go-the-way/anoweb/session"
+)
+
+// BlobCodec encode the values of a session into the blob stored under its key and decode them back,
+// so values can be compressed or encrypted as a whole
+type BlobCodec interface {
+	// Encode return the blob of values
+	Encode(values map[string]interface{}) ([]byte, error)
+	// Decode return the values of blob
+	Decode(blob []byte) (map[string]interface{}, error)
+}
+
+// JSONCodec is the BlobCodec storing values as a json object
+type JSONCodec struct{}
+
+// Encode return values as a json object
+func (JSONCodec) Encode(values map[string]interface{}) ([]byte, error) {
+	return json.Marshal(values)
+}
+
+// Decode return the values of json object blob
+func (JSONCodec) Decode(blob []byte) (map[string]interface{}, error) {
+	values := map[string]interface{}{}
+	if err := json.Unmarshal(blob, &values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// blobSetScript replace the blob of a live session keeping its ttl, returning 0 when the session is gone
+var blobSetScript = newScript(`
+local ttl = redis.call('PTTL', KEYS[1])
+if ttl == -2 then
+	return 0
+end
+redis.call('SET', KEYS[1], ARGV[1])
+if ttl > 0 then
+	redis.call('PEXPIRE', KEYS[1], ttl)
+end
+return 1
+`)
+
+type blobProvider struct {
+	p     *provider
+	codec BlobCodec
+}
+
+var _ s.Provider = (*blobProvider)(nil)
+
+// BlobProvider return new provider storing each session as one blob encoded by codec, JSONCodec when nil,
+// under its key with a ttl, so reading a session takes one GET. Each write rewrites the whole blob,
+// the last concurrent writer winning, which suits read-mostly sessions. opts configure the key prefix,
+// clock, entropy and hooks; the key prefix should differ from the one of hash sessions.
+func BlobProvider(options *r.Options, codec BlobCodec, opts ...Option) *blobProvider {
+	if codec == nil {
+		codec = JSONCodec{}
+	}
+	return &blobProvider{p: newProvider(options, opts...), codec: codec}
+}
+
+// CookieName return session cookie name
+func (bp *blobProvider) CookieName() string {
+	return bp.p.CookieName()
+}
+
+// GetId get session id, empty when the cookie is missing or malformed
+func (bp *blobProvider) GetId(r *http.Request) string {
+	return bp.p.GetId(r)
+}
+
+// Exists session
+func (bp *blobProvider) Exists(id string) bool {
+	existsCmd := bp.p.client.Exists(bp.p.getRedisKey(id))
+	if existsCmd.Err() != nil {
+		_, _ = fmt.Fprintln(os.Stderr, existsCmd.Err())
+		return false
+	}
+	return existsCmd.Val() > 0
+}
+
+// Get load session id, nil when it doesn't exist
+func (bp *blobProvider) Get(id string) s.Session {
+	blob, err := bp.p.client.Get(bp.p.getRedisKey(id)).Bytes()
+	if err == r.Nil {
+		return nil
+	}
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		return nil
+	}
+	values, err := bp.codec.Decode(blob)
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		return nil
+	}
+	return &blobSession{provider: bp, id: id, key: bp.p.getRedisKey(id), values: values}
+}
+
+// GetAll load every session
+func (bp *blobProvider) GetAll() map[string]s.Session {
+	sessions := map[string]s.Session{}
+	iter := bp.p.scans().Scan(0, bp.p.getKeyPattern("*"), 100).Iterator()
+	for iter.Next() {
+		if id, own := bp.p.ownId(iter.Val()); own {
+			if currentSession := bp.Get(id); currentSession != nil {
+				sessions[id] = currentSession
+			}
+		}
+	}
+	if err := iter.Err(); err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+	}
+	return sessions
+}
+
+// Del session
+func (bp *blobProvider) Del(id string) {
+	if err := bp.p.client.Del(bp.p.getRedisKey(id)).Err(); err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+	}
+}
+
+// Clear sessions
+func (bp *blobProvider) Clear() {
+	for id := range bp.GetAll() {
+		bp.Del(id)
+	}
+}
+
+// New return new empty session
+func (bp *blobProvider) New(config *s.Config, listener *s.Listener) s.Session {
+	id := bp.p.newSID()
+	currentSession := &blobSession{provider: bp, id: id, key: bp.p.getRedisKey(id), values: map[string]interface{}{}}
+	blob, err := bp.codec.Encode(currentSession.values)
+	if err == nil {
+		err = bp.p.client.Set(currentSession.key, blob, bp.p.valid(config)).Err()
+	}
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		return nil
+	}
+	bp.p.dispatch(currentSession.Id(), func() {
+		if listener != nil && listener.Created != nil {
+			listener.Created(currentSession)
+		}
+	})
+	return currentSession
+}
+
+// Cookie return the session cookie to set on the response
+func (bp *blobProvider) Cookie(session s.Session, config *s.Config) *http.Cookie {
+	return bp.p.Cookie(session, config)
+}
+
+// Refresh session
+func (bp *blobProvider) Refresh(session s.Session, config *s.Config, listener *s.Listener) {
+	session.Renew(bp.p.valid(config))
+	bp.p.dispatch(session.Id(), func() {
+		if listener != nil && listener.Refreshed != nil {
+			listener.Refreshed(session)
+		}
+	})
+}
+
+// Clean do nothing, redis expiring the sessions
+func (bp *blobProvider) Clean(*s.Config, *s.Listener) {}
+
+type blobSession struct {
+	provider    *blobProvider
+	id          string
+	key         string
+	mu          sync.Mutex
+	values      map[string]interface{}
+	invalidated bool
+}
+
+var _ s.Session = (*blobSession)(nil)
+
+// Id return session id
+func (bs *blobSession) Id() string {
+	return bs.id
+}
+
+// Renew session
+func (bs *blobSession) Renew(lifeTime time.Duration) {
+	if err := bs.provider.p.client.PExpire(bs.key, lifeTime).Err(); err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+	}
+}
+
+// Invalidate session
+func (bs *blobSession) Invalidate() {
+	bs.mu.Lock()
+	bs.invalidated = true
+	bs.mu.Unlock()
+	bs.provider.Del(bs.id)
+}
+
+// Invalidated session
+func (bs *blobSession) Invalidated() bool {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	return bs.invalidated
+}
+
+// Get session named val, as loaded with the session
+func (bs *blobSession) Get(name string) interface{} {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	return bs.values[name]
+}
+
+// GetAll session's values, as loaded with the session
+func (bs *blobSession) GetAll() map[string]interface{} {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	values := make(map[string]interface{}, len(bs.values))
+	for k, v := range bs.values {
+		values[k] = v
+	}
+	return values
+}
+
+// Set named val into session
+func (bs *blobSession) Set(name string, val interface{}) {
+	bs.update(func() { bs.values[name] = val })
+}
+
+// SetAll values into session
+func (bs *blobSession) SetAll(data map[string]interface{}, flush bool) {
+	bs.update(func() {
+		if flush {
+			bs.values = map[string]interface{}{}
+		}
+		for k, v := range data {
+			bs.values[k] = v
+		}
+	})
+}
+
+// Del named val from session
+func (bs *blobSession) Del(name string) {
+	bs.update(func() { delete(bs.values, name) })
+}
+
+// Clear session's values
+func (bs *blobSession) Clear() {
+	bs.update(func() { bs.values = map[string]interface{}{} })
+}
+
+// update apply fn to the values and store them back as the blob of session, keeping its ttl
+func (bs *blobSession) update(fn func()) {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	fn()
+	blob, err := bs.provider.codec.Encode(bs.values)
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	set, err := blobSetScript.Run(bs.provider.p.client, []string{bs.key}, blob).Int64()
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	if set == 0 {
+		bs.invalidated = true
+	}
+}
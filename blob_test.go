@@ -0,0 +1,81 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	s "github.com/go-the-way/anoweb/session"
+
+	"github.com/stretchr/testify/require"
+)
+
+type reversedCodec struct {
+	JSONCodec
+}
+
+func (c reversedCodec) Encode(values map[string]interface{}) ([]byte, error) {
+	b, err := c.JSONCodec.Encode(values)
+	return reverse(b), err
+}
+
+func (c reversedCodec) Decode(blob []byte) (map[string]interface{}, error) {
+	return c.JSONCodec.Decode(reverse(blob))
+}
+
+func reverse(b []byte) []byte {
+	reversed := make([]byte, len(b))
+	for i := range b {
+		reversed[len(b)-1-i] = b[i]
+	}
+	return reversed
+}
+
+func TestBlobProvider(t *testing.T) {
+	bp := BlobProvider(redisOptions, nil, WithPrefixKey("_blob_:"))
+	bp.Clear()
+	currSession := bp.New(&s.Config{Valid: time.Minute}, nil)
+	currSession.Set("apple", "100")
+	currSession.SetAll(map[string]interface{}{"banana": "200"}, false)
+	require.Equal(t, "string", bp.p.client.Type("_blob_:"+currSession.Id()).Val())
+	ttl := bp.p.client.PTTL("_blob_:" + currSession.Id()).Val()
+	require.True(t, ttl > 0 && ttl <= time.Minute)
+
+	require.True(t, bp.Exists(currSession.Id()))
+	loaded := bp.Get(currSession.Id())
+	require.Equal(t, map[string]interface{}{"apple": "100", "banana": "200"}, loaded.GetAll())
+	loaded.Del("apple")
+	require.Nil(t, bp.Get(currSession.Id()).Get("apple"))
+	require.Len(t, bp.GetAll(), 1)
+
+	bp.Refresh(loaded, &s.Config{Valid: time.Hour}, nil)
+	require.True(t, bp.p.client.PTTL("_blob_:"+currSession.Id()).Val() > time.Minute)
+	loaded.Invalidate()
+	require.False(t, bp.Exists(currSession.Id()))
+	require.Nil(t, bp.Get(currSession.Id()))
+	currSession.Set("apple", "100")
+	require.True(t, currSession.Invalidated())
+	require.False(t, bp.Exists(currSession.Id()))
+}
+
+func TestBlobProviderCodec(t *testing.T) {
+	bp := BlobProvider(redisOptions, reversedCodec{}, WithPrefixKey("_blob_codec_:"))
+	currSession := bp.New(&s.Config{Valid: time.Minute}, nil)
+	currSession.Set("apple", "100")
+	blob, err := bp.p.client.Get("_blob_codec_:" + currSession.Id()).Bytes()
+	require.NoError(t, err)
+	require.True(t, bytes.HasPrefix(blob, []byte("}")))
+	require.Equal(t, "100", bp.Get(currSession.Id()).Get("apple"))
+	bp.Del(currSession.Id())
+}
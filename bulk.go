@@ -0,0 +1,159 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"context"
+	"time"
+
+	r "github.com/go-redis/redis"
+
+	s "github.com/go-the-way/anoweb/session"
+)
+
+// DelMany delete sessions ids in two pipelined round trips and return how many existed in redis.
+// As with Delete, local sessions are invalidated, the listeners given to Clean fire and peer
+// instances are told, which makes it suited to mass logouts.
+func (p *provider) DelMany(ids ...string) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	for _, id := range ids {
+		p.archive(id, false)
+	}
+	fields := []string{p.field(userIdName)}
+	for field := range p.indexes {
+		fields = append(fields, field)
+	}
+	getCmds := make([]*r.SliceCmd, len(ids))
+	_, err := p.client.Pipelined(func(pipe r.Pipeliner) error {
+		for i, id := range ids {
+			getCmds[i] = pipe.HMGet(p.getRedisKey(id), fields...)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, wrapErr(err)
+	}
+	delCmds := make([]r.Cmder, len(ids))
+	window := int64(p.softDeleteWindow / time.Millisecond)
+	members := make([]interface{}, len(ids))
+	_, err = p.client.Pipelined(func(pipe r.Pipeliner) error {
+		for i, id := range ids {
+			for j, val := range getCmds[i].Val() {
+				value, ok := val.(string)
+				if !ok {
+					continue
+				}
+				if j == 0 {
+					pipe.SRem(p.getUserKey(value), id)
+				} else {
+					pipe.SRem(p.getIndexKey(fields[j], value), id)
+				}
+			}
+			if window <= 0 {
+				delCmds[i] = pipe.Del(p.getRedisKey(id))
+				pipe.Del(p.getDocumentKey(id))
+			} else {
+				delCmds[i] = buryScript.Eval(pipe, []string{p.getRedisKey(id), p.getTombstoneKey(id)}, window)
+			}
+			if p.evictFields {
+				pipe.Del(p.getFieldsKey(id))
+			}
+			members[i] = id
+		}
+		pipe.ZRem(p.getExpiryKey(), members...)
+		return nil
+	})
+	if err != nil {
+		return 0, wrapErr(err)
+	}
+	deleted := int64(0)
+	for _, delCmd := range delCmds {
+		n := int64(0)
+		switch cmd := delCmd.(type) {
+		case *r.IntCmd:
+			n = cmd.Val()
+		case *r.Cmd:
+			n, _ = cmd.Int64()
+		}
+		if n > 0 {
+			deleted++
+		}
+	}
+	p.mu.Lock()
+	sessions := make([]s.Session, 0, len(ids))
+	for _, id := range ids {
+		if currentSession, have := p.sessions[id]; have {
+			markInvalidated(currentSession)
+			delete(p.sessions, id)
+			sessions = append(sessions, currentSession)
+		}
+	}
+	listener := p.cleanListener
+	p.mu.Unlock()
+	for _, id := range ids {
+		p.revoked(id)
+		p.publishInvalidation(id)
+	}
+	for _, currentSession := range sessions {
+		p.emit(EventInvalidated, currentSession.Id())
+		p.emit(EventDestroyed, currentSession.Id())
+	}
+	if listener != nil {
+		for _, currentSession := range sessions {
+			currentSession := currentSession
+			p.dispatch(currentSession.Id(), func() {
+				if listener.Invalidated != nil {
+					listener.Invalidated(currentSession)
+				}
+				if listener.Destroyed != nil {
+					listener.Destroyed(currentSession)
+				}
+			})
+		}
+	}
+	return deleted, nil
+}
+
+// DelByPattern delete the sessions whose id matches the glob pattern, walking them with SCAN
+// and deleting each batch with DelMany, and return how many existed in redis.
+// It stops when ctx is done, returning the count deleted so far.
+func (p *provider) DelByPattern(ctx context.Context, pattern string) (int64, error) {
+	client := p.scans().WithContext(ctx)
+	deleted := int64(0)
+	cursor := uint64(0)
+	for {
+		if err := ctx.Err(); err != nil {
+			return deleted, wrapErr(err)
+		}
+		keys, next, err := client.Scan(cursor, p.getKeyPattern(pattern), p.scanCount()).Result()
+		if err != nil {
+			return deleted, wrapErr(err)
+		}
+		ids := make([]string, 0, len(keys))
+		for _, key := range keys {
+			if id, own := p.ownId(key); own {
+				ids = append(ids, id)
+			}
+		}
+		n, err := p.DelMany(ids...)
+		deleted += n
+		if err != nil {
+			return deleted, wrapErr(err)
+		}
+		if next == 0 {
+			return deleted, nil
+		}
+		cursor = next
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	r "github.com/go-redis/redis"
+
+	s "github.com/go-the-way/anoweb/session"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProviderDelMany(t *testing.T) {
+	p := ProviderWithOptions(redisOptions, WithPrefixKey("_delmany_:"), WithIndex("tenant"))
+	destroyed := make(chan string, 3)
+	p.cleanListener = &s.Listener{Destroyed: func(session s.Session) { destroyed <- session.Id() }}
+	config := &s.Config{Valid: time.Minute}
+	first := p.New(config, nil).(*session)
+	second := p.New(config, nil).(*session)
+	kept := p.New(config, nil)
+	require.NoError(t, first.SetValue("tenant", "acme"))
+	require.NoError(t, second.BindUser("u1"))
+
+	deleted, err := p.DelMany(first.Id(), second.Id(), "missing")
+	require.NoError(t, err)
+	require.Equal(t, int64(2), deleted)
+	require.True(t, first.Invalidated())
+	require.False(t, p.Exists(second.Id()))
+	require.True(t, p.Exists(kept.Id()))
+	require.Equal(t, int64(0), p.client.Exists(p.getRedisKey(first.Id()), p.getRedisKey(second.Id())).Val())
+	require.False(t, p.client.SIsMember(p.getIndexKey("tenant", "acme"), first.Id()).Val())
+	require.False(t, p.client.SIsMember(p.getUserKey("u1"), second.Id()).Val())
+	require.Equal(t, r.Nil, p.client.ZScore(p.getExpiryKey(), first.Id()).Err())
+	require.ElementsMatch(t, []string{first.Id(), second.Id()}, []string{<-destroyed, <-destroyed})
+}
+
+func TestProviderDelByPattern(t *testing.T) {
+	p := ProviderWithOptions(redisOptions, WithPrefixKey("_delpattern_:"))
+	p.Clear()
+	config := &s.Config{Valid: time.Minute}
+	for _, id := range []string{"a-1", "a-2", "b-1"} {
+		p.create(id, config, nil, nil)
+	}
+	deleted, err := p.DelByPattern(context.Background(), "a-*")
+	require.NoError(t, err)
+	require.Equal(t, int64(2), deleted)
+	require.False(t, p.Exists("a-1"))
+	require.False(t, p.Exists("a-2"))
+	require.True(t, p.Exists("b-1"))
+}
@@ -0,0 +1,86 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"container/list"
+	"time"
+
+	s "github.com/go-the-way/anoweb/session"
+)
+
+// localCache track the use of local sessions, so the least recently used ones can be dropped
+type localCache struct {
+	max   int
+	idle  time.Duration
+	order *list.List
+	elems map[string]*list.Element
+}
+
+type cacheEntry struct {
+	id     string
+	usedAt time.Time
+}
+
+func newLocalCache(max int, idle time.Duration) *localCache {
+	return &localCache{max: max, idle: idle, order: list.New(), elems: map[string]*list.Element{}}
+}
+
+// store add currentSession as the local session of id, dropping the least recently used
+// ones beyond the cap set by WithLocalCache. p.mu must be held.
+func (p *provider) store(id string, currentSession s.Session) {
+	p.sessions[id] = currentSession
+	p.cacheTouch(id)
+}
+
+// cacheTouch mark the local session of id used. p.mu must be held.
+func (p *provider) cacheTouch(id string) {
+	c := p.cache
+	if c == nil {
+		return
+	}
+	now := p.now()
+	if elem, have := c.elems[id]; have {
+		elem.Value.(*cacheEntry).usedAt = now
+		c.order.MoveToFront(elem)
+	} else {
+		c.elems[id] = c.order.PushFront(&cacheEntry{id: id, usedAt: now})
+	}
+	for c.max > 0 && c.order.Len() > c.max {
+		p.cacheEvict(c.order.Back())
+	}
+}
+
+// cacheExpireIdle drop the local sessions unused for longer than the idle time set by WithLocalCache
+func (p *provider) cacheExpireIdle() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	c := p.cache
+	if c == nil || c.idle <= 0 {
+		return
+	}
+	before := p.now().Add(-c.idle)
+	for elem := c.order.Back(); elem != nil && elem.Value.(*cacheEntry).usedAt.Before(before); elem = c.order.Back() {
+		p.cacheEvict(elem)
+	}
+}
+
+// cacheEvict forget the local session of elem. Invalidated sessions are left to the cleaning
+// pass, so the Destroyed listener still fires for them. p.mu must be held.
+func (p *provider) cacheEvict(elem *list.Element) {
+	id := elem.Value.(*cacheEntry).id
+	p.cache.order.Remove(elem)
+	delete(p.cache.elems, id)
+	if currentSession, have := p.sessions[id]; have && !currentSession.Invalidated() {
+		delete(p.sessions, id)
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"testing"
+	"time"
+
+	s "github.com/go-the-way/anoweb/session"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProviderLocalCacheMax(t *testing.T) {
+	p := ProviderWithOptions(redisOptions, WithPrefixKey("_cache_max_:"), WithLocalCache(2, 0))
+	config := &s.Config{Valid: time.Minute}
+	first := p.New(config, nil)
+	second := p.New(config, nil)
+	require.NotNil(t, p.Get(first.Id()))
+	third := p.New(config, nil)
+
+	all := p.GetAll()
+	require.Len(t, all, 2)
+	require.Contains(t, all, first.Id())
+	require.Contains(t, all, third.Id())
+	require.NotContains(t, all, second.Id())
+	require.NotNil(t, p.Get(second.Id()))
+	require.Len(t, p.GetAll(), 2)
+}
+
+func TestProviderLocalCacheIdle(t *testing.T) {
+	clock := &fixedClock{time.Now()}
+	p := ProviderWithOptions(redisOptions, WithPrefixKey("_cache_idle_:"), WithClock(clock), WithLocalCache(0, time.Minute))
+	config := &s.Config{Valid: time.Hour}
+	idle := p.New(config, nil)
+	used := p.New(config, nil)
+	invalidated := p.New(config, nil)
+	invalidated.Invalidate()
+
+	clock.now = clock.now.Add(time.Second * 50)
+	p.Get(used.Id())
+	clock.now = clock.now.Add(time.Second * 20)
+	p.cacheExpireIdle()
+
+	all := p.GetAll()
+	require.NotContains(t, all, idle.Id())
+	require.Contains(t, all, used.Id())
+	require.Contains(t, all, invalidated.Id())
+	require.True(t, p.Exists(idle.Id()))
+}
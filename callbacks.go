@@ -0,0 +1,139 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"errors"
+	"sync"
+)
+
+// OverflowPolicy decide what happens to a listener callback when the queue of WithCallbackPool is full
+type OverflowPolicy int
+
+const (
+	// OverflowBlock wait for room in the queue, slowing down the operation firing the callback
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDrop drop the callback, reporting ErrCallbackDropped
+	OverflowDrop
+	// OverflowSpawn run the callback on a goroutine of its own, as without pool
+	OverflowSpawn
+)
+
+// ErrCallbackDropped reported when a listener callback is dropped under OverflowDrop
+var ErrCallbackDropped = errors.New("rsn: listener callback dropped")
+
+// callbackPool run the listener callbacks on a fixed number of workers, the callbacks of a session
+// always going to the same worker so they run in order
+type callbackPool struct {
+	queues   []chan func()
+	overflow OverflowPolicy
+	report   func(err error)
+}
+
+func newCallbackPool(workers, queue int, overflow OverflowPolicy) *callbackPool {
+	if workers < 1 {
+		workers = 1
+	}
+	if queue < 0 {
+		queue = 0
+	}
+	cp := &callbackPool{queues: make([]chan func(), workers), overflow: overflow}
+	for i := range cp.queues {
+		cp.queues[i] = make(chan func(), queue)
+	}
+	return cp
+}
+
+// start the workers
+func (cp *callbackPool) start() {
+	for _, queue := range cp.queues {
+		go func(queue chan func()) {
+			for fn := range queue {
+				fn()
+			}
+		}(queue)
+	}
+}
+
+// dispatch queue fn, a callback about session id, applying the overflow policy when the queue is full
+func (cp *callbackPool) dispatch(id string, fn func()) {
+	queue := cp.queues[fnv32a(id)%uint32(len(cp.queues))]
+	select {
+	case queue <- fn:
+		return
+	default:
+	}
+	switch cp.overflow {
+	case OverflowDrop:
+		cp.report(ErrCallbackDropped)
+	case OverflowSpawn:
+		go fn()
+	default:
+		queue <- fn
+	}
+}
+
+// fnv32a return the 32-bit FNV-1a hash of text, as hash/fnv without allocating a hasher
+func fnv32a(text string) uint32 {
+	h := uint32(2166136261)
+	for i := 0; i < len(text); i++ {
+		h ^= uint32(text[i])
+		h *= 16777619
+	}
+	return h
+}
+
+// serialQueues run the callbacks of each session one after the other, on a goroutine per session
+// with callbacks pending
+type serialQueues struct {
+	mu      sync.Mutex
+	pending map[string][]func()
+}
+
+// dispatch run fn, a callback about session id, once the callbacks of id dispatched before have run
+func (sq *serialQueues) dispatch(id string, fn func()) {
+	sq.mu.Lock()
+	if fns, running := sq.pending[id]; running {
+		sq.pending[id] = append(fns, fn)
+		sq.mu.Unlock()
+		return
+	}
+	sq.pending[id] = nil
+	sq.mu.Unlock()
+	go sq.drain(id, fn)
+}
+
+// drain run fn, then the callbacks of id dispatched meanwhile
+func (sq *serialQueues) drain(id string, fn func()) {
+	for fn != nil {
+		fn()
+		sq.mu.Lock()
+		if fns := sq.pending[id]; len(fns) > 0 {
+			fn, sq.pending[id] = fns[0], fns[1:]
+		} else {
+			delete(sq.pending, id)
+			fn = nil
+		}
+		sq.mu.Unlock()
+	}
+}
+
+// dispatch run fn, a listener callback about session id, on the pool of WithCallbackPool, or on a goroutine
+// without. The callbacks of a session run in the order they are dispatched, except those overflowing under
+// OverflowSpawn. It must not be called with p.mu held, since callbacks may wait for it.
+func (p *provider) dispatch(id string, fn func()) {
+	if p.callbacks == nil {
+		p.serial.dispatch(id, fn)
+		return
+	}
+	p.callbacks.dispatch(id, fn)
+}
@@ -0,0 +1,110 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	s "github.com/go-the-way/anoweb/session"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCallbackPoolOverflow(t *testing.T) {
+	for _, overflow := range []OverflowPolicy{OverflowDrop, OverflowSpawn, OverflowBlock} {
+		var dropped, ran int32
+		cp := newCallbackPool(1, 1, overflow)
+		cp.report = func(err error) {
+			require.Equal(t, ErrCallbackDropped, err)
+			atomic.AddInt32(&dropped, 1)
+		}
+		cp.start()
+		release := make(chan struct{})
+		started := make(chan struct{})
+		cp.dispatch("id", func() {
+			close(started)
+			<-release
+			atomic.AddInt32(&ran, 1)
+		})
+		<-started
+		cp.dispatch("id", func() { atomic.AddInt32(&ran, 1) })
+		if overflow == OverflowBlock {
+			go cp.dispatch("id", func() { atomic.AddInt32(&ran, 1) })
+		} else {
+			cp.dispatch("id", func() { atomic.AddInt32(&ran, 1) })
+		}
+		switch overflow {
+		case OverflowDrop:
+			require.Equal(t, int32(1), atomic.LoadInt32(&dropped))
+			close(release)
+			require.Eventually(t, func() bool { return atomic.LoadInt32(&ran) == 2 }, time.Second, time.Millisecond)
+		case OverflowSpawn:
+			require.Eventually(t, func() bool { return atomic.LoadInt32(&ran) == 1 }, time.Second, time.Millisecond)
+			close(release)
+			require.Eventually(t, func() bool { return atomic.LoadInt32(&ran) == 3 }, time.Second, time.Millisecond)
+		default:
+			close(release)
+			require.Eventually(t, func() bool { return atomic.LoadInt32(&ran) == 3 }, time.Second, time.Millisecond)
+			require.Equal(t, int32(0), atomic.LoadInt32(&dropped))
+		}
+	}
+}
+
+func TestProviderCallbackPool(t *testing.T) {
+	p := ProviderWithOptions(redisOptions, WithPrefixKey("_callbacks_:"), WithCallbackPool(2, 16, OverflowBlock))
+	var destroyed int32
+	p.Clean(nil, &s.Listener{Destroyed: func(s.Session) { atomic.AddInt32(&destroyed, 1) }})
+	for i := 0; i < 10; i++ {
+		p.Del(p.New(&s.Config{Valid: time.Minute}, nil).Id())
+	}
+	require.Eventually(t, func() bool { return atomic.LoadInt32(&destroyed) == 10 }, time.Second, time.Millisecond*10)
+}
+
+func TestProviderDispatchOrder(t *testing.T) {
+	for _, p := range []*provider{
+		ProviderWithPrefixKey(redisOptions, "_dispatch_:"),
+		ProviderWithOptions(redisOptions, WithPrefixKey("_dispatch_:"), WithCallbackPool(4, 4, OverflowBlock)),
+	} {
+		var mu sync.Mutex
+		seen := map[string][]int{}
+		var wg sync.WaitGroup
+		for i := 0; i < 100; i++ {
+			id := fmt.Sprint(i % 5)
+			i := i
+			wg.Add(1)
+			p.dispatch(id, func() {
+				defer wg.Done()
+				if i%7 == 0 {
+					time.Sleep(time.Millisecond)
+				}
+				mu.Lock()
+				seen[id] = append(seen[id], i)
+				mu.Unlock()
+			})
+		}
+		wg.Wait()
+		for _, order := range seen {
+			require.True(t, sort.IntsAreSorted(order))
+			require.Len(t, order, 20)
+		}
+		require.Eventually(t, func() bool {
+			p.serial.mu.Lock()
+			defer p.serial.mu.Unlock()
+			return len(p.serial.pending) == 0
+		}, time.Second, time.Millisecond)
+	}
+}
@@ -0,0 +1,45 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// Clock tell the current time, so tests can move time forward
+type Clock interface {
+	Now() time.Time
+}
+
+// now return the current time by the clock set with WithClock
+func (p *provider) now() time.Time {
+	if p.clock != nil {
+		return p.clock.Now()
+	}
+	return time.Now()
+}
+
+// newSID return a new session id, drawn from the entropy source set with WithEntropy if any
+func (p *provider) newSID() string {
+	if p.entropy == nil {
+		return newSID()
+	}
+	b := make([]byte, 16)
+	if _, err := io.ReadFull(p.entropy, b); err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		return newSID()
+	}
+	return upperHex(b)
+}
@@ -0,0 +1,43 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	s "github.com/go-the-way/anoweb/session"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fixedClock struct {
+	now time.Time
+}
+
+func (c *fixedClock) Now() time.Time {
+	return c.now
+}
+
+func TestProviderClockAndEntropy(t *testing.T) {
+	clock := &fixedClock{time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)}
+	entropy := bytes.NewReader(bytes.Repeat([]byte{0xab}, 16))
+	p := ProviderWithOptions(redisOptions, WithPrefixKey("_clock_:"), WithClock(clock), WithEntropy(entropy))
+	currSession := p.New(&s.Config{Valid: time.Minute}, nil)
+	require.Equal(t, "ABABABABABABABABABABABABABABABAB", currSession.Id())
+	require.Equal(t, formatTime(clock.now), currSession.Get(p.field(createdAtName)))
+
+	clock.now = clock.now.Add(time.Minute * 2)
+	require.Equal(t, currSession.Id(), p.expiredIds(clock.now)[0])
+	p.Del(currSession.Id())
+}
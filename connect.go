@@ -0,0 +1,100 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/url"
+	"strings"
+
+	r "github.com/go-redis/redis"
+)
+
+// ConnectRedisCodec is the BlobCodec of connect-redis, the redis store of express-session:
+// a json object whose cookie member describes the session cookie, added when missing
+// so express-session can load sessions created in go
+type ConnectRedisCodec struct {
+	JSONCodec
+}
+
+// Encode return values as a json object holding a cookie member
+func (c ConnectRedisCodec) Encode(values map[string]interface{}) ([]byte, error) {
+	if _, have := values["cookie"]; !have {
+		withCookie := make(map[string]interface{}, len(values)+1)
+		for k, v := range values {
+			withCookie[k] = v
+		}
+		withCookie["cookie"] = map[string]interface{}{"originalMaxAge": nil, "httpOnly": true, "path": "/"}
+		values = withCookie
+	}
+	return c.JSONCodec.Encode(values)
+}
+
+// ConnectRedisProvider return new blob provider sharing sessions with a Node.js application using
+// express-session and connect-redis with their defaults: "sess:" key prefix, connect.sid cookie
+// signed with secret, and json values. opts may override the key prefix and cookie name.
+func ConnectRedisProvider(options *r.Options, secret string, opts ...Option) *blobProvider {
+	connectOpts := []Option{
+		WithPrefixKey("sess:"),
+		WithCookieName("connect.sid"),
+		WithIdValidator(connectId),
+		func(p *provider) {
+			p.cookieCodec = &cookieCodec{
+				encode: func(id string) string {
+					return url.PathEscape("s:" + id + "." + connectSignature(id, secret))
+				},
+				decode: func(value string) (string, bool) {
+					return unsignConnectId(value, secret)
+				},
+			}
+		},
+	}
+	return BlobProvider(options, ConnectRedisCodec{}, append(connectOpts, opts...)...)
+}
+
+// connectId report whether id is well-formed as generated by express-session or rsn
+func connectId(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, c := range id {
+		if (c < '0' || c > '9') && (c < 'A' || c > 'Z') && (c < 'a' || c > 'z') && c != '-' && c != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+// connectSignature return the signature express-session appends to id
+func connectSignature(id, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = mac.Write([]byte(id))
+	return base64.RawStdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// unsignConnectId return the id of a signed express-session cookie value and whether its signature is valid
+func unsignConnectId(value, secret string) (string, bool) {
+	if unescaped, err := url.PathUnescape(value); err == nil {
+		value = unescaped
+	}
+	if !strings.HasPrefix(value, "s:") {
+		return "", false
+	}
+	dot := strings.LastIndex(value, ".")
+	if dot < 2 {
+		return "", false
+	}
+	id := value[2:dot]
+	return id, hmac.Equal([]byte(value[dot+1:]), []byte(connectSignature(id, secret)))
+}
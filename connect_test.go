@@ -0,0 +1,51 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	s "github.com/go-the-way/anoweb/session"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnectRedisProvider(t *testing.T) {
+	bp := ConnectRedisProvider(redisOptions, "keyboard cat", WithPrefixKey("_sess_:"))
+	require.Equal(t, "connect.sid", bp.CookieName())
+
+	// as stored and signed by express-session with connect-redis
+	id := "WYkm3cqIgq0vgfV0ZkmdDVbHHQ2Fm4cz"
+	require.NoError(t, bp.p.client.Set("_sess_:"+id, `{"cookie":{"originalMaxAge":86400000,"httpOnly":true,"path":"/"},"user":"bob"}`, time.Minute).Err())
+	req, _ := http.NewRequest("", "", nil)
+	req.AddCookie(&http.Cookie{Name: "connect.sid", Value: "s%3A" + id + "." + connectSignature(id, "keyboard cat")})
+	require.Equal(t, id, bp.GetId(req))
+	require.Equal(t, "bob", bp.Get(id).Get("user"))
+
+	forged, _ := http.NewRequest("", "", nil)
+	forged.AddCookie(&http.Cookie{Name: "connect.sid", Value: "s%3A" + id + ".forged"})
+	require.Equal(t, "", bp.GetId(forged))
+	bp.Del(id)
+
+	currSession := bp.New(&s.Config{Valid: time.Minute}, nil)
+	currSession.Set("user", "alice")
+	blob := bp.p.client.Get("_sess_:" + currSession.Id()).Val()
+	require.True(t, strings.Contains(blob, `"cookie":{`) && strings.Contains(blob, `"user":"alice"`))
+	cookie := bp.Cookie(currSession, &s.Config{Valid: time.Minute})
+	req, _ = http.NewRequest("", "", nil)
+	req.AddCookie(cookie)
+	require.Equal(t, currSession.Id(), bp.GetId(req))
+	bp.Del(currSession.Id())
+}
@@ -0,0 +1,59 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"net/http"
+	"time"
+)
+
+// cookieNames return the names GetId reads the session id from, the cookie name first,
+// then the legacy names of WithLegacyCookieNames
+func (p *provider) cookieNames() []string {
+	return append([]string{p.CookieName()}, p.legacyCookieNames...)
+}
+
+// clearedCookie return a cookie deleting the cookie of name from the client
+func clearedCookie(name string) *http.Cookie {
+	return &http.Cookie{
+		Name:     name,
+		Value:    "",
+		Path:     "/",
+		Expires:  time.Unix(0, 0),
+		MaxAge:   -1,
+		HttpOnly: true,
+	}
+}
+
+// LegacyCookies return the cookies deleting the legacy cookies of WithLegacyCookieNames r still carries,
+// to set on the response along with the session cookie so the client moves to the new name.
+// Middleware, Login, Logout and the adapters of the other frameworks set them.
+func (p *provider) LegacyCookies(r *http.Request) []*http.Cookie {
+	cookies := make([]*http.Cookie, 0)
+	for _, name := range p.legacyCookieNames {
+		if _, err := r.Cookie(name); err == nil {
+			cookies = append(cookies, clearedCookie(name))
+		}
+	}
+	return cookies
+}
+
+// setLegacyCookies set on w the cookies deleting the legacy cookies of r, when provider is an rsn provider
+func setLegacyCookies(provider interface{}, w http.ResponseWriter, r *http.Request) {
+	if lp, ok := provider.(interface {
+		LegacyCookies(r *http.Request) []*http.Cookie
+	}); ok {
+		for _, cookie := range lp.LegacyCookies(r) {
+			http.SetCookie(w, cookie)
+		}
+	}
+}
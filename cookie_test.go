@@ -0,0 +1,52 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	s "github.com/go-the-way/anoweb/session"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProviderLegacyCookieNames(t *testing.T) {
+	p := ProviderWithOptions(redisOptions, WithPrefixKey("_legacy_cookie_:"), WithCookieName("SID"),
+		WithLegacyCookieNames("GOSESSID", "OLDSID"))
+	config := &s.Config{Valid: time.Minute}
+	currSession := p.New(config, nil)
+	defer p.Del(currSession.Id())
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(&http.Cookie{Name: "OLDSID", Value: currSession.Id()})
+	require.Equal(t, currSession.Id(), p.GetId(req))
+
+	rec := httptest.NewRecorder()
+	Middleware(p, config)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, currSession.Id(), sessionOf(r).Id())
+	})).ServeHTTP(rec, req)
+	cookies := rec.Result().Cookies()
+	require.Len(t, cookies, 2)
+	require.Equal(t, "SID", cookies[0].Name)
+	require.Equal(t, currSession.Id(), cookies[0].Value)
+	require.Equal(t, "OLDSID", cookies[1].Name)
+	require.Equal(t, -1, cookies[1].MaxAge)
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(&http.Cookie{Name: "SID", Value: currSession.Id()})
+	req.AddCookie(&http.Cookie{Name: "GOSESSID", Value: "garbage"})
+	require.Equal(t, currSession.Id(), p.GetId(req))
+	require.Len(t, p.LegacyCookies(req), 1)
+}
@@ -0,0 +1,116 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"encoding"
+	"strconv"
+)
+
+// encodeValue return val as redis stores it, false when it can't be stored
+func encodeValue(val interface{}) (string, bool) {
+	switch v := val.(type) {
+	case nil:
+		return "", true
+	case string:
+		return v, true
+	case []byte:
+		return string(v), true
+	case int:
+		return strconv.FormatInt(int64(v), 10), true
+	case int8:
+		return strconv.FormatInt(int64(v), 10), true
+	case int16:
+		return strconv.FormatInt(int64(v), 10), true
+	case int32:
+		return strconv.FormatInt(int64(v), 10), true
+	case int64:
+		return strconv.FormatInt(v, 10), true
+	case uint:
+		return strconv.FormatUint(uint64(v), 10), true
+	case uint8:
+		return strconv.FormatUint(uint64(v), 10), true
+	case uint16:
+		return strconv.FormatUint(uint64(v), 10), true
+	case uint32:
+		return strconv.FormatUint(uint64(v), 10), true
+	case uint64:
+		return strconv.FormatUint(v, 10), true
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 64), true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case bool:
+		if v {
+			return "1", true
+		}
+		return "0", true
+	case encoding.BinaryMarshaler:
+		b, err := v.MarshalBinary()
+		return string(b), err == nil
+	}
+	return "", false
+}
+
+// Diff return what to write for session to hold exactly the values of other: the fields
+// whose value differs or is missing, and the names of the fields other lacks. Internal
+// fields are left out of both.
+func (s *session) Diff(other map[string]interface{}) (map[string]interface{}, []string) {
+	current := s.GetAll()
+	changed := map[string]interface{}{}
+	for name, val := range other {
+		if s.provider.reserved(name) {
+			continue
+		}
+		stored, have := current[name]
+		encoded, ok := encodeValue(val)
+		if !have || !ok || stored != encoded {
+			changed[name] = val
+		}
+	}
+	removed := make([]string, 0)
+	for name := range current {
+		if _, have := other[name]; !have && !s.provider.reserved(name) {
+			removed = append(removed, name)
+		}
+	}
+	return changed, removed
+}
+
+// Merge make session hold exactly the values of data, writing only the fields whose value
+// changed and deleting those data lacks. It saves the writes of handlers saving a whole
+// session object on every request, the values read and written not being a transaction.
+func (s *session) Merge(data map[string]interface{}) error {
+	changed, removed := s.Diff(data)
+	if err := s.SetValues(changed, false); err != nil {
+		return err
+	}
+	if len(removed) == 0 {
+		return nil
+	}
+	plain := make([]string, 0, len(removed))
+	for _, name := range removed {
+		if s.provider.indexed(name) {
+			s.indexDel(name)
+		} else {
+			plain = append(plain, name)
+		}
+	}
+	if len(plain) > 0 {
+		if err := s.hdel(plain...); err != nil {
+			return err
+		}
+	}
+	s.wrote()
+	s.provider.valuesDel(s, removed...)
+	return nil
+}
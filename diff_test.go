@@ -0,0 +1,53 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"testing"
+	"time"
+
+	s "github.com/go-the-way/anoweb/session"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionDiff(t *testing.T) {
+	p := ProviderWithPrefixKey(redisOptions, "_diff_:")
+	currSession := p.New(&s.Config{Valid: time.Minute}, nil).(*session)
+	require.NoError(t, currSession.SetValues(map[string]interface{}{"count": 3, "admin": true, "name": "a", "gone": "x"}, false))
+
+	changed, removed := currSession.Diff(map[string]interface{}{"count": 3, "admin": true, "name": "b", "new": 1.5, p.field(sessionIdName): "other"})
+	require.Equal(t, map[string]interface{}{"name": "b", "new": 1.5}, changed)
+	require.Equal(t, []string{"gone"}, removed)
+}
+
+func TestSessionMerge(t *testing.T) {
+	events := make(chan string, 4)
+	p := ProviderWithOptions(redisOptions, WithPrefixKey("_merge_:"), WithIndex("tenant"), WithListener(&Listener{
+		Set: func(session s.Session, name string, val interface{}) { events <- "set " + name },
+		Del: func(session s.Session, name string) { events <- "del " + name },
+	}))
+	currSession := p.New(&s.Config{Valid: time.Minute}, nil).(*session)
+	require.NoError(t, currSession.SetValues(map[string]interface{}{"count": "3", "tenant": "acme"}, false))
+	<-events
+	<-events
+
+	require.NoError(t, currSession.Merge(map[string]interface{}{"count": "3", "name": "b"}))
+	require.ElementsMatch(t, []string{"set name", "del tenant"}, []string{<-events, <-events})
+	require.Len(t, events, 0)
+	values := currSession.GetAll()
+	require.Equal(t, "3", values["count"])
+	require.Equal(t, "b", values["name"])
+	require.NotContains(t, values, "tenant")
+	require.Equal(t, currSession.Id(), values[p.field(sessionIdName)])
+	require.False(t, p.client.SIsMember(p.getIndexKey("tenant", "acme"), currSession.Id()).Val())
+}
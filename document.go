@@ -0,0 +1,97 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"encoding/json"
+	"errors"
+
+	r "github.com/go-redis/redis"
+)
+
+// ErrPathNotFound returned when reading a path missing from a session document
+var ErrPathNotFound = errors.New("rsn: session document path not found")
+
+const documentPrefixKey = "doc-sessions:"
+
+// getDocumentKey return the key of the RedisJSON document of session id
+func (p *provider) getDocumentKey(id string) string {
+	return documentPrefixKey + p.keyPrefix + id
+}
+
+// documentSetScript set a path of the document of a live session, creating the document
+// as an empty object first, and give it the ttl of the session. 0 is returned when the session is gone.
+//
+// KEYS are the session hash and its document. ARGV holds the path and the json value.
+var documentSetScript = newScript(`
+local ttl = redis.call('PTTL', KEYS[1])
+if ttl == -2 then
+	return 0
+end
+if redis.call('EXISTS', KEYS[2]) == 0 and ARGV[1] ~= '$' and ARGV[1] ~= '.' then
+	redis.call('JSON.SET', KEYS[2], '$', '{}')
+end
+redis.call('JSON.SET', KEYS[2], ARGV[1], ARGV[2])
+if ttl > 0 then
+	redis.call('PEXPIRE', KEYS[2], ttl)
+end
+return 1
+`)
+
+// Document is the RedisJSON document of a session, holding nested values stored apart from its hash.
+// Paths follow the RedisJSON syntax, so updating one only touches that part of the document.
+// The document lives as long as the session, and needs the RedisJSON module on the server.
+type Document struct {
+	provider *provider
+	id       string
+	key      string
+}
+
+// Document return the RedisJSON document of session id
+func (p *provider) Document(id string) *Document {
+	return &Document{provider: p, id: id, key: p.getDocumentKey(id)}
+}
+
+// Set store val encoded as json at path, returning ErrSessionNotFound when the session is gone
+func (d *Document) Set(path string, val interface{}) error {
+	data, err := json.Marshal(val)
+	if err != nil {
+		return err
+	}
+	keys := []string{d.provider.getRedisKey(d.id), d.key}
+	set, err := documentSetScript.Run(d.provider.client, keys, path, string(data)).Int64()
+	if err != nil {
+		return wrapErr(err)
+	}
+	if set == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// Get decode the json value at path into dst, returning ErrPathNotFound when the document doesn't hold it
+func (d *Document) Get(path string, dst interface{}) error {
+	data, err := d.provider.client.Do("JSON.GET", d.key, path).String()
+	if err == r.Nil {
+		return ErrPathNotFound
+	}
+	if err != nil {
+		return wrapErr(err)
+	}
+	return json.Unmarshal([]byte(data), dst)
+}
+
+// Del delete the values at path, returning how many were deleted
+func (d *Document) Del(path string) (int64, error) {
+	deleted, err := d.provider.client.Do("JSON.DEL", d.key, path).Int64()
+	return deleted, wrapErr(err)
+}
@@ -0,0 +1,101 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	s "github.com/go-the-way/anoweb/session"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionDocument(t *testing.T) {
+	p := ProviderWithPrefixKey(redisOptions, "_document_:")
+	require.Equal(t, ErrSessionNotFound, p.Document("missing").Set(".cart", []string{}))
+
+	currSession := p.New(&s.Config{Valid: time.Minute}, nil)
+	doc := p.Document(currSession.Id())
+	err := doc.Set(".cart", map[string]interface{}{"items": []string{"apple"}})
+	if err != nil && strings.Contains(strings.ToLower(err.Error()), "unknown") {
+		p.Del(currSession.Id())
+		t.Skip("RedisJSON module not loaded")
+	}
+	require.NoError(t, err)
+	require.NoError(t, doc.Set(".cart.total", 100))
+	var items []string
+	require.NoError(t, doc.Get(".cart.items", &items))
+	require.Equal(t, []string{"apple"}, items)
+	ttl := p.client.PTTL(p.getDocumentKey(currSession.Id())).Val()
+	require.True(t, ttl > 0 && ttl <= time.Minute)
+
+	deleted, err := doc.Del(".cart.total")
+	require.NoError(t, err)
+	require.Equal(t, int64(1), deleted)
+	p.Del(currSession.Id())
+	require.Zero(t, p.client.Exists(p.getDocumentKey(currSession.Id())).Val())
+}
+
+func TestSessionDocumentKeyLifecycle(t *testing.T) {
+	p := ProviderWithPrefixKey(redisOptions, "_document_:")
+	config := &s.Config{Valid: time.Minute}
+	// the paths below handle the document as a key, so a plain value stands for it without RedisJSON
+	withDocument := func(currSession s.Session) string {
+		key := p.getDocumentKey(currSession.Id())
+		require.NoError(t, p.client.Set(key, "{}", time.Minute).Err())
+		return key
+	}
+
+	touched := p.New(config, nil).(*session)
+	doc := withDocument(touched)
+	require.NoError(t, p.client.PExpire(touched.key, time.Second*10).Err())
+	require.NoError(t, p.client.PExpire(doc, time.Second*10).Err())
+	require.NoError(t, touched.Touch())
+	require.True(t, p.client.PTTL(doc).Val() > time.Second*30)
+	p.Del(touched.Id())
+
+	regenerated := p.New(config, nil)
+	doc = withDocument(regenerated)
+	next, err := p.Regenerate(regenerated)
+	require.NoError(t, err)
+	require.Zero(t, p.client.Exists(doc).Val())
+	require.Equal(t, int64(1), p.client.Exists(p.getDocumentKey(next.Id())).Val())
+	p.Del(next.Id())
+
+	loggedIn := p.New(config, nil)
+	doc = withDocument(loggedIn)
+	req := httptest.NewRequest(http.MethodPost, "/login", nil)
+	req.AddCookie(p.Cookie(loggedIn, config))
+	next, err = p.Login(httptest.NewRecorder(), req, "u1", nil)
+	require.NoError(t, err)
+	require.Zero(t, p.client.Exists(doc).Val())
+	require.Equal(t, int64(1), p.client.Exists(p.getDocumentKey(next.Id())).Val())
+	p.Del(next.Id())
+
+	deleted := p.New(config, nil)
+	doc = withDocument(deleted)
+	_, err = p.DelMany(deleted.Id())
+	require.NoError(t, err)
+	require.Zero(t, p.client.Exists(doc).Val())
+
+	purged := p.New(config, nil).(*session)
+	require.NoError(t, purged.BindUser("u-purge-doc"))
+	doc = withDocument(purged)
+	_, err = p.Purge(context.Background(), "u-purge-doc")
+	require.NoError(t, err)
+	require.Zero(t, p.client.Exists(doc).Val())
+}
@@ -0,0 +1,102 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"errors"
+	"time"
+
+	r "github.com/go-redis/redis"
+)
+
+// ErrNotReplicated returned when a write was done on the primary but fewer replicas than asked
+// acknowledged it before the timeout, so it may be lost on failover
+var ErrNotReplicated = errors.New("rsn: write not acknowledged by enough replicas")
+
+type durability struct {
+	replicas int
+	timeout  time.Duration
+}
+
+// waitWrite run write, then WAIT for replicas to acknowledge it within timeout when replicas > 0.
+// WAIT only covers the writes of its own connection, so both go in one pipeline.
+func waitWrite(client *r.Client, replicas int, timeout time.Duration, write func(pipe r.Pipeliner)) error {
+	var acked *r.Cmd
+	_, err := client.Pipelined(func(pipe r.Pipeliner) error {
+		write(pipe)
+		if replicas > 0 {
+			acked = pipe.Do("wait", replicas, int64(timeout/time.Millisecond))
+		}
+		return nil
+	})
+	if err != nil {
+		return wrapErr(err)
+	}
+	if acked == nil {
+		return nil
+	}
+	if n, err := acked.Int64(); err != nil || n < int64(replicas) {
+		return ErrNotReplicated
+	}
+	return nil
+}
+
+// criticalWrite run write, a critical write such as a login or a role change, waiting for
+// the replicas of WithWaitReplicas
+func (p *provider) criticalWrite(client *r.Client, write func(pipe r.Pipeliner)) error {
+	if p.durability == nil {
+		return waitWrite(client, 0, 0, write)
+	}
+	return waitWrite(client, p.durability.replicas, p.durability.timeout, write)
+}
+
+// SetDurable set named val at once, then wait for replicas to acknowledge it within timeout,
+// returning ErrNotReplicated when too few did. The value is kept on the primary either way.
+// A replicas <= 0 doesn't wait, as SyncSet without WithWaitReplicas.
+func (s *session) SetDurable(name string, val interface{}, replicas int, timeout time.Duration) error {
+	if s.provider.reserved(name) {
+		return ErrReservedField
+	}
+	if s.invalidated {
+		return ErrSessionNotFound
+	}
+	p := s.provider
+	p.writeBehind.forget(s.key, name)
+	values := map[string]interface{}{name: val}
+	if replicas <= 0 {
+		if err := s.writeNow(values); err != nil {
+			return err
+		}
+		p.valuesSet(s, values)
+		return nil
+	}
+	s.wrote()
+	var written *r.Cmd
+	err := waitWrite(s.client, replicas, timeout, func(pipe r.Pipeliner) {
+		if p.checkedWrites() {
+			written = writeScript.Eval(pipe, []string{s.key, p.getFieldsKey(s.id)}, s.writeArgs(values)...)
+		} else {
+			pipe.HMSet(s.key, values)
+		}
+	})
+	if err != nil && err != ErrNotReplicated {
+		return err
+	}
+	if written != nil {
+		n, _ := written.Int64()
+		if refused := writeRefused(n); refused != nil {
+			return refused
+		}
+	}
+	p.valuesSet(s, values)
+	return err
+}
@@ -0,0 +1,49 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"testing"
+	"time"
+
+	s "github.com/go-the-way/anoweb/session"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionSetDurable(t *testing.T) {
+	p := ProviderWithPrefixKey(redisOptions, "_durable_:")
+	currSession := p.New(&s.Config{Valid: time.Minute}, nil).(*session)
+	require.NoError(t, currSession.SetDurable("apple", "100", 0, 0))
+	require.Equal(t, "100", currSession.Get("apple"))
+
+	// the test server has no replica to acknowledge the write, which is kept anyway
+	require.Equal(t, ErrNotReplicated, currSession.SetDurable("apple", "200", 1, time.Millisecond*10))
+	require.Equal(t, "200", currSession.Get("apple"))
+	require.Equal(t, ErrReservedField, currSession.SetDurable(p.field(userIdName), "u1", 0, 0))
+	p.Del(currSession.Id())
+}
+
+func TestSessionWaitReplicas(t *testing.T) {
+	p := ProviderWithOptions(redisOptions, WithPrefixKey("_durable_:"), WithWaitReplicas(1, time.Millisecond*10))
+	currSession := p.New(&s.Config{Valid: time.Minute}, nil).(*session)
+	require.Equal(t, ErrNotReplicated, currSession.BindUser("u1"))
+	require.Equal(t, "u1", currSession.UserId())
+	require.Equal(t, ErrNotReplicated, currSession.Authenticate())
+	require.NoError(t, currSession.RequireFresh())
+	require.Equal(t, ErrNotReplicated, currSession.SyncSet("apple", "100"))
+	require.Equal(t, "100", currSession.Get("apple"))
+
+	// plain writes don't wait
+	require.NoError(t, currSession.SetValue("pear", "200"))
+	p.Del(currSession.Id())
+}
@@ -0,0 +1,57 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"errors"
+	"io"
+	"net"
+
+	r "github.com/go-redis/redis"
+)
+
+// ErrRedisUnavailable matched by errors.Is for errors caused by redis being unreachable,
+// as opposed to a command being refused. The underlying error is kept and unwrapped by errors.As.
+var ErrRedisUnavailable = errors.New("rsn: redis unavailable")
+
+// redisError is an error of the connection to redis
+type redisError struct {
+	err error
+}
+
+func (e *redisError) Error() string {
+	return ErrRedisUnavailable.Error() + ": " + e.err.Error()
+}
+
+func (e *redisError) Unwrap() error {
+	return e.err
+}
+
+func (e *redisError) Is(target error) bool {
+	return target == ErrRedisUnavailable
+}
+
+// wrapErr return err marked as ErrRedisUnavailable when it comes from the connection to redis,
+// or err unchanged
+func wrapErr(err error) error {
+	if err == nil || err == r.Nil || errors.Is(err, ErrRedisUnavailable) {
+		return err
+	}
+	var netErr net.Error
+	switch {
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF), errors.As(err, &netErr):
+	case err.Error() == "redis: client is closed", err.Error() == "redis: connection pool timeout":
+	default:
+		return err
+	}
+	return &redisError{err: err}
+}
@@ -0,0 +1,62 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	rds "github.com/go-redis/redis"
+
+	s "github.com/go-the-way/anoweb/session"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrapErr(t *testing.T) {
+	require.NoError(t, wrapErr(nil))
+	require.Equal(t, rds.Nil, wrapErr(rds.Nil))
+	require.Equal(t, ErrSessionNotFound, wrapErr(ErrSessionNotFound))
+
+	err := wrapErr(io.EOF)
+	require.True(t, errors.Is(err, ErrRedisUnavailable))
+	require.True(t, errors.Is(err, io.EOF))
+	require.Equal(t, err, wrapErr(err))
+
+	var opErr *net.OpError
+	require.True(t, errors.As(wrapErr(&net.OpError{Op: "dial", Err: errors.New("refused")}), &opErr))
+}
+
+func TestProviderRedisUnavailable(t *testing.T) {
+	p := ProviderWithOptions(redisOptions, WithPrefixKey("_unavailable_:"), WithClient(rds.NewClient(&rds.Options{
+		Addr:        "127.0.0.1:1",
+		DialTimeout: time.Millisecond * 100,
+	})))
+	_, err := p.Count(context.Background())
+	require.True(t, errors.Is(err, ErrRedisUnavailable))
+	_, err = p.Delete("id")
+	require.True(t, errors.Is(err, ErrRedisUnavailable))
+}
+
+func TestProviderSessionExpired(t *testing.T) {
+	p := ProviderWithOptions(redisOptions, WithPrefixKey("_expired_:"), WithMaxLifetime(time.Hour))
+	currSession := p.New(&s.Config{Valid: time.Minute}, nil)
+	require.NoError(t, p.client.HSet(p.getRedisKey(currSession.Id()), p.field(deadlineName), formatTime(time.Now().Add(-time.Second))).Err())
+	_, err := p.renew(currSession.Id(), time.Minute, false)
+	require.Equal(t, ErrSessionExpired, err)
+	_, err = p.renew(currSession.Id(), time.Minute, false)
+	require.Equal(t, ErrSessionNotFound, err)
+}
@@ -0,0 +1,107 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// eventBuffer the capacity of the channel returned by Events
+const eventBuffer = 256
+
+// EventKind tell what an Event is about
+type EventKind int
+
+const (
+	// EventError a background operation such as cleaning or syncing failed
+	EventError EventKind = iota
+	// EventCreated a session was created
+	EventCreated
+	// EventInvalidated a session was deleted or expired
+	EventInvalidated
+	// EventDestroyed a local session was dropped after being invalidated
+	EventDestroyed
+	// EventShardDown a shard of a sharded provider failed its health check
+	EventShardDown
+	// EventShardUp a shard of a sharded provider passed its health check again
+	EventShardUp
+	// EventFailover the sessions moved to the standby endpoint of WithFailover, named by Shard
+	EventFailover
+)
+
+// Event delivered by Events
+type Event struct {
+	Kind EventKind
+	// SessionId of the session the event is about, empty if none
+	SessionId string
+	// Shard name of the shard or failover endpoint the event is about, empty if none
+	Shard string
+	// Err of EventError
+	Err  error
+	Time time.Time
+}
+
+// eventBus deliver events to the channel returned by Events, once asked for
+type eventBus struct {
+	mu sync.Mutex
+	ch chan Event
+}
+
+func (b *eventBus) channel() <-chan Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.ch == nil {
+		b.ch = make(chan Event, eventBuffer)
+	}
+	return b.ch
+}
+
+// emit deliver e without blocking, dropping it when nobody asked for events or the channel is full
+func (b *eventBus) emit(e Event) {
+	b.mu.Lock()
+	ch := b.ch
+	b.mu.Unlock()
+	if ch == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	select {
+	case ch <- e:
+	default:
+	}
+}
+
+// Events return the channel delivering background errors and session lifecycle events, for apps preferring
+// channels to listeners. Events are dropped rather than blocking the provider when the channel is full,
+// and only start being delivered once Events is first called. Tenant views share the channel of p.
+func (p *provider) Events() <-chan Event {
+	return p.events.channel()
+}
+
+// emit deliver an event of kind about session id, giving it to the publishers and the event stream
+func (p *provider) emit(kind EventKind, id string) {
+	e := Event{Kind: kind, SessionId: id, Time: p.now()}
+	p.events.emit(e)
+	p.publish(e)
+	p.appendEvent(e)
+}
+
+// report print err of a background operation and deliver it as EventError
+func (p *provider) report(err error) {
+	_, _ = fmt.Fprintln(os.Stderr, err)
+	p.events.emit(Event{Kind: EventError, Err: err, Time: p.now()})
+}
@@ -0,0 +1,68 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	rds "github.com/go-redis/redis"
+
+	s "github.com/go-the-way/anoweb/session"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProviderEvents(t *testing.T) {
+	p := ProviderWithPrefixKey(redisOptions, "_events_:")
+	events := p.Events()
+	currSession := p.New(&s.Config{Valid: time.Minute}, nil)
+	require.Equal(t, Event{Kind: EventCreated, SessionId: currSession.Id()}, withoutTime(<-events))
+
+	_, err := p.Delete(currSession.Id())
+	require.NoError(t, err)
+	require.Equal(t, Event{Kind: EventInvalidated, SessionId: currSession.Id()}, withoutTime(<-events))
+	require.Equal(t, Event{Kind: EventDestroyed, SessionId: currSession.Id()}, withoutTime(<-events))
+
+	failure := errors.New("clean failed")
+	p.report(failure)
+	require.Equal(t, Event{Kind: EventError, Err: failure}, withoutTime(<-events))
+}
+
+func TestProviderEventsDropWhenFull(t *testing.T) {
+	p := ProviderWithPrefixKey(redisOptions, "_events_full_:")
+	p.emit(EventCreated, "before")
+	events := p.Events()
+	for i := 0; i < eventBuffer+10; i++ {
+		p.emit(EventCreated, "id")
+	}
+	require.Len(t, events, eventBuffer)
+}
+
+func TestShardedEvents(t *testing.T) {
+	sp := Sharded([]*rds.Options{redisOptions})
+	events := sp.Events()
+	sh := sp.shards[0]
+	sh.setHealthy(true)
+	sh.setHealthy(false)
+	sh.setHealthy(false)
+	sh.setHealthy(true)
+	require.Equal(t, Event{Kind: EventShardDown, Shard: sh.name}, withoutTime(<-events))
+	require.Equal(t, Event{Kind: EventShardUp, Shard: sh.name}, withoutTime(<-events))
+	require.Len(t, events, 0)
+}
+
+func withoutTime(e Event) Event {
+	e.Time = time.Time{}
+	return e
+}
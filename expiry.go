@@ -0,0 +1,188 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	r "github.com/go-redis/redis"
+
+	s "github.com/go-the-way/anoweb/session"
+)
+
+const expiryPrefixKey = "expiry-sessions:"
+
+// expiryGrace how long expired entries stay in the expiry index, so the cleaning pass
+// of every instance gets to see them before they are dropped
+const expiryGrace = 5 * time.Minute
+
+// getExpiryKey return the key of the sorted set scoring session ids by expiry time in unix milliseconds
+func (p *provider) getExpiryKey() string {
+	return expiryPrefixKey + p.keyPrefix
+}
+
+// indexExpiry record that session id expires after ttl
+func (p *provider) indexExpiry(id string, ttl time.Duration) {
+	score := float64(p.now().Add(ttl).UnixNano() / int64(time.Millisecond))
+	if err := p.client.ZAdd(p.getExpiryKey(), r.Z{Score: score, Member: id}).Err(); err != nil {
+		p.report(err)
+	}
+}
+
+// indexExpiryNX record the current expiry of session id unless it is indexed already
+func (p *provider) indexExpiryNX(id string) {
+	ttl, err := p.client.PTTL(p.getRedisKey(id)).Result()
+	if err != nil {
+		p.report(err)
+		return
+	}
+	if ttl < 0 {
+		return
+	}
+	score := float64(p.now().Add(ttl).UnixNano() / int64(time.Millisecond))
+	if err = p.client.ZAddNX(p.getExpiryKey(), r.Z{Score: score, Member: id}).Err(); err != nil {
+		p.report(err)
+	}
+}
+
+func (p *provider) unindexExpiry(id string) {
+	if err := p.client.ZRem(p.getExpiryKey(), id).Err(); err != nil {
+		p.report(err)
+	}
+}
+
+// expiredIds return the ids of sessions due to expire by now, at most the PassLimit of WithMaintenance,
+// dropping entries older than expiryGrace
+func (p *provider) expiredIds(now time.Time) []string {
+	expiryKey := p.getExpiryKey()
+	limit := int64(p.maintenance.PassLimit)
+	ids, err := p.client.ZRangeByScore(expiryKey, r.ZRangeBy{Min: "-inf", Max: formatTime(now), Count: limit}).Result()
+	if err != nil {
+		p.report(err)
+		return nil
+	}
+	if limit > 0 && int64(len(ids)) == limit {
+		// more are due, keep the old entries for the next passes
+		return ids
+	}
+	if err = p.client.ZRemRangeByScore(expiryKey, "-inf", formatTime(now.Add(-expiryGrace))).Err(); err != nil {
+		p.report(err)
+	}
+	return ids
+}
+
+// expire invalidate the local session of id once its key is gone from redis
+func (p *provider) expire(id string, listener *s.Listener) {
+	p.mu.Lock()
+	currentSession, have := p.sessions[id]
+	p.mu.Unlock()
+	if !have || currentSession.Invalidated() {
+		return
+	}
+	existsCmd := p.client.Exists(p.getRedisKey(id))
+	if existsCmd.Err() != nil {
+		p.report(existsCmd.Err())
+		return
+	}
+	if existsCmd.Val() > 0 {
+		return
+	}
+	markInvalidated(currentSession)
+	p.revoked(id)
+	p.emit(EventInvalidated, id)
+	if rs, ok := currentSession.(*session); ok {
+		p.unbindExpired(rs)
+	}
+	p.dispatch(id, func() {
+		if listener != nil && listener.Invalidated != nil {
+			listener.Invalidated(currentSession)
+		}
+	})
+}
+
+// warnExpiring fire AboutToExpire for local sessions expiring within the warning threshold
+func (p *provider) warnExpiring(now time.Time) {
+	if p.expiryWarning <= 0 || p.listener == nil || p.listener.AboutToExpire == nil {
+		return
+	}
+	expiring, err := p.client.ZRangeByScoreWithScores(p.getExpiryKey(), r.ZRangeBy{
+		Min: "(" + formatTime(now),
+		Max: formatTime(now.Add(p.expiryWarning)),
+	}).Result()
+	if err != nil {
+		p.report(err)
+		return
+	}
+	for _, z := range expiring {
+		id, _ := z.Member.(string)
+		p.mu.Lock()
+		currentSession, have := p.sessions[id]
+		p.mu.Unlock()
+		if rs, ok := currentSession.(*session); have && ok && !rs.warned {
+			rs.warned = true
+			ttl := parseTime(fmt.Sprintf("%.0f", z.Score)).Sub(now)
+			p.dispatch(id, func() { p.listener.AboutToExpire(rs, ttl) })
+		}
+	}
+}
+
+// rearmExpiryWarning let AboutToExpire fire again for a session extended past the threshold
+func rearmExpiryWarning(currentSession s.Session) {
+	if rs, ok := currentSession.(*session); ok {
+		rs.warned = false
+	}
+}
+
+// TTLBucket count the live sessions whose remaining ttl is within (Min, Max]
+type TTLBucket struct {
+	Min time.Duration
+	// Max the upper bound, negative for the last bucket holding every longer ttl
+	Max   time.Duration
+	Count int64
+}
+
+// defaultTTLBounds the bucket bounds of TTLHistogram when none are given
+var defaultTTLBounds = []time.Duration{time.Minute, 5 * time.Minute, 15 * time.Minute, time.Hour, 6 * time.Hour, 24 * time.Hour, 7 * 24 * time.Hour}
+
+// TTLHistogram return how many live sessions have their remaining ttl within each bucket of the ascending
+// bounds, and past the last one, for capacity planning or to check the idle timeout takes effect.
+// It is read from the expiry index, and defaults to bounds from a minute to a week.
+func (p *provider) TTLHistogram(ctx context.Context, bounds ...time.Duration) ([]TTLBucket, error) {
+	if len(bounds) == 0 {
+		bounds = defaultTTLBounds
+	}
+	now := p.now()
+	buckets := make([]TTLBucket, 0, len(bounds)+1)
+	counts := make([]*r.IntCmd, 0, len(bounds)+1)
+	_, err := p.scans().WithContext(ctx).Pipelined(func(pipe r.Pipeliner) error {
+		min := time.Duration(0)
+		for i := 0; i <= len(bounds); i++ {
+			max, upper := time.Duration(-1), "+inf"
+			if i < len(bounds) {
+				max, upper = bounds[i], formatTime(now.Add(bounds[i]))
+			}
+			buckets = append(buckets, TTLBucket{Min: min, Max: max})
+			counts = append(counts, pipe.ZCount(p.getExpiryKey(), "("+formatTime(now.Add(min)), upper))
+			min = max
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, wrapErr(err)
+	}
+	for i, count := range counts {
+		buckets[i].Count = count.Val()
+	}
+	return buckets, nil
+}
@@ -0,0 +1,87 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	s "github.com/go-the-way/anoweb/session"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProviderExpiryWarning(t *testing.T) {
+	warnedCh := make(chan string, 2)
+	p := ProviderWithOptions(redisOptions, WithPrefixKey("_expiry_:"), WithExpiryWarning(time.Minute),
+		WithListener(&Listener{AboutToExpire: func(session s.Session, ttl time.Duration) {
+			warnedCh <- session.Id()
+		}}))
+	p.Clear()
+	soon := p.New(&s.Config{Valid: 30 * time.Second}, nil)
+	_ = p.New(&s.Config{Valid: time.Hour}, nil)
+
+	p.cleanSession(nil)
+	p.cleanSession(nil)
+	require.Equal(t, soon.Id(), <-warnedCh)
+	select {
+	case id := <-warnedCh:
+		t.Errorf("unexpected warning for %s", id)
+	case <-time.After(time.Millisecond * 100):
+	}
+}
+
+func TestProviderExpiryIndex(t *testing.T) {
+	p := ProviderWithPrefixKey(redisOptions, "_expiry_index_:")
+	p.Clear()
+	destroyedCh := make(chan string, 1)
+	listener := &s.Listener{Destroyed: func(session s.Session) { destroyedCh <- session.Id() }}
+	currSession := p.New(&s.Config{Valid: time.Second}, nil)
+	score, err := p.client.ZScore(p.getExpiryKey(), currSession.Id()).Result()
+	require.Nil(t, err)
+	require.InDelta(t, float64(time.Now().Add(time.Second).UnixNano()/int64(time.Millisecond)), score, 500)
+
+	p.Refresh(currSession, &s.Config{Valid: time.Minute}, nil)
+	score, err = p.client.ZScore(p.getExpiryKey(), currSession.Id()).Result()
+	require.Nil(t, err)
+	require.InDelta(t, float64(time.Now().Add(time.Minute).UnixNano()/int64(time.Millisecond)), score, 500)
+
+	p.Refresh(currSession, &s.Config{Valid: time.Second}, nil)
+	p.cleanSession(listener)
+	require.True(t, p.Exists(currSession.Id()))
+	time.Sleep(time.Millisecond * 1500)
+	p.cleanSession(listener)
+	require.Equal(t, currSession.Id(), <-destroyedCh)
+	require.False(t, p.Exists(currSession.Id()))
+}
+
+func TestProviderTTLHistogram(t *testing.T) {
+	p := ProviderWithPrefixKey(redisOptions, "_ttl_histogram_:")
+	p.Clear()
+	_ = p.New(&s.Config{Valid: 30 * time.Second}, nil)
+	_ = p.New(&s.Config{Valid: 10 * time.Minute}, nil)
+	_ = p.New(&s.Config{Valid: 20 * time.Minute}, nil)
+	_ = p.New(&s.Config{Valid: 48 * time.Hour}, nil)
+	buckets, err := p.TTLHistogram(context.Background(), time.Minute, time.Hour)
+	require.NoError(t, err)
+	require.Equal(t, []TTLBucket{
+		{Min: 0, Max: time.Minute, Count: 1},
+		{Min: time.Minute, Max: time.Hour, Count: 2},
+		{Min: time.Hour, Max: -1, Count: 1},
+	}, buckets)
+
+	buckets, err = p.TTLHistogram(context.Background())
+	require.NoError(t, err)
+	require.Len(t, buckets, len(defaultTTLBounds)+1)
+	p.Clear()
+}
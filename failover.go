@@ -0,0 +1,220 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	r "github.com/go-redis/redis"
+)
+
+// readCommands the commands which don't change data, or which mustn't be repeated on the standby
+var readCommands = map[string]struct{}{
+	"get": {}, "mget": {}, "exists": {}, "ttl": {}, "pttl": {}, "type": {}, "strlen": {},
+	"hget": {}, "hmget": {}, "hgetall": {}, "hexists": {}, "hkeys": {}, "hvals": {}, "hlen": {},
+	"smembers": {}, "scard": {}, "sismember": {}, "zrange": {}, "zrangebyscore": {}, "zrevrange": {},
+	"zscore": {}, "zcard": {}, "zcount": {}, "zrank": {}, "lrange": {}, "llen": {},
+	"scan": {}, "keys": {}, "dbsize": {}, "memory": {}, "object": {}, "info": {}, "ping": {},
+	"publish": {}, "wait": {}, "script": {}, "ft.search": {}, "ft.info": {}, "json.get": {},
+}
+
+// unreachable report whether err tells the server couldn't be reached, rather than refusing a command
+func unreachable(err error) bool {
+	if err == nil || err == r.Nil {
+		return false
+	}
+	if _, ok := err.(net.Error); ok {
+		return true
+	}
+	return err == io.EOF || err == io.ErrUnexpectedEOF || strings.HasPrefix(err.Error(), "redis: connection pool timeout")
+}
+
+// failover run the commands of the primary on the active endpoint, mirroring the writes to the standby.
+// Endpoint 0 is the primary and 1 the secondary, the roles being swapped when the active one can't be reached.
+type failover struct {
+	names       [2]string
+	pings       [2]*r.Client
+	secondary   *r.Client
+	active      int32
+	standbyDown int32
+	emit        func(e Event)
+	report      func(err error)
+}
+
+func newFailover(primary, secondary *r.Options) *failover {
+	f := &failover{
+		pings:     [2]*r.Client{r.NewClient(primary), r.NewClient(secondary)},
+		secondary: r.NewClient(secondary),
+	}
+	for i, client := range f.pings {
+		f.names[i] = fmt.Sprintf("%s/%d", client.Options().Addr, client.Options().DB)
+	}
+	return f
+}
+
+// failOver make the standby active after the active endpoint from failed to answer, unless done meanwhile
+func (f *failover) failOver(from int32) {
+	if atomic.LoadInt32(&f.standbyDown) == 1 || !atomic.CompareAndSwapInt32(&f.active, from, 1-from) {
+		return
+	}
+	atomic.StoreInt32(&f.standbyDown, 1)
+	f.emit(Event{Kind: EventShardDown, Shard: f.names[from]})
+	f.emit(Event{Kind: EventFailover, Shard: f.names[1-from]})
+}
+
+// standbyFailed stop mirroring to the standby until the health check sees it again
+func (f *failover) standbyFailed(err error) {
+	if atomic.CompareAndSwapInt32(&f.standbyDown, 0, 1) {
+		f.report(err)
+		f.emit(Event{Kind: EventShardDown, Shard: f.names[1-atomic.LoadInt32(&f.active)]})
+	}
+}
+
+// mirrored return a copy of the writes among cmds, to run on the standby
+func mirrored(cmds ...r.Cmder) []r.Cmder {
+	writes := make([]r.Cmder, 0, len(cmds))
+	for _, cmd := range cmds {
+		if _, read := readCommands[cmd.Name()]; !read {
+			writes = append(writes, r.NewCmd(cmd.Args()...))
+		}
+	}
+	return writes
+}
+
+// mirror run writes through process on the standby, loading the scripts it doesn't know yet
+func (f *failover) mirror(process func(cmds []r.Cmder) error, writes []r.Cmder) {
+	if len(writes) == 0 || atomic.LoadInt32(&f.standbyDown) == 1 {
+		return
+	}
+	err := process(writes)
+	if unreachable(err) {
+		f.standbyFailed(err)
+		return
+	}
+	retries := make([]r.Cmder, 0)
+	for _, cmd := range writes {
+		args := cmd.Args()
+		if cmd.Name() != "evalsha" || cmd.Err() == nil || !strings.HasPrefix(cmd.Err().Error(), "NOSCRIPT") {
+			continue
+		}
+		if src, have := scriptSources[fmt.Sprint(args[1])]; have {
+			retries = append(retries, r.NewCmd(append([]interface{}{"eval", src}, args[2:]...)...))
+		}
+	}
+	if len(retries) > 0 {
+		if err = process(retries); unreachable(err) {
+			f.standbyFailed(err)
+		}
+	}
+}
+
+// each return a func running cmds one by one through process, returning the first error
+func each(process func(cmd r.Cmder) error) func(cmds []r.Cmder) error {
+	return func(cmds []r.Cmder) error {
+		var first error
+		for _, cmd := range cmds {
+			if err := process(cmd); err != nil && first == nil {
+				first = err
+			}
+		}
+		return first
+	}
+}
+
+// pipeline return a func running cmds in a pipeline of client
+func pipeline(client *r.Client) func(cmds []r.Cmder) error {
+	return func(cmds []r.Cmder) error {
+		pipe := client.Pipeline()
+		for _, cmd := range cmds {
+			_ = pipe.Process(cmd)
+		}
+		_, err := pipe.Exec()
+		return err
+	}
+}
+
+// wrap run the commands and pipelines of client, which connects to the primary, on the active endpoint
+func (f *failover) wrap(client *r.Client) {
+	client.WrapProcess(func(old func(cmd r.Cmder) error) func(cmd r.Cmder) error {
+		endpoints := [2]func(cmds []r.Cmder) error{each(old), each(f.secondary.Process)}
+		return func(cmd r.Cmder) error {
+			return f.run(endpoints, []r.Cmder{cmd})
+		}
+	})
+	client.WrapProcessPipeline(func(old func(cmds []r.Cmder) error) func(cmds []r.Cmder) error {
+		endpoints := [2]func(cmds []r.Cmder) error{old, pipeline(f.secondary)}
+		return func(cmds []r.Cmder) error {
+			return f.run(endpoints, cmds)
+		}
+	})
+}
+
+// run cmds on the active endpoint, failing over to the standby when it can't be reached, and mirror the writes
+func (f *failover) run(endpoints [2]func(cmds []r.Cmder) error, cmds []r.Cmder) error {
+	active := atomic.LoadInt32(&f.active)
+	err := endpoints[active](cmds)
+	if unreachable(err) {
+		f.failOver(active)
+		if atomic.LoadInt32(&f.active) == active {
+			return err
+		}
+		active = 1 - active
+		err = endpoints[active](cmds)
+	}
+	if err == nil || err == r.Nil {
+		f.mirror(endpoints[1-active], mirrored(cmds...))
+	}
+	return err
+}
+
+// watch ping the standby every shardCheckInterval, for ever, mirroring to it again once it answers.
+// Writes made meanwhile are missing on it until rewritten.
+func (f *failover) watch() {
+	for {
+		time.Sleep(shardCheckInterval)
+		standby := 1 - atomic.LoadInt32(&f.active)
+		if atomic.LoadInt32(&f.standbyDown) == 1 && f.pings[standby].Ping().Err() == nil {
+			atomic.StoreInt32(&f.standbyDown, 0)
+			f.emit(Event{Kind: EventShardUp, Shard: f.names[standby]})
+		}
+	}
+}
+
+// applyFailover run the commands of p on the active endpoint of WithFailover
+func (p *provider) applyFailover() {
+	if p.secondary == nil {
+		return
+	}
+	p.failover = newFailover(p.client.Options(), p.secondary)
+	p.failover.emit = p.events.emit
+	p.failover.report = p.report
+	for _, client := range []*r.Client{p.client, p.readClient, p.scanClient} {
+		if client != nil {
+			p.failover.wrap(client)
+		}
+	}
+	go p.failover.watch()
+}
+
+// ActiveEndpoint return the address and database of the endpoint sessions are stored on, which is
+// the secondary of WithFailover after a failover, empty without WithFailover
+func (p *provider) ActiveEndpoint() string {
+	if p.failover == nil {
+		return ""
+	}
+	return p.failover.names[atomic.LoadInt32(&p.failover.active)]
+}
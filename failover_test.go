@@ -0,0 +1,56 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"testing"
+	"time"
+
+	rds "github.com/go-redis/redis"
+	s "github.com/go-the-way/anoweb/session"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProviderFailoverMirror(t *testing.T) {
+	secondary := *redisOptions
+	secondary.DB = 3
+	p := ProviderWithOptions(redisOptions, WithPrefixKey("_failover_:"), WithFailover(&secondary))
+	mirror := rds.NewClient(&secondary)
+	defer func() {
+		_ = mirror.Close()
+	}()
+	currSession := p.New(&s.Config{Valid: time.Minute}, nil).(*session)
+	currSession.Set("apple", "100")
+	require.NoError(t, currSession.Touch())
+	require.Equal(t, "100", mirror.HGet(currSession.key, "apple").Val())
+	require.True(t, mirror.PTTL(currSession.key).Val() > 0)
+	require.Equal(t, p.failover.names[0], p.ActiveEndpoint())
+	p.Del(currSession.Id())
+	require.Zero(t, mirror.Exists(currSession.key).Val())
+}
+
+func TestProviderFailover(t *testing.T) {
+	down := *redisOptions
+	down.Addr = "127.0.0.1:1"
+	down.Dialer = nil
+	secondary := *redisOptions
+	secondary.DB = 3
+	p := ProviderWithOptions(&down, WithPrefixKey("_failover_:"), WithFailover(&secondary))
+	require.Equal(t, p.failover.names[1], p.ActiveEndpoint())
+	currSession := p.New(&s.Config{Valid: time.Minute}, nil)
+	require.NotNil(t, currSession)
+	currSession.Set("apple", "100")
+	require.Equal(t, "100", currSession.Get("apple"))
+	require.True(t, p.Exists(currSession.Id()))
+	p.Del(currSession.Id())
+}
@@ -0,0 +1,127 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"errors"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	r "github.com/go-redis/redis"
+)
+
+// ErrInjectedFault the error injected by a Fault without Err
+var ErrInjectedFault = errors.New("rsn: injected fault")
+
+// Fault describe the errors and latency injected into the redis operations named Op
+type Fault struct {
+	// Op the lower case command name, or "pipeline", every operation matching when empty
+	Op string
+	// ErrorRate the probability, from 0 to 1, that an operation fails with Err instead of running
+	ErrorRate float64
+	// Err the error injected, ErrInjectedFault when nil
+	Err error
+	// LatencyRate the probability, from 0 to 1, that an operation is delayed by Latency
+	LatencyRate float64
+	Latency     time.Duration
+}
+
+// FaultInjector inject the faults it is set to into the redis operations of the providers given it
+// by WithFaultInjector, so applications can test how they behave when the session store degrades.
+// Faults can be changed at any time, e.g. to fail redis in the middle of a test.
+type FaultInjector struct {
+	mu     sync.Mutex
+	faults []Fault
+	rand   *rand.Rand
+	broken map[error]*r.Client
+}
+
+// NewFaultInjector return an injector drawing its probabilities from seed, injecting nothing until Set
+func NewFaultInjector(seed int64) *FaultInjector {
+	return &FaultInjector{rand: rand.New(rand.NewSource(seed)), broken: map[error]*r.Client{}}
+}
+
+// Set inject faults from now on, instead of those set before. No faults stop the injection.
+func (fi *FaultInjector) Set(faults ...Fault) {
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+	fi.faults = faults
+}
+
+// inject delay op as drawn from the faults, and return the error it must fail with, nil to run it
+func (fi *FaultInjector) inject(op string) error {
+	fi.mu.Lock()
+	var (
+		delay time.Duration
+		err   error
+	)
+	for _, fault := range fi.faults {
+		if fault.Op != "" && fault.Op != op {
+			continue
+		}
+		if fault.LatencyRate > 0 && fi.rand.Float64() < fault.LatencyRate {
+			delay += fault.Latency
+		}
+		if err == nil && fault.ErrorRate > 0 && fi.rand.Float64() < fault.ErrorRate {
+			err = fault.Err
+			if err == nil {
+				err = ErrInjectedFault
+			}
+		}
+	}
+	fi.mu.Unlock()
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+	return err
+}
+
+// fail make cmds fail with err. The error of a command can only be set by a client running it,
+// so they are run by a client whose connections all fail with err.
+func (fi *FaultInjector) fail(err error, cmds []r.Cmder) error {
+	fi.mu.Lock()
+	broken, have := fi.broken[err]
+	if !have {
+		broken = r.NewClient(&r.Options{
+			Dialer:      func() (net.Conn, error) { return nil, err },
+			IdleTimeout: -1,
+		})
+		fi.broken[err] = broken
+	}
+	fi.mu.Unlock()
+	if len(cmds) == 1 {
+		return broken.Process(cmds[0])
+	}
+	return pipeline(broken)(cmds)
+}
+
+// wrap inject the faults into the commands and pipelines of client
+func (fi *FaultInjector) wrap(client *r.Client) {
+	client.WrapProcess(func(old func(cmd r.Cmder) error) func(cmd r.Cmder) error {
+		return func(cmd r.Cmder) error {
+			if err := fi.inject(cmd.Name()); err != nil {
+				return fi.fail(err, []r.Cmder{cmd})
+			}
+			return old(cmd)
+		}
+	})
+	client.WrapProcessPipeline(func(old func(cmds []r.Cmder) error) func(cmds []r.Cmder) error {
+		return func(cmds []r.Cmder) error {
+			if err := fi.inject("pipeline"); err != nil {
+				return fi.fail(err, cmds)
+			}
+			return old(cmds)
+		}
+	})
+}
@@ -0,0 +1,49 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	s "github.com/go-the-way/anoweb/session"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProviderFaultInjector(t *testing.T) {
+	injector := NewFaultInjector(1)
+	p := ProviderWithOptions(redisOptions, WithPrefixKey("_faults_:"), WithFaultInjector(injector))
+	currSession := p.New(&s.Config{Valid: time.Minute}, nil).(*session)
+	require.NoError(t, currSession.SetValue("apple", "100"))
+
+	injector.Set(Fault{Op: "hmset", ErrorRate: 1})
+	require.True(t, errors.Is(currSession.SetValue("apple", "200"), ErrInjectedFault))
+	require.Equal(t, "100", currSession.Get("apple"))
+
+	down := errors.New("down")
+	injector.Set(Fault{ErrorRate: 1, Err: down})
+	_, err := currSession.TTL()
+	require.True(t, errors.Is(err, down))
+	_, _, err = p.List(context.Background(), 0, 10)
+	require.True(t, errors.Is(err, down))
+
+	injector.Set(Fault{Op: "hget", LatencyRate: 1, Latency: time.Millisecond * 50})
+	start := time.Now()
+	require.Equal(t, "100", currSession.Get("apple"))
+	require.True(t, time.Since(start) >= time.Millisecond*50)
+
+	injector.Set()
+	p.Del(currSession.Id())
+}
@@ -0,0 +1,86 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"strings"
+	"sync/atomic"
+	"time"
+
+	r "github.com/go-redis/redis"
+)
+
+const fieldExpiryPrefixKey = "field-expiry-sessions:"
+
+// hash field expiration support of the server, as found by the first SetWithTTL
+const (
+	hashFieldTTLUnknown int32 = iota
+	hashFieldTTLSupported
+	hashFieldTTLUnsupported
+)
+
+// getFieldExpiryKey return the key of the sorted set scoring session id and field name pairs by expiry time,
+// used on servers without hash field expiration
+func (p *provider) getFieldExpiryKey() string {
+	return fieldExpiryPrefixKey + p.keyPrefix
+}
+
+// SetWithTTL set named val into session, expiring it alone after ttl while the session lives on.
+// Redis 7.4 hash field expiration is used when the server supports it, otherwise the field
+// is deleted by the first cleaning pass after ttl. A ttl <= 0 sets val without expiry.
+func (s *session) SetWithTTL(name string, val interface{}, ttl time.Duration) error {
+	if err := s.SyncSet(name, val); err != nil || ttl <= 0 {
+		return err
+	}
+	p := s.provider
+	if atomic.LoadInt32(&p.hashFieldTTL) != hashFieldTTLUnsupported {
+		err := s.client.Do("HPEXPIRE", s.key, int64(ttl/time.Millisecond), "FIELDS", 1, name).Err()
+		if err == nil {
+			atomic.StoreInt32(&p.hashFieldTTL, hashFieldTTLSupported)
+			return nil
+		}
+		if !strings.HasPrefix(err.Error(), "ERR unknown command") {
+			return wrapErr(err)
+		}
+		atomic.StoreInt32(&p.hashFieldTTL, hashFieldTTLUnsupported)
+	}
+	score := float64(p.now().Add(ttl).UnixNano() / int64(time.Millisecond))
+	return wrapErr(p.client.ZAdd(p.getFieldExpiryKey(), r.Z{Score: score, Member: s.id + ":" + name}).Err())
+}
+
+// expireFields delete the fields set by SetWithTTL due to expire by now, on servers without hash field expiration
+func (p *provider) expireFields(now time.Time) {
+	if atomic.LoadInt32(&p.hashFieldTTL) == hashFieldTTLSupported {
+		return
+	}
+	fieldExpiryKey := p.getFieldExpiryKey()
+	members, err := p.client.ZRangeByScore(fieldExpiryKey, r.ZRangeBy{Min: "-inf", Max: formatTime(now)}).Result()
+	if err != nil {
+		p.report(err)
+		return
+	}
+	if len(members) == 0 {
+		return
+	}
+	_, err = p.client.Pipelined(func(pipe r.Pipeliner) error {
+		for _, member := range members {
+			if parts := strings.SplitN(member, ":", 2); len(parts) == 2 {
+				pipe.HDel(p.getRedisKey(parts[0]), parts[1])
+			}
+		}
+		pipe.ZRemRangeByScore(fieldExpiryKey, "-inf", formatTime(now))
+		return nil
+	})
+	if err != nil {
+		p.report(err)
+	}
+}
@@ -0,0 +1,43 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	s "github.com/go-the-way/anoweb/session"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionSetWithTTL(t *testing.T) {
+	clock := &fixedClock{time.Now()}
+	p := ProviderWithOptions(redisOptions, WithPrefixKey("_field_ttl_:"), WithClock(clock))
+	currSession := p.New(&s.Config{Valid: time.Hour}, nil).(*session)
+	require.NoError(t, currSession.SetWithTTL("otp", "123456", time.Minute))
+	require.NoError(t, currSession.SetWithTTL("name", "kept", 0))
+	require.Equal(t, ErrReservedField, currSession.SetWithTTL(p.field(userIdName), "u1", time.Minute))
+	require.Equal(t, "123456", currSession.Get("otp"))
+
+	p.CleanNow(nil)
+	require.Equal(t, "123456", currSession.Get("otp"))
+	clock.now = clock.now.Add(time.Minute)
+	p.CleanNow(nil)
+	if atomic.LoadInt32(&p.hashFieldTTL) == hashFieldTTLUnsupported {
+		require.Nil(t, currSession.Get("otp"))
+	}
+	require.Equal(t, "kept", currSession.Get("name"))
+	require.Equal(t, ErrReadOnlySession, currSession.ReadOnly().SetWithTTL("otp", "1", time.Minute))
+	p.Del(currSession.Id())
+}
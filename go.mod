@@ -3,6 +3,7 @@ module github.com/go-the-way/rsn
 go 1.16
 
 require (
+	github.com/alicebob/miniredis/v2 v2.39.0
 	github.com/go-redis/redis v6.15.9+incompatible
 	github.com/go-the-way/anoweb v1.0.0
 	github.com/onsi/ginkgo v1.16.5 // indirect
@@ -0,0 +1,97 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"fmt"
+	"time"
+
+	r "github.com/go-redis/redis"
+)
+
+// Hooks observe every redis operation of the provider, e.g. for metrics, slow operation logging
+// or injecting latency. op is the lower case command name, or "pipeline" for a pipeline, and key
+// the first key it touches, empty if none. Either func may be nil.
+type Hooks struct {
+	// BeforeOp called before op runs
+	BeforeOp func(op, key string)
+	// AfterOp called after op ran with how long it took and its error, nil when a key was just missing
+	AfterOp func(op, key string, took time.Duration, err error)
+}
+
+// cmdKey return the key cmd touches first, EVAL and EVALSHA naming it after the script and key count
+func cmdKey(cmd r.Cmder) string {
+	args := cmd.Args()
+	at := 1
+	switch cmd.Name() {
+	case "eval", "evalsha":
+		at = 3
+	}
+	if len(args) <= at {
+		return ""
+	}
+	return fmt.Sprint(args[at])
+}
+
+// run call fn as op on key between the hooks
+func (h *Hooks) run(op, key string, fn func() error) error {
+	if h.BeforeOp != nil {
+		h.BeforeOp(op, key)
+	}
+	start := time.Now()
+	err := fn()
+	if h.AfterOp != nil {
+		reported := err
+		if reported == r.Nil {
+			reported = nil
+		}
+		h.AfterOp(op, key, time.Since(start), reported)
+	}
+	return err
+}
+
+// instrument run the commands and pipelines of client between the hooks set by WithHooks,
+// logging the slow ones as set by WithSlowLog, with the faults of WithFaultInjector,
+// recording them as set by WithCommandRecorder
+func (p *provider) instrument(client *r.Client) {
+	if p.faults != nil {
+		p.faults.wrap(client)
+	}
+	if p.recorder != nil {
+		p.recorder.wrap(client)
+	}
+	h := p.hooks
+	if p.slowLog != nil {
+		h = p.slowLog.wrap(h)
+	}
+	if h == nil {
+		return
+	}
+	client.WrapProcess(func(old func(cmd r.Cmder) error) func(cmd r.Cmder) error {
+		return func(cmd r.Cmder) error {
+			return h.run(cmd.Name(), cmdKey(cmd), func() error {
+				return old(cmd)
+			})
+		}
+	})
+	client.WrapProcessPipeline(func(old func(cmds []r.Cmder) error) func(cmds []r.Cmder) error {
+		return func(cmds []r.Cmder) error {
+			key := ""
+			if len(cmds) > 0 {
+				key = cmdKey(cmds[0])
+			}
+			return h.run("pipeline", key, func() error {
+				return old(cmds)
+			})
+		}
+	})
+}
@@ -0,0 +1,58 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	s "github.com/go-the-way/anoweb/session"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProviderHooks(t *testing.T) {
+	var mu sync.Mutex
+	before := map[string]string{}
+	var after []string
+	p := ProviderWithOptions(redisOptions, WithPrefixKey("_hooks_:"), WithHooks(&Hooks{
+		BeforeOp: func(op, key string) {
+			mu.Lock()
+			defer mu.Unlock()
+			before[op] = key
+		},
+		AfterOp: func(op, key string, took time.Duration, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			require.NoError(t, err)
+			require.True(t, took >= 0)
+			after = append(after, op)
+		},
+	}))
+	currSession := p.New(&s.Config{Valid: time.Minute}, nil)
+	require.Nil(t, currSession.Get("missing"))
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Equal(t, p.getRedisKey(currSession.Id()), before["hget"])
+	require.Equal(t, "", before["ping"])
+	require.Contains(t, after, "hget")
+	require.Contains(t, after, "ping")
+}
+
+func TestCmdKey(t *testing.T) {
+	p := ProviderWithPrefixKey(redisOptions, "_hooks_key_:")
+	require.Equal(t, "k", cmdKey(p.client.Get("k")))
+	require.Equal(t, "k", cmdKey(p.client.Eval("return 1", []string{"k"})))
+	require.Equal(t, "", cmdKey(p.client.Ping()))
+}
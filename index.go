@@ -0,0 +1,124 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	r "github.com/go-redis/redis"
+
+	s "github.com/go-the-way/anoweb/session"
+)
+
+const indexPrefixKey = "index-sessions:"
+
+// indexSetScript set a hash field and move the session between value sets atomically,
+// so the set member always matches the value redis actually stored
+var indexSetScript = newScript(`
+local old = redis.call('HGET', KEYS[1], ARGV[1])
+redis.call('HSET', KEYS[1], ARGV[1], ARGV[2])
+if old then
+	redis.call('SREM', ARGV[3] .. old, ARGV[4])
+end
+redis.call('SADD', ARGV[3] .. ARGV[2], ARGV[4])
+return 1
+`)
+
+// indexDelScript delete a hash field and remove the session from its value set
+var indexDelScript = newScript(`
+local old = redis.call('HGET', KEYS[1], ARGV[1])
+redis.call('HDEL', KEYS[1], ARGV[1])
+if old then
+	redis.call('SREM', ARGV[2] .. old, ARGV[3])
+end
+return 1
+`)
+
+func (p *provider) indexed(field string) bool {
+	_, have := p.indexes[field]
+	return have
+}
+
+// getIndexKeyPrefix return the key prefix of the value sets of field
+func (p *provider) getIndexKeyPrefix(field string) string {
+	return indexPrefixKey + p.keyPrefix + field + ":"
+}
+
+func (p *provider) getIndexKey(field, value string) string {
+	return p.getIndexKeyPrefix(field) + value
+}
+
+func (s *session) indexDel(name string) {
+	indexPrefix := s.provider.getIndexKeyPrefix(name)
+	evalCmd := indexDelScript.Run(s.client, []string{s.key}, name, indexPrefix, s.id)
+	if evalCmd.Err() != nil {
+		_, _ = fmt.Fprintln(os.Stderr, evalCmd.Err())
+	}
+}
+
+// unindex remove session id from the value sets of all indexed fields
+func (p *provider) unindex(id string) {
+	if len(p.indexes) == 0 {
+		return
+	}
+	fields := make([]string, 0, len(p.indexes))
+	for field := range p.indexes {
+		fields = append(fields, field)
+	}
+	hashMGetCmd := p.client.HMGet(p.getRedisKey(id), fields...)
+	if hashMGetCmd.Err() != nil {
+		_, _ = fmt.Fprintln(os.Stderr, hashMGetCmd.Err())
+		return
+	}
+	_, err := p.client.Pipelined(func(pipe r.Pipeliner) error {
+		for i, val := range hashMGetCmd.Val() {
+			if value, ok := val.(string); ok {
+				pipe.SRem(p.getIndexKey(fields[i], value), id)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+	}
+}
+
+// Find return sessions whose field holds value.
+//
+// field must be registered with WithIndex. Index entries of sessions which expired
+// meanwhile are dropped while searching.
+func (p *provider) Find(ctx context.Context, field, value string) ([]s.Session, error) {
+	if !p.indexed(field) {
+		return nil, fmt.Errorf("rsn: field %q is not indexed", field)
+	}
+	client := p.client.WithContext(ctx)
+	indexKey := p.getIndexKey(field, value)
+	ids, err := client.SMembers(indexKey).Result()
+	if err != nil {
+		return nil, wrapErr(err)
+	}
+	sessions := make([]s.Session, 0, len(ids))
+	for _, id := range ids {
+		val, err := client.HGet(p.getRedisKey(id), field).Result()
+		if err == r.Nil || (err == nil && val != value) {
+			client.SRem(indexKey, id)
+			continue
+		}
+		if err != nil {
+			return nil, wrapErr(err)
+		}
+		sessions = append(sessions, p.load(id))
+	}
+	return sessions, nil
+}
@@ -0,0 +1,54 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	s "github.com/go-the-way/anoweb/session"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProviderFind(t *testing.T) {
+	p := ProviderWithOptions(redisOptions, WithPrefixKey("_find_:"), WithIndex("role"))
+	p.Clear()
+	config := &s.Config{Valid: time.Minute}
+	admin := p.New(config, nil)
+	admin.Set("role", "admin")
+	user := p.New(config, nil)
+	user.SetAll(map[string]interface{}{"role": "admin", "apple": "100"}, false)
+	user.Set("role", "user")
+	other := p.New(config, nil)
+	other.Set("role", "admin")
+	p.Del(other.Id())
+
+	found, err := p.Find(context.Background(), "role", "admin")
+	require.Nil(t, err)
+	require.Equal(t, 1, len(found))
+	require.Equal(t, admin.Id(), found[0].Id())
+
+	found, err = p.Find(context.Background(), "role", "user")
+	require.Nil(t, err)
+	require.Equal(t, 1, len(found))
+	require.Equal(t, "100", found[0].Get("apple"))
+
+	user.Del("role")
+	found, err = p.Find(context.Background(), "role", "user")
+	require.Nil(t, err)
+	require.Equal(t, 0, len(found))
+
+	_, err = p.Find(context.Background(), "apple", "100")
+	require.Error(t, err)
+}
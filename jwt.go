@@ -0,0 +1,151 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	s "github.com/go-the-way/anoweb/session"
+)
+
+// ErrInvalidToken returned when a JWT is malformed, not signed with the secret, or expired
+var ErrInvalidToken = errors.New("rsn: invalid token")
+
+// jwtHeader is the encoded header of the HS256 tokens minted by MintToken
+var jwtHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// registeredClaims are the claims of a token which aren't session values
+var registeredClaims = map[string]struct{}{
+	"iss": {}, "sub": {}, "aud": {}, "exp": {}, "nbf": {}, "iat": {}, "jti": {}, "sid": {},
+}
+
+// MintToken return a JWT signed with secret by HS256 and valid for ttl, for services speaking tokens
+// to act for session. It holds the session id as sid, the bound user as sub and the named fields of session.
+func (p *provider) MintToken(session s.Session, secret []byte, ttl time.Duration, fields ...string) (string, error) {
+	now := p.now()
+	claims := map[string]interface{}{
+		"sid": session.Id(),
+		"iat": now.Unix(),
+		"exp": now.Add(ttl).Unix(),
+	}
+	if rs, ok := session.(Session); ok {
+		if userId := rs.UserId(); userId != "" {
+			claims["sub"] = userId
+		}
+	}
+	for _, field := range fields {
+		if _, registered := registeredClaims[field]; !registered {
+			if val := session.Get(field); val != nil {
+				claims[field] = val
+			}
+		}
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	unsigned := jwtHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return unsigned + "." + jwtSignature(unsigned, secret), nil
+}
+
+// NewFromToken return new session for the user of a JWT signed with secret by HS256, for services
+// speaking cookies to serve clients holding a token. The session is bound to the sub claim and holds
+// the claims which aren't registered ones, array and object claims encoded as json. ErrInvalidToken
+// is returned for a token not valid now.
+func (p *provider) NewFromToken(token string, secret []byte, config *s.Config) (s.Session, error) {
+	claims, err := p.verifyToken(token, secret)
+	if err != nil {
+		return nil, err
+	}
+	values := map[string]interface{}{}
+	for name, val := range claims {
+		if _, registered := registeredClaims[name]; registered {
+			continue
+		}
+		switch val.(type) {
+		case []interface{}, map[string]interface{}:
+			encoded, err := json.Marshal(val)
+			if err != nil {
+				return nil, err
+			}
+			values[name] = string(encoded)
+		default:
+			values[name] = val
+		}
+	}
+	currentSession := p.New(config, nil)
+	if currentSession == nil {
+		return nil, ErrRedisUnavailable
+	}
+	rs := currentSession.(*session)
+	if sub, _ := claims["sub"].(string); sub != "" {
+		if err = rs.BindUser(sub); err != nil {
+			p.Del(rs.id)
+			return nil, err
+		}
+	}
+	if err = rs.SetValues(values, false); err != nil {
+		p.Del(rs.id)
+		return nil, err
+	}
+	return rs, nil
+}
+
+// verifyToken return the claims of token once its HS256 signature, exp and nbf are checked
+func (p *provider) verifyToken(token string, secret []byte) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+	header, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	var alg struct {
+		Alg string `json:"alg"`
+	}
+	if json.Unmarshal(header, &alg) != nil || alg.Alg != "HS256" {
+		return nil, ErrInvalidToken
+	}
+	if !hmac.Equal([]byte(parts[2]), []byte(jwtSignature(parts[0]+"."+parts[1], secret))) {
+		return nil, ErrInvalidToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	claims := map[string]interface{}{}
+	if json.Unmarshal(payload, &claims) != nil {
+		return nil, ErrInvalidToken
+	}
+	now := float64(p.now().Unix())
+	if exp, ok := claims["exp"].(float64); !ok || exp <= now {
+		return nil, ErrInvalidToken
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && nbf > now {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// jwtSignature return the HS256 signature of unsigned with secret
+func jwtSignature(unsigned string, secret []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	_, _ = mac.Write([]byte(unsigned))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
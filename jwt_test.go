@@ -0,0 +1,75 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+	"time"
+
+	s "github.com/go-the-way/anoweb/session"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProviderToken(t *testing.T) {
+	clock := &fixedClock{time.Now()}
+	p := ProviderWithOptions(redisOptions, WithPrefixKey("_token_:"), WithClock(clock))
+	secret := []byte("secret")
+	currSession := p.New(&s.Config{Valid: time.Minute}, nil).(Session)
+	require.NoError(t, currSession.BindUser("alice"))
+	currSession.Set("role", "admin")
+	currSession.Set("cart", "3")
+
+	token, err := p.MintToken(currSession, secret, time.Minute, "role")
+	require.NoError(t, err)
+	_, err = p.NewFromToken(token, []byte("other"), &s.Config{Valid: time.Minute})
+	require.Equal(t, ErrInvalidToken, err)
+	_, err = p.NewFromToken(token[:len(token)-2], secret, &s.Config{Valid: time.Minute})
+	require.Equal(t, ErrInvalidToken, err)
+
+	bridged, err := p.NewFromToken(token, secret, &s.Config{Valid: time.Minute})
+	require.NoError(t, err)
+	require.NotEqual(t, currSession.Id(), bridged.Id())
+	require.Equal(t, "alice", bridged.(Session).UserId())
+	require.Equal(t, "admin", bridged.Get("role"))
+	require.Nil(t, bridged.Get("cart"))
+	require.Nil(t, bridged.Get("sid"))
+
+	clock.now = clock.now.Add(time.Minute)
+	_, err = p.NewFromToken(token, secret, &s.Config{Valid: time.Minute})
+	require.Equal(t, ErrInvalidToken, err)
+	p.Del(currSession.Id())
+	p.Del(bridged.Id())
+}
+
+func TestProviderTokenStructuredClaims(t *testing.T) {
+	p := ProviderWithPrefixKey(redisOptions, "_token_:")
+	secret := []byte("secret")
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+	payload, _ := json.Marshal(map[string]interface{}{
+		"sub":   "bob",
+		"exp":   time.Now().Add(time.Minute).Unix(),
+		"roles": []string{"admin", "ops"},
+		"org":   map[string]interface{}{"id": 7},
+	})
+	signingInput := header + "." + base64.RawURLEncoding.EncodeToString(payload)
+	token := signingInput + "." + jwtSignature(signingInput, secret)
+
+	bridged, err := p.NewFromToken(token, secret, &s.Config{Valid: time.Minute})
+	require.NoError(t, err)
+	defer p.Del(bridged.Id())
+	require.Equal(t, "bob", bridged.(Session).UserId())
+	require.Equal(t, `["admin","ops"]`, bridged.Get("roles"))
+	require.Equal(t, `{"id":7}`, bridged.Get("org"))
+}
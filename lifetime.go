@@ -0,0 +1,108 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"errors"
+	"time"
+
+	s "github.com/go-the-way/anoweb/session"
+)
+
+// renewScript mark a session accessed and extend its ttl, and its document's, to the idle timeout,
+// or to its own lifetime when it is remembered, capped by the time left before its absolute deadline. A session past its
+// deadline is deleted with its document and -1 returned. When a refresh threshold is given the write is skipped
+// while enough ttl remains and the last access is recent.
+var renewScript = newScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return 0
+end
+local ttl = tonumber(ARGV[1])
+if redis.call('HEXISTS', KEYS[1], ARGV[7]) == 1 then
+	ttl = tonumber(redis.call('HGET', KEYS[1], ARGV[8])) or ttl
+end
+local now = tonumber(ARGV[2])
+local ratio = tonumber(ARGV[5])
+local interval = tonumber(ARGV[6])
+if ratio > 0 or interval > 0 then
+	local left = redis.call('PTTL', KEYS[1])
+	local last = tonumber(redis.call('HGET', KEYS[1], ARGV[4])) or 0
+	if (ratio <= 0 or left >= ttl * ratio) and (interval <= 0 or now - last < interval) then
+		return 2
+	end
+end
+local deadline = tonumber(redis.call('HGET', KEYS[1], ARGV[3]))
+if deadline then
+	local left = deadline - now
+	if left <= 0 then
+		redis.call('DEL', KEYS[1], KEYS[3])
+		redis.call('ZREM', KEYS[2], ARGV[9])
+		return -1
+	end
+	if left < ttl then
+		ttl = left
+	end
+end
+redis.call('HSET', KEYS[1], ARGV[4], ARGV[2])
+redis.call('PEXPIRE', KEYS[1], ttl)
+redis.call('PEXPIRE', KEYS[3], ttl)
+redis.call('ZADD', KEYS[2], now + ttl, ARGV[9])
+return 1
+`)
+
+// ErrSessionExpired returned when a session reached the absolute deadline set by WithMaxLifetime, and was deleted
+var ErrSessionExpired = errors.New("rsn: session expired")
+
+// renew mark session id accessed and extend it by idle, never past the deadline set by WithMaxLifetime.
+// With throttle the threshold set by WithRefreshThreshold applies, and false is returned when it
+// skipped the write. ErrSessionNotFound or ErrSessionExpired is returned for a session gone.
+func (p *provider) renew(id string, idle time.Duration, throttle bool) (bool, error) {
+	ratio, interval := 0.0, int64(0)
+	if throttle {
+		ratio, interval = p.refreshRatio, int64(p.refreshInterval/time.Millisecond)
+	}
+	now := formatTime(p.now())
+	renewed, err := renewScript.Run(p.client, []string{p.getRedisKey(id), p.getExpiryKey(), p.getDocumentKey(id)},
+		int64(idle/time.Millisecond), now, p.field(deadlineName), p.field(accessedAtName), ratio, interval,
+		p.field(rememberName), p.field(lifeTimeName), id).Int64()
+	if err != nil {
+		return false, wrapErr(err)
+	}
+	switch renewed {
+	case 0:
+		return false, ErrSessionNotFound
+	case -1:
+		return false, ErrSessionExpired
+	}
+	return renewed == 1, nil
+}
+
+// initialTTL return the ttl of a new session with idle timeout
+func (p *provider) initialTTL(idle time.Duration) time.Duration {
+	if p.maxLifetime > 0 && p.maxLifetime < idle {
+		return p.maxLifetime
+	}
+	return idle
+}
+
+// gone report whether err tells the session no longer exists
+func gone(err error) bool {
+	return errors.Is(err, ErrSessionNotFound) || errors.Is(err, ErrSessionExpired)
+}
+
+// valid return the idle timeout of sessions, config.Valid unless set by WithValid
+func (p *provider) valid(config *s.Config) time.Duration {
+	if p.idleTimeout > 0 {
+		return p.idleTimeout
+	}
+	return config.Valid
+}
@@ -0,0 +1,88 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"testing"
+	"time"
+
+	rds "github.com/go-redis/redis"
+
+	s "github.com/go-the-way/anoweb/session"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProviderMaxLifetime(t *testing.T) {
+	p := ProviderWithOptions(redisOptions, WithPrefixKey("_lifetime_:"), WithMaxLifetime(10*time.Second))
+	config := &s.Config{Valid: time.Minute}
+	currSession := p.New(config, nil).(Session)
+	ttl, err := currSession.TTL()
+	require.Nil(t, err)
+	require.True(t, ttl <= 10*time.Second)
+
+	p.Refresh(currSession, config, nil)
+	ttl, err = currSession.TTL()
+	require.Nil(t, err)
+	require.True(t, ttl <= 10*time.Second)
+	require.False(t, currSession.Invalidated())
+
+	c := rds.NewClient(redisOptions)
+	defer func() {
+		_ = c.Close()
+	}()
+	require.Nil(t, c.HSet("_lifetime_:"+currSession.Id(), p.field(deadlineName), formatTime(time.Now().Add(-time.Second))).Err())
+	p.Refresh(currSession, config, nil)
+	require.True(t, currSession.Invalidated())
+	require.Equal(t, int64(0), c.Exists("_lifetime_:"+currSession.Id()).Val())
+}
+
+func TestProviderIdleTimeout(t *testing.T) {
+	p := ProviderWithOptions(redisOptions, WithPrefixKey("_lifetime_:"), WithMaxLifetime(time.Hour))
+	config := &s.Config{Valid: time.Minute}
+	currSession := p.New(config, nil).(Session)
+	p.Refresh(currSession, config, nil)
+	ttl, err := currSession.TTL()
+	require.Nil(t, err)
+	require.True(t, ttl > 50*time.Second && ttl <= time.Minute)
+	p.Del(currSession.Id())
+}
+
+func TestProviderRefreshThreshold(t *testing.T) {
+	p := ProviderWithOptions(redisOptions, WithPrefixKey("_lifetime_:"), WithRefreshThreshold(0.8, time.Hour))
+	config := &s.Config{Valid: time.Minute}
+	currSession := p.New(config, nil).(Session)
+	c := rds.NewClient(redisOptions)
+	defer func() {
+		_ = c.Close()
+	}()
+	key := "_lifetime_:" + currSession.Id()
+
+	require.Nil(t, c.Expire(key, 55*time.Second).Err())
+	refreshedCh := make(chan struct{}, 1)
+	listener := &s.Listener{Refreshed: func(session s.Session) { refreshedCh <- struct{}{} }}
+	p.Refresh(currSession, config, listener)
+	ttl, _ := currSession.TTL()
+	require.True(t, ttl <= 55*time.Second)
+	select {
+	case <-refreshedCh:
+		t.Error("throttled refresh must not fire Refreshed")
+	case <-time.After(time.Millisecond * 50):
+	}
+
+	require.Nil(t, c.Expire(key, 30*time.Second).Err())
+	p.Refresh(currSession, config, listener)
+	ttl, _ = currSession.TTL()
+	require.True(t, ttl > 55*time.Second)
+	<-refreshedCh
+	p.Del(currSession.Id())
+}
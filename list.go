@@ -0,0 +1,88 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"context"
+
+	s "github.com/go-the-way/anoweb/session"
+)
+
+const scanBatchSize = 100
+
+// List return a page of session ids starting at cursor and the cursor of the next page.
+//
+// limit is passed to SCAN as COUNT hint, so a page may hold slightly more or fewer ids.
+// Iteration is complete when the returned cursor is 0.
+func (p *provider) List(ctx context.Context, cursor uint64, limit int64) ([]string, uint64, error) {
+	scanCmd := p.scans().WithContext(ctx).Scan(cursor, p.getKeyPattern("*"), limit)
+	keys, next, err := scanCmd.Result()
+	if err != nil {
+		return nil, 0, wrapErr(err)
+	}
+	ids := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if id, own := p.ownId(key); own {
+			ids = append(ids, id)
+		}
+	}
+	return ids, next, nil
+}
+
+// ForEach call fn with every session stored in redis.
+//
+// Sessions are walked with SCAN, so memory use stays bounded no matter how many exist.
+// Iteration stops at the first error returned by fn or when ctx is done, and that error is returned.
+func (p *provider) ForEach(ctx context.Context, fn func(s.Session) error) error {
+	cursor := uint64(0)
+	for {
+		if err := ctx.Err(); err != nil {
+			return wrapErr(err)
+		}
+		ids, next, err := p.List(ctx, cursor, p.scanCount())
+		if err != nil {
+			return wrapErr(err)
+		}
+		for _, id := range ids {
+			if err := ctx.Err(); err != nil {
+				return wrapErr(err)
+			}
+			if err := fn(p.load(id)); err != nil {
+				return wrapErr(err)
+			}
+		}
+		if next == 0 {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// GetAllStored return the sessions held in redis, including those created by peer instances
+func (p *provider) GetAllStored(ctx context.Context) (map[string]s.Session, error) {
+	sessions := map[string]s.Session{}
+	err := p.ForEach(ctx, func(currentSession s.Session) error {
+		sessions[currentSession.Id()] = currentSession
+		return nil
+	})
+	return sessions, wrapErr(err)
+}
+
+// load return the known session of id, or a new handle bound to its redis key
+func (p *provider) load(id string) s.Session {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if currentSession, have := p.sessions[id]; have {
+		return currentSession
+	}
+	return newSession(p, id)
+}
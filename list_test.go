@@ -0,0 +1,93 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	s "github.com/go-the-way/anoweb/session"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProviderList(t *testing.T) {
+	p := ProviderWithPrefixKey(redisOptions, "_list_:")
+	p.Clear()
+	want := map[string]bool{}
+	for i := 0; i < 5; i++ {
+		want[p.New(&s.Config{Valid: time.Minute}, nil).Id()] = true
+	}
+	got := map[string]bool{}
+	cursor := uint64(0)
+	for {
+		ids, next, err := p.List(context.Background(), cursor, 2)
+		require.Nil(t, err)
+		for _, id := range ids {
+			got[id] = true
+		}
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+	require.Equal(t, want, got)
+}
+
+func TestProviderForEach(t *testing.T) {
+	p := ProviderWithPrefixKey(redisOptions, "_foreach_:")
+	p.Clear()
+	want := map[string]bool{}
+	for i := 0; i < 5; i++ {
+		want[p.New(&s.Config{Valid: time.Minute}, nil).Id()] = true
+	}
+	got := map[string]bool{}
+	require.Nil(t, p.ForEach(context.Background(), func(session s.Session) error {
+		got[session.Id()] = true
+		return nil
+	}))
+	require.Equal(t, want, got)
+
+	stop := errors.New("stop")
+	count := 0
+	require.Equal(t, stop, p.ForEach(context.Background(), func(session s.Session) error {
+		count++
+		return stop
+	}))
+	require.Equal(t, 1, count)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.Equal(t, context.Canceled, p.ForEach(ctx, func(session s.Session) error { return nil }))
+}
+
+func TestProviderGetAllStored(t *testing.T) {
+	p := ProviderWithPrefixKey(redisOptions, "_stored_:")
+	p.Clear()
+	peer := ProviderWithPrefixKey(redisOptions, "_stored_:")
+	local := p.New(&s.Config{Valid: time.Minute}, nil)
+	remote := peer.New(&s.Config{Valid: time.Minute}, nil)
+
+	snapshot := p.GetAll()
+	delete(snapshot, local.Id())
+	require.True(t, p.Exists(local.Id()))
+	require.NotContains(t, p.GetAll(), remote.Id())
+
+	stored, err := p.GetAllStored(context.Background())
+	require.Nil(t, err)
+	require.Contains(t, stored, local.Id())
+	require.Contains(t, stored, remote.Id())
+	p.Del(local.Id())
+	p.Del(remote.Id())
+}
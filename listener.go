@@ -0,0 +1,86 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"net/http"
+	"time"
+
+	s "github.com/go-the-way/anoweb/session"
+)
+
+// Listener of rsn specific session events, complementing the anoweb session listener
+type Listener struct {
+	// FingerprintMismatch Listener
+	FingerprintMismatch func(session s.Session, r *http.Request)
+	// AboutToExpire Listener, fired once the ttl drops below the threshold of WithExpiryWarning
+	AboutToExpire func(session s.Session, ttl time.Duration)
+	// Set Listener, fired after named val was stored into session, e.g. to audit role changes
+	Set func(session s.Session, name string, val interface{})
+	// Del Listener, fired after named val was removed from session by Del or Clear
+	Del func(session s.Session, name string)
+	// Login Listener, fired once Login logged session in as userId
+	Login func(session s.Session, userId string)
+	// Logout Listener, fired once Logout deleted the session of userId
+	Logout func(session s.Session, userId string)
+	// UnknownId Listener, fired under FlagUnknown when r presents id, the id of no live session
+	UnknownId func(r *http.Request, id string)
+	// Fields restrict Set and Del to the named fields, all fields firing when empty
+	Fields []string
+}
+
+// watches report whether listener fires value events of name
+func (l *Listener) watches(name string) bool {
+	if len(l.Fields) == 0 {
+		return true
+	}
+	for _, field := range l.Fields {
+		if field == name {
+			return true
+		}
+	}
+	return false
+}
+
+// valuesSet fire the Set listener for the values just stored into currentSession, and publish the change
+func (p *provider) valuesSet(currentSession *session, values map[string]interface{}) {
+	if p.changeFeed {
+		names := make([]string, 0, len(values))
+		for name := range values {
+			names = append(names, name)
+		}
+		p.publishChange(currentSession.id, ChangeSet, names)
+	}
+	if p.listener == nil || p.listener.Set == nil {
+		return
+	}
+	for name, val := range values {
+		if p.listener.watches(name) {
+			name, val := name, val
+			p.dispatch(currentSession.id, func() { p.listener.Set(currentSession, name, val) })
+		}
+	}
+}
+
+// valuesDel fire the Del listener for the names just removed from currentSession, and publish the change
+func (p *provider) valuesDel(currentSession *session, names ...string) {
+	p.publishChange(currentSession.id, ChangeDel, names)
+	if p.listener == nil || p.listener.Del == nil {
+		return
+	}
+	for _, name := range names {
+		if p.listener.watches(name) {
+			name := name
+			p.dispatch(currentSession.id, func() { p.listener.Del(currentSession, name) })
+		}
+	}
+}
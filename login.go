@@ -0,0 +1,110 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	s "github.com/go-the-way/anoweb/session"
+)
+
+// ErrSessionNotCreated returned by Login when no session could be created, the client exceeding
+// the limit set by WithCreationRateLimit or redis failing. A veto of WithBeforeCreate is returned as is.
+var ErrSessionNotCreated = errors.New("rsn: session not created")
+
+// defaultLoginValid the idle timeout of sessions created by Login when none is set by WithValid
+const defaultLoginValid = 30 * time.Minute
+
+// loginConfig return the config of the sessions of Login and Logout
+func (p *provider) loginConfig() *s.Config {
+	if p.idleTimeout > 0 {
+		return &s.Config{Valid: p.idleTimeout}
+	}
+	return &s.Config{Valid: defaultLoginValid}
+}
+
+// Login log the client of r in as userId in one call: the session of the request is moved to a
+// new id against session fixation, or one is created, then bound to userId, marked authenticated
+// and given claims, its cookie is set on w and the Login listener of WithListener fires.
+// A session of the request bound to another user is destroyed rather than moved, so none of its
+// values, principal or authentication reach userId.
+// The session is valid for the idle timeout of WithValid, 30 minutes when unset.
+func (p *provider) Login(w http.ResponseWriter, r *http.Request, userId string, claims map[string]interface{}) (s.Session, error) {
+	config := p.loginConfig()
+	var currentSession s.Session
+	if id := p.GetId(r); id != "" {
+		existing := p.Get(id)
+		if rs, ok := existing.(*session); ok && rs.UserId() != "" && rs.UserId() != userId {
+			// the session of another user is never handed over, its values and principal included
+			p.destroy(id)
+			existing = nil
+		}
+		if existing != nil {
+			regenerated, err := p.Regenerate(existing)
+			if err != nil && err != ErrSessionNotFound {
+				return nil, err
+			}
+			currentSession = regenerated
+		}
+	}
+	if currentSession == nil {
+		p.mu.Lock()
+		listener := p.cleanListener
+		p.mu.Unlock()
+		created, err := p.newWithRequest(r, config, listener, false)
+		if err != nil {
+			return nil, err
+		}
+		currentSession = created
+	}
+	rs := currentSession.(*session)
+	if err := rs.BindUser(userId); err != nil {
+		return nil, err
+	}
+	if err := rs.Authenticate(); err != nil {
+		return nil, err
+	}
+	if err := rs.SetValues(claims, false); err != nil {
+		return nil, err
+	}
+	http.SetCookie(w, p.Cookie(rs, config))
+	setLegacyCookies(p, w, r)
+	if p.listener != nil && p.listener.Login != nil {
+		p.dispatch(rs.id, func() { p.listener.Login(rs, userId) })
+	}
+	return rs, nil
+}
+
+// Logout log the client of r out in one call: its session is deleted, firing the Logout listener
+// of WithListener, and its cookie cleared on w. A request without session is left as is.
+func (p *provider) Logout(w http.ResponseWriter, r *http.Request) error {
+	id := p.GetId(r)
+	if id == "" {
+		return nil
+	}
+	currentSession, userId := p.Get(id), ""
+	if rs, ok := currentSession.(*session); ok {
+		userId = rs.UserId()
+	}
+	existed, err := p.Delete(id)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, clearedCookie(p.CookieName()))
+	setLegacyCookies(p, w, r)
+	if existed && currentSession != nil && p.listener != nil && p.listener.Logout != nil {
+		p.dispatch(currentSession.Id(), func() { p.listener.Logout(currentSession, userId) })
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	s "github.com/go-the-way/anoweb/session"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProviderLoginLogout(t *testing.T) {
+	logins, logouts := make(chan string, 1), make(chan string, 1)
+	p := ProviderWithOptions(redisOptions, WithPrefixKey("_login_:"), WithListener(&Listener{
+		Login:  func(session s.Session, userId string) { logins <- userId },
+		Logout: func(session s.Session, userId string) { logouts <- userId },
+	}))
+
+	w := httptest.NewRecorder()
+	currSession, err := p.Login(w, httptest.NewRequest(http.MethodPost, "/login", nil), "alice", map[string]interface{}{"role": "admin"})
+	require.NoError(t, err)
+	require.Equal(t, "alice", <-logins)
+	require.Equal(t, "alice", currSession.(Session).UserId())
+	require.Equal(t, "admin", currSession.Get("role"))
+	require.NoError(t, currSession.(Session).RequireFresh())
+	cookie := w.Result().Cookies()[0]
+	require.Equal(t, currSession.Id(), cookie.Value)
+	ttl, err := currSession.(Session).TTL()
+	require.NoError(t, err)
+	require.True(t, ttl > time.Minute*29)
+
+	anonymous := p.New(&s.Config{Valid: time.Minute}, nil)
+	anonymous.Set("cart", "3")
+	req := httptest.NewRequest(http.MethodPost, "/login", nil)
+	req.AddCookie(&http.Cookie{Name: p.CookieName(), Value: anonymous.Id()})
+	w = httptest.NewRecorder()
+	loggedIn, err := p.Login(w, req, "bob", nil)
+	require.NoError(t, err)
+	require.Equal(t, "bob", <-logins)
+	require.NotEqual(t, anonymous.Id(), loggedIn.Id())
+	require.Equal(t, "3", loggedIn.Get("cart"))
+	require.False(t, p.client.Exists(p.getRedisKey(anonymous.Id())).Val() > 0)
+
+	req = httptest.NewRequest(http.MethodPost, "/logout", nil)
+	req.AddCookie(&http.Cookie{Name: p.CookieName(), Value: loggedIn.Id()})
+	w = httptest.NewRecorder()
+	require.NoError(t, p.Logout(w, req))
+	require.Equal(t, "bob", <-logouts)
+	require.True(t, loggedIn.Invalidated())
+	require.Equal(t, -1, w.Result().Cookies()[0].MaxAge)
+	require.NoError(t, p.Logout(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/logout", nil)))
+	p.Del(currSession.Id())
+}
+
+func TestProviderLoginOtherUser(t *testing.T) {
+	p := ProviderWithPrefixKey(redisOptions, "_login_other_:")
+	alice, err := p.Login(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/login", nil), "alice", map[string]interface{}{"role": "admin"})
+	require.NoError(t, err)
+	alice.Set("cart", "3")
+
+	req := httptest.NewRequest(http.MethodPost, "/login", nil)
+	req.AddCookie(&http.Cookie{Name: p.CookieName(), Value: alice.Id()})
+	bob, err := p.Login(httptest.NewRecorder(), req, "bob", nil)
+	require.NoError(t, err)
+	require.NotEqual(t, alice.Id(), bob.Id())
+	require.Equal(t, "bob", bob.(Session).UserId())
+	require.Nil(t, bob.Get("role"))
+	require.Nil(t, bob.Get("cart"))
+	require.True(t, alice.Invalidated())
+	require.False(t, p.client.Exists(p.getRedisKey(alice.Id())).Val() > 0)
+	sessions, err := p.SessionsByUser(context.Background(), "alice")
+	require.NoError(t, err)
+	require.Empty(t, sessions)
+	p.Del(bob.Id())
+}
@@ -0,0 +1,53 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import "time"
+
+// Maintenance pace the background work of the provider, the initial sync and the cleaning passes,
+// and the walks of the admin APIs, so it doesn't compete with request traffic on large installations.
+// Zero fields keep the defaults.
+type Maintenance struct {
+	// ScanCount the COUNT hint of SCAN, and the batch size of the walks, 100 by default
+	ScanCount int64
+	// PassLimit the most sessions loaded by the initial sync or expired by a cleaning pass,
+	// the rest being left to the next pass, unlimited by default. Sessions the sync leaves
+	// are read through by Get once asked for.
+	PassLimit int
+	// BatchPause the pause after each batch of the initial sync and of the cleaning passes
+	BatchPause time.Duration
+	// CleanInterval the pause between two cleaning passes, a minute by default
+	CleanInterval time.Duration
+}
+
+// scanCount return the COUNT hint of SCAN and the batch size of the walks
+func (p *provider) scanCount() int64 {
+	if p.maintenance.ScanCount > 0 {
+		return p.maintenance.ScanCount
+	}
+	return scanBatchSize
+}
+
+// cleanEvery return the pause between two cleaning passes
+func (p *provider) cleanEvery() time.Duration {
+	if p.maintenance.CleanInterval > 0 {
+		return p.maintenance.CleanInterval
+	}
+	return cleanInterval
+}
+
+// batchDone pause after the batch of n items ending a multiple of the scan count, as set by BatchPause
+func (p *provider) batchDone(n int) {
+	if p.maintenance.BatchPause > 0 && n > 0 && int64(n)%p.scanCount() == 0 {
+		time.Sleep(p.maintenance.BatchPause)
+	}
+}
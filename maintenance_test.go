@@ -0,0 +1,51 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"testing"
+	"time"
+
+	s "github.com/go-the-way/anoweb/session"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProviderMaintenanceDefaults(t *testing.T) {
+	p := ProviderWithPrefixKey(redisOptions, "_maintenance_defaults_:")
+	require.Equal(t, int64(scanBatchSize), p.scanCount())
+	require.Equal(t, cleanInterval, p.cleanEvery())
+	p = ProviderWithOptions(redisOptions, WithPrefixKey("_maintenance_defaults_:"),
+		WithMaintenance(Maintenance{ScanCount: 10, CleanInterval: time.Second}))
+	require.Equal(t, int64(10), p.scanCount())
+	require.Equal(t, time.Second, p.cleanEvery())
+}
+
+func TestProviderMaintenancePassLimit(t *testing.T) {
+	peer := ProviderWithPrefixKey(redisOptions, "_maintenance_limit_:")
+	ids := make([]string, 0, 3)
+	for i := 0; i < 3; i++ {
+		ids = append(ids, peer.New(&s.Config{Valid: time.Minute}, nil).Id())
+	}
+	defer func() {
+		for _, id := range ids {
+			peer.Del(id)
+		}
+	}()
+	p := ProviderWithOptions(redisOptions, WithPrefixKey("_maintenance_limit_:"),
+		WithMaintenance(Maintenance{ScanCount: 1, PassLimit: 2, BatchPause: time.Millisecond}))
+	require.Len(t, p.GetAll(), 2)
+	require.Len(t, p.expiredIds(time.Now().Add(time.Minute*2)), 2)
+	for _, id := range ids {
+		require.True(t, p.Exists(id))
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"context"
+	"sort"
+
+	r "github.com/go-redis/redis"
+)
+
+// SessionMemory the memory a session takes in redis, as reported by MemoryReport
+type SessionMemory struct {
+	Id string
+	// Bytes the memory used by the session hash, estimated by MEMORY USAGE
+	Bytes int64
+	// Fields the number of fields of the session, internal ones included
+	Fields int64
+}
+
+// MemoryReport return the topN sessions taking the most memory, heaviest first, to find the handlers
+// stuffing sessions with bloat. Every session is walked with SCAN and measured by MEMORY USAGE, which
+// samples the fields of large hashes, so sizes are estimates. It stops when ctx is done.
+func (p *provider) MemoryReport(ctx context.Context, topN int) ([]SessionMemory, error) {
+	client := p.scans().WithContext(ctx)
+	heaviest := make([]SessionMemory, 0, topN)
+	cursor := uint64(0)
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, wrapErr(err)
+		}
+		keys, next, err := client.Scan(cursor, p.getKeyPattern("*"), p.scanCount()).Result()
+		if err != nil {
+			return nil, wrapErr(err)
+		}
+		measured, err := p.measure(client, keys)
+		if err != nil {
+			return nil, wrapErr(err)
+		}
+		heaviest = append(heaviest, measured...)
+		sort.Slice(heaviest, func(i, j int) bool { return heaviest[i].Bytes > heaviest[j].Bytes })
+		if len(heaviest) > topN {
+			heaviest = heaviest[:topN]
+		}
+		if next == 0 {
+			return heaviest, nil
+		}
+		cursor = next
+	}
+}
+
+// measure return the memory used by the sessions of keys, skipping those gone meanwhile
+func (p *provider) measure(client *r.Client, keys []string) ([]SessionMemory, error) {
+	ids := make([]string, 0, len(keys))
+	usages := make([]*r.IntCmd, 0, len(keys))
+	fields := make([]*r.IntCmd, 0, len(keys))
+	_, err := client.Pipelined(func(pipe r.Pipeliner) error {
+		for _, key := range keys {
+			if id, own := p.ownId(key); own {
+				ids = append(ids, id)
+				usages = append(usages, pipe.MemoryUsage(key))
+				fields = append(fields, pipe.HLen(key))
+			}
+		}
+		return nil
+	})
+	if err != nil && err != r.Nil {
+		return nil, err
+	}
+	measured := make([]SessionMemory, 0, len(ids))
+	for i, id := range ids {
+		if usages[i].Err() == r.Nil || fields[i].Val() == 0 {
+			continue
+		}
+		measured = append(measured, SessionMemory{Id: id, Bytes: usages[i].Val(), Fields: fields[i].Val()})
+	}
+	return measured, nil
+}
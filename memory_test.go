@@ -0,0 +1,43 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	s "github.com/go-the-way/anoweb/session"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProviderMemoryReport(t *testing.T) {
+	p := ProviderWithPrefixKey(redisOptions, "_memory_:")
+	p.Clear()
+	small := p.New(&s.Config{Valid: time.Minute}, nil)
+	small.Set("apple", "100")
+	large := p.New(&s.Config{Valid: time.Minute}, nil)
+	large.Set("bloat", strings.Repeat("x", 4096))
+	large.Set("pear", "200")
+	report, err := p.MemoryReport(context.Background(), 1)
+	if err != nil && strings.Contains(strings.ToLower(err.Error()), "unknown") {
+		t.Skip("MEMORY USAGE is not supported by the test server")
+	}
+	require.NoError(t, err)
+	require.Len(t, report, 1)
+	require.Equal(t, large.Id(), report[0].Id)
+	require.True(t, report[0].Bytes > 4096)
+	require.Equal(t, int64(len(large.GetAll())), report[0].Fields)
+	p.Clear()
+}
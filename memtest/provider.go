@@ -0,0 +1,415 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package memtest provide an in-memory rsn.SessionProvider with a fake clock,
+// so unit tests of code using rsn need no redis.
+package memtest
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-the-way/rsn"
+
+	s "github.com/go-the-way/anoweb/session"
+)
+
+// Provider is an in-memory rsn.SessionProvider. Time only moves when Advance is called,
+// and sessions past their ttl are gone at once, as if redis expired them.
+type Provider struct {
+	mu       sync.Mutex
+	now      time.Time
+	sessions map[string]*session
+	listener *s.Listener
+}
+
+var _ rsn.SessionProvider = (*Provider)(nil)
+
+// New return new in-memory provider, its clock starting at the current time
+func New() *Provider {
+	return &Provider{now: time.Now(), sessions: map[string]*session{}}
+}
+
+// Now return the time of the fake clock
+func (p *Provider) Now() time.Time {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.now
+}
+
+// Advance move the fake clock forward by d, expiring the sessions whose ttl ran out
+// and firing the Invalidated and Destroyed listeners given to Clean
+func (p *Provider) Advance(d time.Duration) {
+	p.mu.Lock()
+	p.now = p.now.Add(d)
+	expired := make([]*session, 0)
+	for id, ms := range p.sessions {
+		if !ms.expiresAt.After(p.now) {
+			ms.invalidated = true
+			delete(p.sessions, id)
+			expired = append(expired, ms)
+		}
+	}
+	listener := p.listener
+	p.mu.Unlock()
+	for _, ms := range expired {
+		if listener != nil && listener.Invalidated != nil {
+			listener.Invalidated(ms)
+		}
+		if listener != nil && listener.Destroyed != nil {
+			listener.Destroyed(ms)
+		}
+	}
+}
+
+// live return the session of id unless it is missing or expired, p.mu held
+func (p *Provider) live(id string) *session {
+	ms, have := p.sessions[id]
+	if !have || !ms.expiresAt.After(p.now) {
+		return nil
+	}
+	return ms
+}
+
+// CookieName return cookie name
+func (p *Provider) CookieName() string {
+	return "GOSESSID"
+}
+
+// GetId get session id
+func (p *Provider) GetId(r *http.Request) string {
+	cookie, err := r.Cookie(p.CookieName())
+	if err == nil && cookie != nil {
+		return cookie.Value
+	}
+	return ""
+}
+
+// Exists session
+func (p *Provider) Exists(id string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ms := p.live(id)
+	return ms != nil && !ms.invalidated
+}
+
+// Get session
+func (p *Provider) Get(id string) s.Session {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if ms := p.live(id); ms != nil {
+		return ms
+	}
+	return nil
+}
+
+// Del session
+func (p *Provider) Del(id string) {
+	_, _ = p.Delete(id)
+}
+
+// Delete delete session id and report whether it was live, firing the listeners given to Clean
+func (p *Provider) Delete(id string) (bool, error) {
+	p.mu.Lock()
+	ms := p.live(id)
+	delete(p.sessions, id)
+	if ms != nil {
+		ms.invalidated = true
+	}
+	listener := p.listener
+	p.mu.Unlock()
+	if ms == nil {
+		return false, nil
+	}
+	if listener != nil && listener.Invalidated != nil {
+		listener.Invalidated(ms)
+	}
+	if listener != nil && listener.Destroyed != nil {
+		listener.Destroyed(ms)
+	}
+	return true, nil
+}
+
+// GetAll return the live sessions
+func (p *Provider) GetAll() map[string]s.Session {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	sessions := make(map[string]s.Session, len(p.sessions))
+	for id := range p.sessions {
+		if ms := p.live(id); ms != nil {
+			sessions[id] = ms
+		}
+	}
+	return sessions
+}
+
+// Clear sessions
+func (p *Provider) Clear() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.sessions = map[string]*session{}
+}
+
+// New return new session
+func (p *Provider) New(config *s.Config, listener *s.Listener) s.Session {
+	return p.create(config, listener, rsn.Meta{})
+}
+
+// NewWithRequest return new session recording the ip and user agent of r
+func (p *Provider) NewWithRequest(r *http.Request, config *s.Config, listener *s.Listener) s.Session {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	return p.create(config, listener, rsn.Meta{IP: ip, UserAgent: r.UserAgent()})
+}
+
+// NewFromRequest return the live session of the cookie of r, or a new one recording the ip and user agent of r,
+// with the cookie to set on the response
+func (p *Provider) NewFromRequest(r *http.Request, config *s.Config, listener *s.Listener) (s.Session, *http.Cookie, error) {
+	currentSession, _ := p.GetOrCreate(r, config, listener)
+	return currentSession, p.Cookie(currentSession, config), nil
+}
+
+// GetOrCreate return the live session of the cookie of r, or a new one recording the ip and user agent of r
+func (p *Provider) GetOrCreate(r *http.Request, config *s.Config, listener *s.Listener) (s.Session, error) {
+	if currentSession := p.Get(p.GetId(r)); currentSession != nil {
+		return currentSession, nil
+	}
+	return p.NewWithRequest(r, config, listener), nil
+}
+
+// Load return the live session of the cookie of r, nil if none
+func (p *Provider) Load(r *http.Request) (s.Session, error) {
+	return p.Get(p.GetId(r)), nil
+}
+
+// LoadId return the live session of id, nil if none
+func (p *Provider) LoadId(_ *http.Request, id string) (s.Session, error) {
+	if !p.ValidId(id) {
+		return nil, nil
+	}
+	return p.Get(id), nil
+}
+
+// ValidId report whether id isn't empty, ids aren't checked further in memory
+func (p *Provider) ValidId(id string) bool {
+	return id != ""
+}
+
+func (p *Provider) create(config *s.Config, listener *s.Listener, meta rsn.Meta) s.Session {
+	p.mu.Lock()
+	ms := &session{
+		provider:   p,
+		id:         newId(),
+		values:     map[string]interface{}{},
+		meta:       meta,
+		createdAt:  p.now,
+		accessedAt: p.now,
+		lifeTime:   config.Valid,
+		expiresAt:  p.now.Add(config.Valid),
+	}
+	p.sessions[ms.id] = ms
+	p.mu.Unlock()
+	if listener != nil && listener.Created != nil {
+		listener.Created(ms)
+	}
+	return ms
+}
+
+// Refresh session
+func (p *Provider) Refresh(session s.Session, config *s.Config, listener *s.Listener) {
+	p.mu.Lock()
+	ms := p.live(session.Id())
+	if ms != nil {
+		ms.accessedAt = p.now
+		ms.expiresAt = p.now.Add(config.Valid)
+	}
+	p.mu.Unlock()
+	if ms == nil {
+		session.Invalidate()
+		return
+	}
+	if listener != nil && listener.Refreshed != nil {
+		listener.Refreshed(ms)
+	}
+}
+
+// Clean keep listener for the sessions expired by Advance
+func (p *Provider) Clean(_ *s.Config, listener *s.Listener) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.listener = listener
+}
+
+// Cookie return the session cookie to set on the response
+func (p *Provider) Cookie(session s.Session, config *s.Config) *http.Cookie {
+	return &http.Cookie{
+		Name:     p.CookieName(),
+		Value:    session.Id(),
+		Path:     "/",
+		Expires:  p.Now().Add(config.Valid),
+		MaxAge:   int(config.Valid / time.Second),
+		HttpOnly: true,
+	}
+}
+
+// LegacyCookies return no cookies, memtest has no legacy cookie names
+func (p *Provider) LegacyCookies(_ *http.Request) []*http.Cookie {
+	return []*http.Cookie{}
+}
+
+// Regenerate move session to a new id, dropping the old one at once
+func (p *Provider) Regenerate(session s.Session) (s.Session, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ms := p.live(session.Id())
+	if ms == nil {
+		return nil, rsn.ErrSessionNotFound
+	}
+	delete(p.sessions, ms.id)
+	ms.lineage = append(ms.lineage, ms.id)
+	ms.id = newId()
+	p.sessions[ms.id] = ms
+	return ms, nil
+}
+
+// Restore always fail, as the in-memory provider has no soft delete
+func (p *Provider) Restore(string) error {
+	return rsn.ErrSessionNotFound
+}
+
+// Find return the sessions whose field holds value
+func (p *Provider) Find(_ context.Context, field, value string) ([]s.Session, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	found := make([]s.Session, 0)
+	for _, id := range p.ids() {
+		ms := p.sessions[id]
+		if val, have := ms.values[field]; have && fmt.Sprint(val) == value {
+			found = append(found, ms)
+		}
+	}
+	return found, nil
+}
+
+// ids return the sorted ids of the live sessions, p.mu held
+func (p *Provider) ids() []string {
+	ids := make([]string, 0, len(p.sessions))
+	for id := range p.sessions {
+		if p.live(id) != nil {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// List return a page of at most limit session ids starting at cursor, the cursor being an offset
+func (p *Provider) List(_ context.Context, cursor uint64, limit int64) ([]string, uint64, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ids := p.ids()
+	if cursor >= uint64(len(ids)) {
+		return []string{}, 0, nil
+	}
+	end := cursor + uint64(limit)
+	if limit <= 0 || end >= uint64(len(ids)) {
+		return ids[cursor:], 0, nil
+	}
+	return ids[cursor:end], end, nil
+}
+
+// ForEach call fn with every session, stopping at the first error or when ctx is done
+func (p *Provider) ForEach(ctx context.Context, fn func(s.Session) error) error {
+	for _, currentSession := range p.GetAll() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(currentSession); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Count return the number of live sessions
+func (p *Provider) Count(context.Context) (int64, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return int64(len(p.ids())), nil
+}
+
+// userSessions return the live sessions bound to userId, p.mu held
+func (p *Provider) userSessions(userId string) []*session {
+	sessions := make([]*session, 0)
+	for _, id := range p.ids() {
+		if ms := p.sessions[id]; ms.userId == userId {
+			sessions = append(sessions, ms)
+		}
+	}
+	return sessions
+}
+
+// SessionsByUser describe the live sessions of userId, most recently accessed first
+func (p *Provider) SessionsByUser(_ context.Context, userId string) ([]rsn.SessionInfo, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	infos := make([]rsn.SessionInfo, 0)
+	for _, ms := range p.userSessions(userId) {
+		infos = append(infos, rsn.SessionInfo{
+			Id:             ms.id,
+			CreatedAt:      ms.createdAt,
+			LastAccessedAt: ms.accessedAt,
+			IP:             ms.meta.IP,
+			UserAgent:      ms.meta.UserAgent,
+			Device:         ms.meta.Device,
+		})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].LastAccessedAt.After(infos[j].LastAccessedAt) })
+	return infos, nil
+}
+
+// InvalidateUser delete every session of userId
+func (p *Provider) InvalidateUser(_ context.Context, userId string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, ms := range p.userSessions(userId) {
+		ms.invalidated = true
+		delete(p.sessions, ms.id)
+	}
+	return nil
+}
+
+// Purge delete every session of userId, reporting their ids
+func (p *Provider) Purge(_ context.Context, userId string) (*rsn.PurgeReport, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	report := &rsn.PurgeReport{UserId: userId, Sessions: []string{}, Tombstones: []string{}}
+	for _, ms := range p.userSessions(userId) {
+		ms.invalidated = true
+		delete(p.sessions, ms.id)
+		report.Sessions = append(report.Sessions, ms.id)
+	}
+	return report, nil
+}
+
+func newId() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return fmt.Sprintf("%X", b)
+}
@@ -0,0 +1,151 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memtest
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/go-the-way/rsn"
+
+	s "github.com/go-the-way/anoweb/session"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProviderExpiry(t *testing.T) {
+	p := New()
+	destroyed := make([]string, 0)
+	p.Clean(nil, &s.Listener{Destroyed: func(session s.Session) { destroyed = append(destroyed, session.Id()) }})
+	currSession := p.New(&s.Config{Valid: time.Minute}, nil).(rsn.Session)
+	currSession.Set("apple", "100")
+	require.True(t, p.Exists(currSession.Id()))
+	require.Equal(t, "100", p.Get(currSession.Id()).Get("apple"))
+
+	p.Advance(time.Second * 40)
+	ttl, err := currSession.TTL()
+	require.Nil(t, err)
+	require.Equal(t, time.Second*20, ttl)
+	require.Nil(t, currSession.Touch())
+	ttl, _ = currSession.TTL()
+	require.Equal(t, time.Minute, ttl)
+
+	p.Refresh(currSession, &s.Config{Valid: time.Minute * 2}, nil)
+	p.Advance(time.Minute * 2)
+	require.False(t, p.Exists(currSession.Id()))
+	require.Equal(t, []string{currSession.Id()}, destroyed)
+	_, err = currSession.TTL()
+	require.Equal(t, rsn.ErrSessionNotFound, err)
+}
+
+func TestProviderUsers(t *testing.T) {
+	p := New()
+	req, _ := http.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	first := p.NewWithRequest(req, &s.Config{Valid: time.Minute}, nil).(rsn.Session)
+	second := p.New(&s.Config{Valid: time.Minute}, nil).(rsn.Session)
+	require.Equal(t, "10.0.0.1", first.Meta().IP)
+	require.Nil(t, first.BindUser("alice"))
+	require.Nil(t, second.BindUser("alice"))
+	second.Set("role", "admin")
+
+	infos, err := p.SessionsByUser(context.Background(), "alice")
+	require.Nil(t, err)
+	require.Len(t, infos, 2)
+	found, err := p.Find(context.Background(), "role", "admin")
+	require.Nil(t, err)
+	require.Len(t, found, 1)
+
+	regenerated, err := p.Regenerate(second)
+	require.Nil(t, err)
+	lineage, _ := regenerated.(rsn.Session).Lineage()
+	require.Len(t, lineage, 1)
+
+	count, _ := p.Count(context.Background())
+	require.Equal(t, int64(2), count)
+	report, err := p.Purge(context.Background(), "alice")
+	require.Nil(t, err)
+	require.Len(t, report.Sessions, 2)
+	count, _ = p.Count(context.Background())
+	require.Equal(t, int64(0), count)
+}
+
+func TestSessionMerge(t *testing.T) {
+	p := New()
+	currSession := p.New(&s.Config{Valid: time.Minute}, nil).(rsn.Session)
+	currSession.SetAll(map[string]interface{}{"count": 3, "gone": "x"}, false)
+	changed, removed := currSession.Diff(map[string]interface{}{"count": 3, "name": "b"})
+	require.Equal(t, map[string]interface{}{"name": "b"}, changed)
+	require.Equal(t, []string{"gone"}, removed)
+	require.Nil(t, currSession.Merge(map[string]interface{}{"count": 3, "name": "b"}))
+	require.Equal(t, map[string]interface{}{"count": 3, "name": "b"}, currSession.GetAll())
+}
+
+func TestSessionSnapshot(t *testing.T) {
+	p := New()
+	currSession := p.New(&s.Config{Valid: time.Minute}, nil).(rsn.Session)
+	currSession.Set("count", 3)
+	snap, err := currSession.Snapshot()
+	require.Nil(t, err)
+	require.Equal(t, "3", snap.Get("count"))
+	require.Equal(t, time.Minute, snap.TTL())
+}
+
+func TestSessionSetWithTTL(t *testing.T) {
+	p := New()
+	currSession := p.New(&s.Config{Valid: time.Hour}, nil).(rsn.Session)
+	require.NoError(t, currSession.SetWithTTL("otp", "123456", time.Minute))
+	currSession.Set("name", "kept")
+	p.Advance(time.Second * 30)
+	require.Equal(t, "123456", currSession.Get("otp"))
+	p.Advance(time.Second * 30)
+	require.Nil(t, currSession.Get("otp"))
+	require.Equal(t, map[string]interface{}{"name": "kept"}, currSession.GetAll())
+}
+
+func TestSessionStepUp(t *testing.T) {
+	p := New()
+	currSession := p.New(&s.Config{Valid: time.Hour}, nil).(rsn.Session)
+	require.NoError(t, currSession.Elevate(rsn.AuthSecondFactor, time.Minute))
+	require.Equal(t, rsn.AuthSecondFactor, currSession.AuthLevel())
+	require.NoError(t, currSession.RequireLevel(rsn.AuthPassword))
+	p.Advance(time.Minute)
+	require.Equal(t, rsn.ErrStepUpRequired, currSession.RequireLevel(rsn.AuthPassword))
+}
+
+func TestSessionPrincipal(t *testing.T) {
+	p := New()
+	currSession := p.New(&s.Config{Valid: time.Hour}, nil).(rsn.Session)
+	require.NoError(t, currSession.SetPrincipal(rsn.Principal{ID: "alice", Roles: []string{"admin"}}))
+	require.Equal(t, "alice", currSession.UserId())
+	require.True(t, currSession.HasRole("admin"))
+	require.False(t, currSession.HasRole("owner"))
+}
+
+func TestProviderNewFromRequest(t *testing.T) {
+	p := New()
+	config := &s.Config{Valid: time.Minute}
+	req, _ := http.NewRequest(http.MethodGet, "/", nil)
+	loaded, err := p.Load(req)
+	require.Nil(t, err)
+	require.Nil(t, loaded)
+	currSession, cookie, err := p.NewFromRequest(req, config, nil)
+	require.Nil(t, err)
+	require.Equal(t, currSession.Id(), cookie.Value)
+	req.AddCookie(cookie)
+	again, _, _ := p.NewFromRequest(req, config, nil)
+	require.Equal(t, currSession.Id(), again.Id())
+	loaded, _ = p.Load(req)
+	require.Equal(t, currSession.Id(), loaded.Id())
+}
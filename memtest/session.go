@@ -0,0 +1,385 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memtest
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"time"
+
+	"github.com/go-the-way/rsn"
+)
+
+type session struct {
+	provider      *Provider
+	id            string
+	values        map[string]interface{}
+	userId        string
+	meta          rsn.Meta
+	createdAt     time.Time
+	accessedAt    time.Time
+	lifeTime      time.Duration
+	expiresAt     time.Time
+	invalidated   bool
+	remembered    bool
+	authenticated time.Time
+	elevations    map[rsn.AuthLevel]time.Time
+	principal     *rsn.Principal
+	lineage       []string
+	fieldExpiry   map[string]time.Time
+}
+
+var _ rsn.Session = (*session)(nil)
+
+// do call fn with the provider lock held, once the fields due to expire are dropped
+func (ms *session) do(fn func()) {
+	ms.provider.mu.Lock()
+	defer ms.provider.mu.Unlock()
+	for name, expiresAt := range ms.fieldExpiry {
+		if !ms.provider.now.Before(expiresAt) {
+			delete(ms.values, name)
+			delete(ms.fieldExpiry, name)
+		}
+	}
+	fn()
+}
+
+// gone report whether session expired or was deleted, the provider lock held
+func (ms *session) gone() bool {
+	return ms.provider.live(ms.id) != ms
+}
+
+// Id return session id
+func (ms *session) Id() string {
+	return ms.id
+}
+
+// Renew session
+func (ms *session) Renew(lifeTime time.Duration) {
+	ms.do(func() {
+		ms.lifeTime = lifeTime
+		ms.expiresAt = ms.provider.now.Add(lifeTime)
+	})
+}
+
+// Invalidate session, deleting it from the provider
+func (ms *session) Invalidate() {
+	ms.do(func() {
+		ms.invalidated = true
+		if ms.provider.sessions[ms.id] == ms {
+			delete(ms.provider.sessions, ms.id)
+		}
+	})
+}
+
+// Invalidated session
+func (ms *session) Invalidated() bool {
+	invalidated := false
+	ms.do(func() { invalidated = ms.invalidated })
+	return invalidated
+}
+
+// Get session named val
+func (ms *session) Get(name string) interface{} {
+	var val interface{}
+	ms.do(func() { val = ms.values[name] })
+	return val
+}
+
+// GetAll session's values
+func (ms *session) GetAll() map[string]interface{} {
+	values := map[string]interface{}{}
+	ms.do(func() {
+		for k, v := range ms.values {
+			values[k] = v
+		}
+	})
+	return values
+}
+
+// Set named val into session
+func (ms *session) Set(name string, val interface{}) {
+	_ = ms.SetValue(name, val)
+}
+
+// SetAll values into session
+func (ms *session) SetAll(data map[string]interface{}, flush bool) {
+	_ = ms.SetValues(data, flush)
+}
+
+// SetValue set named val
+func (ms *session) SetValue(name string, val interface{}) error {
+	return ms.SetValues(map[string]interface{}{name: val}, false)
+}
+
+// SetValues set data into session, clearing its values first if flush
+func (ms *session) SetValues(data map[string]interface{}, flush bool) error {
+	ms.do(func() {
+		if flush {
+			ms.values = map[string]interface{}{}
+		}
+		for k, v := range data {
+			ms.values[k] = v
+			delete(ms.fieldExpiry, k)
+		}
+	})
+	return nil
+}
+
+// SyncSet set named val, writes never being queued in memory
+func (ms *session) SyncSet(name string, val interface{}) error {
+	return ms.SetValue(name, val)
+}
+
+// SetDurable set named val, there being no replica to wait for in memory
+func (ms *session) SetDurable(name string, val interface{}, _ int, _ time.Duration) error {
+	return ms.SetValue(name, val)
+}
+
+// SetWithTTL set named val, dropping it once ttl elapsed on the provider clock
+func (ms *session) SetWithTTL(name string, val interface{}, ttl time.Duration) error {
+	_ = ms.SetValue(name, val)
+	if ttl > 0 {
+		ms.do(func() {
+			if ms.fieldExpiry == nil {
+				ms.fieldExpiry = map[string]time.Time{}
+			}
+			ms.fieldExpiry[name] = ms.provider.now.Add(ttl)
+		})
+	}
+	return nil
+}
+
+// Diff return the fields to set and to delete for session to hold exactly other
+func (ms *session) Diff(other map[string]interface{}) (map[string]interface{}, []string) {
+	changed := map[string]interface{}{}
+	removed := make([]string, 0)
+	ms.do(func() {
+		for name, val := range other {
+			if stored, have := ms.values[name]; !have || !reflect.DeepEqual(stored, val) {
+				changed[name] = val
+			}
+		}
+		for name := range ms.values {
+			if _, have := other[name]; !have {
+				removed = append(removed, name)
+			}
+		}
+	})
+	return changed, removed
+}
+
+// Merge make session hold exactly data
+func (ms *session) Merge(data map[string]interface{}) error {
+	changed, removed := ms.Diff(data)
+	ms.do(func() {
+		for name, val := range changed {
+			ms.values[name] = val
+		}
+		for _, name := range removed {
+			delete(ms.values, name)
+		}
+	})
+	return nil
+}
+
+// Snapshot return a copy of session with its ttl, its values formatted as strings
+func (ms *session) Snapshot() (snap *rsn.Snapshot, err error) {
+	ms.do(func() {
+		if ms.gone() {
+			err = rsn.ErrSessionNotFound
+			return
+		}
+		values := make(map[string]string, len(ms.values))
+		for k, v := range ms.values {
+			values[k] = fmt.Sprint(v)
+		}
+		snap = rsn.NewSnapshot(ms.id, values, ms.expiresAt.Sub(ms.provider.now))
+	})
+	return
+}
+
+// ReadOnly return a view of session refusing writes
+func (ms *session) ReadOnly() rsn.Session {
+	return rsn.ReadOnly(ms)
+}
+
+// Del named val from session
+func (ms *session) Del(name string) {
+	ms.do(func() { delete(ms.values, name) })
+}
+
+// Clear session's values
+func (ms *session) Clear() {
+	ms.do(func() { ms.values = map[string]interface{}{} })
+}
+
+// BindUser bind session to userId
+func (ms *session) BindUser(userId string) error {
+	ms.do(func() { ms.userId = userId })
+	return nil
+}
+
+// SetPrincipal store principal, binding session to its ID when not empty
+func (ms *session) SetPrincipal(principal rsn.Principal) error {
+	ms.do(func() {
+		if principal.ID != "" {
+			ms.userId = principal.ID
+		}
+		principal.Roles = append([]string(nil), principal.Roles...)
+		ms.principal = &principal
+	})
+	return nil
+}
+
+// Principal return a copy of the stored principal, nil when none
+func (ms *session) Principal() *rsn.Principal {
+	var principal *rsn.Principal
+	ms.do(func() {
+		if ms.principal != nil {
+			principal = &rsn.Principal{ID: ms.principal.ID, Roles: append([]string(nil), ms.principal.Roles...)}
+		}
+	})
+	return principal
+}
+
+// HasRole report whether the principal holds role
+func (ms *session) HasRole(role string) bool {
+	return ms.Principal().HasRole(role)
+}
+
+// UserId return bound user id
+func (ms *session) UserId() string {
+	userId := ""
+	ms.do(func() { userId = ms.userId })
+	return userId
+}
+
+// Meta return the device metadata recorded by NewWithRequest
+func (ms *session) Meta() rsn.Meta {
+	return ms.meta
+}
+
+// Validate accept every request, as the in-memory provider binds sessions to no client
+func (ms *session) Validate(*http.Request) error {
+	return nil
+}
+
+// TTL return the remaining time to live by the fake clock
+func (ms *session) TTL() (ttl time.Duration, err error) {
+	ms.do(func() {
+		if ms.gone() {
+			err = rsn.ErrSessionNotFound
+			return
+		}
+		ttl = ms.expiresAt.Sub(ms.provider.now)
+	})
+	return
+}
+
+// Touch mark session accessed, extending its ttl to the full lifetime once less than half of it remains
+func (ms *session) Touch() (err error) {
+	ms.do(func() {
+		if ms.gone() {
+			err = rsn.ErrSessionNotFound
+			return
+		}
+		now := ms.provider.now
+		ms.accessedAt = now
+		if ms.expiresAt.Sub(now) < ms.lifeTime/2 {
+			ms.expiresAt = now.Add(ms.lifeTime)
+		}
+	})
+	return
+}
+
+// Remember mark session remembered
+func (ms *session) Remember() error {
+	ms.do(func() { ms.remembered = true })
+	return nil
+}
+
+// Remembered report whether session is remembered
+func (ms *session) Remembered() bool {
+	remembered := false
+	ms.do(func() { remembered = ms.remembered })
+	return remembered
+}
+
+// Authenticate record that the user just proved their identity
+func (ms *session) Authenticate() error {
+	ms.do(func() { ms.authenticated = ms.provider.now })
+	return nil
+}
+
+// Elevate record that the user authenticated at level, granted for valid on the provider clock
+func (ms *session) Elevate(level rsn.AuthLevel, valid time.Duration) error {
+	ms.do(func() {
+		if ms.elevations == nil {
+			ms.elevations = map[rsn.AuthLevel]time.Time{}
+		}
+		ms.elevations[level] = ms.provider.now.Add(valid)
+	})
+	return nil
+}
+
+// ElevatedUntil return when level or a higher one stops being granted, zero time when it isn't
+func (ms *session) ElevatedUntil(level rsn.AuthLevel) time.Time {
+	until := time.Time{}
+	ms.do(func() {
+		for granted, grantedUntil := range ms.elevations {
+			if granted >= level && grantedUntil.After(until) && grantedUntil.After(ms.provider.now) {
+				until = grantedUntil
+			}
+		}
+	})
+	return until
+}
+
+// AuthLevel return the highest level granted now
+func (ms *session) AuthLevel() rsn.AuthLevel {
+	highest := rsn.AuthNone
+	ms.do(func() {
+		for level, until := range ms.elevations {
+			if level > highest && until.After(ms.provider.now) {
+				highest = level
+			}
+		}
+	})
+	return highest
+}
+
+// RequireLevel return rsn.ErrStepUpRequired unless level or a higher one is granted now
+func (ms *session) RequireLevel(level rsn.AuthLevel) error {
+	if level <= rsn.AuthNone || !ms.ElevatedUntil(level).IsZero() {
+		return nil
+	}
+	return rsn.ErrStepUpRequired
+}
+
+// RequireFresh return rsn.ErrReauthenticationRequired for a remembered session never authenticated
+func (ms *session) RequireFresh() (err error) {
+	ms.do(func() {
+		if ms.remembered && ms.authenticated.IsZero() {
+			err = rsn.ErrReauthenticationRequired
+		}
+	})
+	return
+}
+
+// Lineage return the ids session had before being regenerated, oldest first
+func (ms *session) Lineage() ([]string, error) {
+	lineage := make([]string, 0)
+	ms.do(func() { lineage = append(lineage, ms.lineage...) })
+	return lineage, nil
+}
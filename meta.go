@@ -0,0 +1,192 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sync/atomic"
+
+	s "github.com/go-the-way/anoweb/session"
+)
+
+// Meta is the device metadata of a session
+type Meta struct {
+	IP        string
+	UserAgent string
+	Device    string
+}
+
+// BeforeCreate decide whether a session may be created for r, returning an error to veto it,
+// e.g. for health checks or clients from blocked countries. fields may be filled with the initial
+// fields of the session, set before the Created listener fires.
+type BeforeCreate func(r *http.Request, fields map[string]interface{}) error
+
+// NewWithRequest return new session created for r.
+//
+// With WithMetaCapture the client ip, user agent and device label of r are
+// stored in the session before the Created listener fires, and with WithIPBinding
+// and WithFingerprint the session is bound to the client network and fingerprint.
+// nil is returned when the client exceeds the limit set by WithCreationRateLimit,
+// or when the hook of WithBeforeCreate vetoes the session.
+func (p *provider) NewWithRequest(r *http.Request, config *s.Config, listener *s.Listener) s.Session {
+	currentSession, _ := p.newWithRequest(r, config, listener, false)
+	return currentSession
+}
+
+// NewFromRequest return the session of the cookie of r when it is still valid for r, or else a new
+// session created for r recording its client ip and user agent, along with the cookie to set on the
+// response, so repeated calls for one client don't pile up sessions. The veto of WithBeforeCreate,
+// ErrUnknownSession under RejectUnknown or ErrSessionNotCreated is returned when no session is created.
+func (p *provider) NewFromRequest(r *http.Request, config *s.Config, listener *s.Listener) (s.Session, *http.Cookie, error) {
+	existing, err := p.Load(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	if existing != nil {
+		return existing, p.Cookie(existing, config), nil
+	}
+	currentSession, err := p.newWithRequest(r, config, listener, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	return currentSession, p.Cookie(currentSession, config), nil
+}
+
+// GetOrCreate return the session of the cookie of r when it is still valid for r, or else a new
+// session created for r as NewWithRequest does. The veto of WithBeforeCreate, ErrUnknownSession
+// under RejectUnknown or ErrSessionNotCreated is returned when no session is created.
+func (p *provider) GetOrCreate(r *http.Request, config *s.Config, listener *s.Listener) (s.Session, error) {
+	existing, err := p.Load(r)
+	if existing != nil || err != nil {
+		return existing, err
+	}
+	return p.newWithRequest(r, config, listener, false)
+}
+
+// Load return the live session of the cookie of r passing Validate, nil if none, without creating one,
+// or ErrUnknownSession when r presents the id of no live session under RejectUnknown.
+// A mismatch under FlagMismatch keeps the session, one under RejectMismatch drops it,
+// and the other errors of Validate, such as redis failing, are returned.
+func (p *provider) Load(r *http.Request) (s.Session, error) {
+	id := p.GetId(r)
+	if id == "" {
+		return nil, nil
+	}
+	return p.loadId(r, id)
+}
+
+// LoadId return the live session of id passing Validate for r as Load does, for an id read elsewhere
+// than from the cookie of r, such as gRPC metadata. A malformed id is rejected as GetId does.
+func (p *provider) LoadId(r *http.Request, id string) (s.Session, error) {
+	if id == "" {
+		return nil, nil
+	}
+	if !p.validId(id) {
+		atomic.AddUint64(&p.rejectedIds, 1)
+		return nil, nil
+	}
+	return p.loadId(r, id)
+}
+
+// loadId return the live session of the well-formed id passing Validate for r
+func (p *provider) loadId(r *http.Request, id string) (s.Session, error) {
+	existing, ok := p.Get(id).(*session)
+	if !ok || existing.Invalidated() {
+		return nil, p.unknownId(r, id)
+	}
+	if err := existing.Validate(r); err != nil {
+		mode, mismatch := p.mismatchMode(err)
+		switch {
+		case mismatch && mode == FlagMismatch:
+			return existing, nil
+		case mismatch || err == ErrSessionNotFound:
+			return nil, nil
+		}
+		return nil, err
+	}
+	return existing, nil
+}
+
+// newWithRequest return new session created for r, recording its metadata when meta or WithMetaCapture,
+// or the error of the hook of WithBeforeCreate vetoing it, ErrSessionCapReached past the cap of WithMaxSessions,
+// ErrSessionNotCreated when it couldn't be created otherwise
+func (p *provider) newWithRequest(r *http.Request, config *s.Config, listener *s.Listener, meta bool) (s.Session, error) {
+	if !p.allowCreate(p.clientIP(r)) {
+		return nil, ErrSessionNotCreated
+	}
+	if p.sessionCapReached() {
+		return nil, ErrSessionCapReached
+	}
+	fields := map[string]interface{}{}
+	if p.beforeCreate != nil {
+		if err := p.beforeCreate(r, fields); err != nil {
+			return nil, err
+		}
+		for name := range fields {
+			if p.reserved(name) {
+				return nil, ErrReservedField
+			}
+		}
+	}
+	if p.captureMeta || meta {
+		fields[p.field(ipName)] = p.clientIP(r)
+		fields[p.field(userAgentName)] = r.UserAgent()
+		if p.deviceLabel != nil {
+			fields[p.field(deviceName)] = p.deviceLabel(r)
+		}
+	}
+	if p.ipBinding != nil {
+		fields[p.field(boundNetworkName)] = p.ipBinding.network(p.clientIP(r))
+	}
+	if p.fingerprintBinding != nil {
+		fields[p.field(fingerprintName)] = p.fingerprintBinding.fingerprint(r)
+	}
+	if currentSession := p.create(p.newSID(), config, listener, fields); currentSession != nil {
+		return currentSession, nil
+	}
+	return nil, ErrSessionNotCreated
+}
+
+// clientIP return the ip of the client sending r
+func (p *provider) clientIP(r *http.Request) string {
+	if p.clientIPFunc != nil {
+		return p.clientIPFunc(r)
+	}
+	return remoteIP(r)
+}
+
+// remoteIP return the ip of the remote address of r
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// Meta return the device metadata captured at creation
+func (s *session) Meta() Meta {
+	p := s.provider
+	vals, err := s.reader().HMGet(s.key, p.field(ipName), p.field(userAgentName), p.field(deviceName)).Result()
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		return Meta{}
+	}
+	return Meta{
+		IP:        stringOf(vals[0]),
+		UserAgent: stringOf(vals[1]),
+		Device:    stringOf(vals[2]),
+	}
+}
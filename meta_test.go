@@ -0,0 +1,157 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	s "github.com/go-the-way/anoweb/session"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProviderNewWithRequest(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "10.0.0.1:5000"
+	req.Header.Set("User-Agent", "rsn-test")
+	config := &s.Config{Valid: time.Minute}
+	{
+		p := ProviderWithOptions(redisOptions, WithPrefixKey("_meta_:"), WithMetaCapture(func(r *http.Request) string {
+			return "laptop"
+		}))
+		currSession := p.NewWithRequest(req, config, nil).(Session)
+		require.Equal(t, Meta{IP: "10.0.0.1", UserAgent: "rsn-test", Device: "laptop"}, currSession.Meta())
+
+		require.Nil(t, currSession.BindUser("50"))
+		infos, err := p.SessionsByUser(context.Background(), "50")
+		require.Nil(t, err)
+		require.Equal(t, "10.0.0.1", infos[0].IP)
+		require.Equal(t, "rsn-test", infos[0].UserAgent)
+		require.Equal(t, "laptop", infos[0].Device)
+		p.Del(currSession.Id())
+	}
+	{
+		p := ProviderWithPrefixKey(redisOptions, "_meta_:")
+		currSession := p.NewWithRequest(req, config, nil).(Session)
+		require.Equal(t, Meta{}, currSession.Meta())
+		p.Del(currSession.Id())
+	}
+}
+
+func TestProviderBeforeCreate(t *testing.T) {
+	errHealthCheck := errors.New("health check")
+	p := ProviderWithOptions(redisOptions, WithPrefixKey("_before_create_:"), WithBeforeCreate(func(r *http.Request, fields map[string]interface{}) error {
+		if r.UserAgent() == "kube-probe" {
+			return errHealthCheck
+		}
+		fields["country"] = r.Header.Get("X-Country")
+		return nil
+	}))
+	config := &s.Config{Valid: time.Minute}
+	req, _ := http.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Country", "NL")
+	currSession := p.NewWithRequest(req, config, nil)
+	require.NotNil(t, currSession)
+	require.Equal(t, "NL", currSession.Get("country"))
+	p.Del(currSession.Id())
+
+	req.Header.Set("User-Agent", "kube-probe")
+	require.Nil(t, p.NewWithRequest(req, config, nil))
+	_, err := p.Login(httptest.NewRecorder(), req, "60", nil)
+	require.Equal(t, errHealthCheck, err)
+
+	p = ProviderWithOptions(redisOptions, WithPrefixKey("_before_create_:"), WithBeforeCreate(func(r *http.Request, fields map[string]interface{}) error {
+		fields[p.field(sessionIdName)] = "forged"
+		return nil
+	}))
+	_, err = p.newWithRequest(req, config, nil, false)
+	require.Equal(t, ErrReservedField, err)
+}
+
+func TestProviderNewFromRequest(t *testing.T) {
+	p := ProviderWithPrefixKey(redisOptions, "_new_from_request_:")
+	config := &s.Config{Valid: time.Minute}
+	req, _ := http.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "10.0.0.2:5000"
+	req.Header.Set("User-Agent", "rsn-test")
+	currSession, cookie, err := p.NewFromRequest(req, config, nil)
+	require.Nil(t, err)
+	require.Equal(t, currSession.Id(), cookie.Value)
+	require.Equal(t, Meta{IP: "10.0.0.2", UserAgent: "rsn-test"}, currSession.(Session).Meta())
+
+	req.AddCookie(cookie)
+	again, cookie, err := p.NewFromRequest(req, config, nil)
+	require.Nil(t, err)
+	require.Equal(t, currSession.Id(), again.Id())
+	require.Equal(t, currSession.Id(), cookie.Value)
+
+	p.Del(currSession.Id())
+	created, cookie, err := p.NewFromRequest(req, config, nil)
+	require.Nil(t, err)
+	require.NotEqual(t, currSession.Id(), created.Id())
+	require.Equal(t, created.Id(), cookie.Value)
+	p.Del(created.Id())
+}
+
+func TestProviderLoadValidate(t *testing.T) {
+	injector := NewFaultInjector(1)
+	p := ProviderWithOptions(redisOptions, WithPrefixKey("_load_validate_:"), WithIPBinding(32, 128, FlagMismatch),
+		WithFaultInjector(injector))
+	config := &s.Config{Valid: time.Minute}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	currSession := p.NewWithRequest(req, config, nil)
+	moved := httptest.NewRequest(http.MethodGet, "/", nil)
+	moved.RemoteAddr = "10.0.0.2:1234"
+	moved.AddCookie(p.Cookie(currSession, config))
+
+	loaded, err := p.Load(moved)
+	require.Nil(t, err)
+	require.Equal(t, currSession.Id(), loaded.Id())
+
+	injector.Set(Fault{Op: "evalsha", ErrorRate: 1}, Fault{Op: "eval", ErrorRate: 1})
+	loaded, err = p.Load(moved)
+	injector.Set()
+	require.Error(t, err)
+	require.Nil(t, loaded)
+	again, err := p.GetOrCreate(moved, config, nil)
+	require.Nil(t, err)
+	require.Equal(t, currSession.Id(), again.Id())
+
+	rejecting := ProviderWithOptions(redisOptions, WithPrefixKey("_load_validate_:"), WithIPBinding(32, 128, RejectMismatch))
+	loaded, err = rejecting.Load(moved)
+	require.Nil(t, err)
+	require.Nil(t, loaded)
+	require.False(t, p.Exists(currSession.Id()))
+}
+
+func TestProviderGetOrCreate(t *testing.T) {
+	p := ProviderWithPrefixKey(redisOptions, "_get_or_create_:")
+	config := &s.Config{Valid: time.Minute}
+	req, _ := http.NewRequest(http.MethodGet, "/", nil)
+	currSession, err := p.GetOrCreate(req, config, nil)
+	require.Nil(t, err)
+	req.AddCookie(p.Cookie(currSession, config))
+	again, err := p.GetOrCreate(req, config, nil)
+	require.Nil(t, err)
+	require.Equal(t, currSession.Id(), again.Id())
+	p.Del(currSession.Id())
+	created, err := p.GetOrCreate(req, config, nil)
+	require.Nil(t, err)
+	require.NotEqual(t, currSession.Id(), created.Id())
+	p.Del(created.Id())
+}
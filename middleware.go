@@ -0,0 +1,225 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	s "github.com/go-the-way/anoweb/session"
+)
+
+type sessionContextKey struct{}
+
+// RoutePolicy tell how Middleware handles the session of the requests a Route matches
+type RoutePolicy int
+
+const (
+	// LoadOrCreate load the session of the request or create one, the policy of the requests no route matches
+	LoadOrCreate RoutePolicy = iota
+	// SkipSession give the request no session, e.g. for static assets
+	SkipSession
+	// LoadReadOnly give the request a read-only view of its session when it has one, e.g. for GET APIs.
+	// No session is created or refreshed, and no cookie set.
+	LoadReadOnly
+	// RequireAuthenticated answer 401 unless the request has a session bound to a user, e.g. for /admin
+	RequireAuthenticated
+)
+
+// MiddlewareOption configure Middleware
+type MiddlewareOption func(m *middlewareOptions)
+
+type middlewareOptions struct {
+	routes []route
+}
+
+type route struct {
+	match  func(r *http.Request) bool
+	policy RoutePolicy
+}
+
+// Route apply policy to the requests match returns true for, the first route matching a request winning
+func Route(match func(r *http.Request) bool, policy RoutePolicy) MiddlewareOption {
+	return func(m *middlewareOptions) {
+		m.routes = append(m.routes, route{match: match, policy: policy})
+	}
+}
+
+// MatchPathPrefix return a Route matcher of the requests whose path starts with one of prefixes
+func MatchPathPrefix(prefixes ...string) func(r *http.Request) bool {
+	return func(r *http.Request) bool {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(r.URL.Path, prefix) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// MatchMethods return a Route matcher of the requests sent with one of methods
+func MatchMethods(methods ...string) func(r *http.Request) bool {
+	return func(r *http.Request) bool {
+		for _, method := range methods {
+			if r.Method == method {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// MatchAll return a Route matcher of the requests every one of matchers matches,
+// e.g. MatchAll(MatchMethods(http.MethodGet), MatchPathPrefix("/api/"))
+func MatchAll(matchers ...func(r *http.Request) bool) func(r *http.Request) bool {
+	return func(r *http.Request) bool {
+		for _, match := range matchers {
+			if !match(r) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// policy return the policy of the first route matching r
+func (m *middlewareOptions) policy(r *http.Request) RoutePolicy {
+	for _, rt := range m.routes {
+		if rt.match(r) {
+			return rt.policy
+		}
+	}
+	return LoadOrCreate
+}
+
+// Middleware return a net/http middleware giving every request a session of provider, no anoweb required.
+//
+// The session of the request cookie is loaded, or a new one created, and attached to the request
+// context for FromContext. Its cookie is set before the handler runs, and the session is refreshed
+// once the handler returns. Cleaning of provider is started as the anoweb middleware does.
+// Routes set other policies for the requests they match.
+func Middleware(provider s.Provider, config *s.Config, opts ...MiddlewareOption) func(http.Handler) http.Handler {
+	m := &middlewareOptions{}
+	for _, opt := range opts {
+		opt(m)
+	}
+	go provider.Clean(config, nil)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var (
+				currentSession s.Session
+				err            error
+			)
+			switch m.policy(r) {
+			case SkipSession:
+				next.ServeHTTP(w, r)
+				return
+			case LoadReadOnly:
+				if currentSession, err = load(provider, r); err == ErrUnknownSession {
+					http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+					return
+				}
+				if currentSession != nil {
+					if rs, ok := currentSession.(Session); ok {
+						currentSession = ReadOnly(rs)
+					}
+					r = r.WithContext(NewContext(r.Context(), currentSession))
+				}
+				next.ServeHTTP(w, r)
+				return
+			case RequireAuthenticated:
+				currentSession, _ = load(provider, r)
+				if rs, ok := currentSession.(Session); !ok || rs.UserId() == "" {
+					http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+					return
+				}
+			default:
+				currentSession, err = getOrCreate(provider, r, config)
+				if err == ErrUnknownSession {
+					http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+					return
+				}
+			}
+			if currentSession == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			http.SetCookie(w, sessionCookie(provider, currentSession, config))
+			setLegacyCookies(provider, w, r)
+			next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), currentSession)))
+			if !currentSession.Invalidated() {
+				provider.Refresh(currentSession, config, nil)
+			}
+		})
+	}
+}
+
+// NewContext return a copy of ctx carrying currentSession, as Middleware and the adapters of the
+// other frameworks attach it to the context of the request
+func NewContext(ctx context.Context, currentSession s.Session) context.Context {
+	return context.WithValue(ctx, sessionContextKey{}, currentSession)
+}
+
+// FromContext return the session carried by ctx, such as the context of a request handled by
+// Middleware or by the adapters of the other frameworks, and whether it carries one
+func FromContext(ctx context.Context) (s.Session, bool) {
+	currentSession, ok := ctx.Value(sessionContextKey{}).(s.Session)
+	return currentSession, ok
+}
+
+// getOrCreate return the session of r, created when missing, through GetOrCreate when provider is an rsn provider
+func getOrCreate(provider s.Provider, r *http.Request, config *s.Config) (s.Session, error) {
+	if gp, ok := provider.(interface {
+		GetOrCreate(*http.Request, *s.Config, *s.Listener) (s.Session, error)
+	}); ok {
+		return gp.GetOrCreate(r, config, nil)
+	}
+	if currentSession := loadSession(provider, r); currentSession != nil {
+		return currentSession, nil
+	}
+	return provider.New(config, nil), nil
+}
+
+// load return the session of r without creating one, through Load when provider is an rsn provider
+func load(provider s.Provider, r *http.Request) (s.Session, error) {
+	if lp, ok := provider.(interface {
+		Load(*http.Request) (s.Session, error)
+	}); ok {
+		return lp.Load(r)
+	}
+	return loadSession(provider, r), nil
+}
+
+// loadSession return the live session of r, nil if none
+func loadSession(provider s.Provider, r *http.Request) s.Session {
+	if id := provider.GetId(r); id != "" && provider.Exists(id) {
+		return provider.Get(id)
+	}
+	return nil
+}
+
+// sessionCookie return the cookie of currentSession, built by provider when it is an rsn provider
+func sessionCookie(provider s.Provider, currentSession s.Session, config *s.Config) *http.Cookie {
+	if cp, ok := provider.(interface {
+		Cookie(s.Session, *s.Config) *http.Cookie
+	}); ok {
+		return cp.Cookie(currentSession, config)
+	}
+	return &http.Cookie{
+		Name:    provider.CookieName(),
+		Value:   currentSession.Id(),
+		Expires: time.Now().Add(config.Valid),
+		Path:    "/",
+	}
+}
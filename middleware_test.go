@@ -0,0 +1,156 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	s "github.com/go-the-way/anoweb/session"
+
+	"github.com/stretchr/testify/require"
+)
+
+// sessionOf return the session Middleware attached to r, nil if none
+func sessionOf(r *http.Request) s.Session {
+	currSession, _ := FromContext(r.Context())
+	return currSession
+}
+
+func TestMiddleware(t *testing.T) {
+	p := ProviderWithPrefixKey(redisOptions, "_middleware_:")
+	handler := Middleware(p, &s.Config{Valid: time.Minute})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		currSession := sessionOf(r)
+		if currSession.Get("visits") == nil {
+			currSession.Set("visits", "1")
+		} else {
+			currSession.Set("visits", "2")
+		}
+		_, _ = w.Write([]byte(currSession.Id()))
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	cookie := rec.Result().Cookies()[0]
+	require.Equal(t, p.CookieName(), cookie.Name)
+	require.Equal(t, rec.Body.String(), cookie.Value)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(cookie)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	require.Equal(t, cookie.Value, rec.Body.String())
+	require.Equal(t, "2", p.Get(cookie.Value).Get("visits"))
+	require.Nil(t, sessionOf(req))
+	p.Del(cookie.Value)
+}
+
+func TestMiddlewareRoutes(t *testing.T) {
+	p := ProviderWithPrefixKey(redisOptions, "_middleware_routes_:")
+	config := &s.Config{Valid: time.Minute}
+	handler := Middleware(p, config,
+		Route(MatchPathPrefix("/static/"), SkipSession),
+		Route(MatchAll(MatchMethods(http.MethodGet), MatchPathPrefix("/api/")), LoadReadOnly),
+		Route(MatchPathPrefix("/admin/"), RequireAuthenticated),
+	)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if currSession := sessionOf(r); currSession != nil {
+			currSession.Set("path", r.URL.Path)
+			_, _ = w.Write([]byte(currSession.Id()))
+		}
+	}))
+	serve := func(method, path string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := serve(http.MethodGet, "/static/app.js", nil)
+	require.Empty(t, rec.Result().Cookies())
+	require.Empty(t, rec.Body.String())
+
+	rec = serve(http.MethodGet, "/api/items", nil)
+	require.Empty(t, rec.Result().Cookies())
+	require.Empty(t, rec.Body.String())
+
+	require.Equal(t, http.StatusUnauthorized, serve(http.MethodGet, "/admin/", nil).Code)
+
+	currSession := p.New(config, nil).(Session)
+	defer p.Del(currSession.Id())
+	cookie := p.Cookie(currSession, config)
+	rec = serve(http.MethodGet, "/api/items", cookie)
+	require.Equal(t, currSession.Id(), rec.Body.String())
+	require.Empty(t, rec.Result().Cookies())
+	require.Nil(t, currSession.Get("path"))
+
+	require.Equal(t, http.StatusUnauthorized, serve(http.MethodGet, "/admin/", cookie).Code)
+	require.Nil(t, currSession.BindUser("70"))
+	rec = serve(http.MethodGet, "/admin/", cookie)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "/admin/", currSession.Get("path"))
+
+	serve(http.MethodPost, "/api/items", cookie)
+	require.Equal(t, "/api/items", currSession.Get("path"))
+}
+
+func TestMiddlewareRoutesValidate(t *testing.T) {
+	p := ProviderWithOptions(redisOptions, WithPrefixKey("_middleware_routes_:"), WithFingerprint(nil, RejectMismatch), WithUnknownIdPolicy(RejectUnknown))
+	config := &s.Config{Valid: time.Minute}
+	handler := Middleware(p, config,
+		Route(MatchPathPrefix("/api/"), LoadReadOnly),
+		Route(MatchPathPrefix("/admin/"), RequireAuthenticated),
+	)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if currSession := sessionOf(r); currSession != nil {
+			_, _ = w.Write([]byte(currSession.Id()))
+		}
+	}))
+	serve := func(path, userAgent string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("User-Agent", userAgent)
+		req.AddCookie(cookie)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("User-Agent", "owner")
+	currSession := p.NewWithRequest(req, config, nil).(Session)
+	defer p.Del(currSession.Id())
+	require.Nil(t, currSession.BindUser("71"))
+	cookie := p.Cookie(currSession, config)
+
+	require.Equal(t, currSession.Id(), serve("/admin/", "owner", cookie).Body.String())
+	require.Equal(t, currSession.Id(), serve("/api/items", "owner", cookie).Body.String())
+	require.Empty(t, serve("/api/items", "thief", cookie).Body.String())
+	require.True(t, currSession.Invalidated())
+	require.Equal(t, http.StatusUnauthorized, serve("/admin/", "owner", cookie).Code)
+	unknown := &http.Cookie{Name: p.CookieName(), Value: "0123456789ABCDEF0123456789ABCDEF"}
+	require.Equal(t, http.StatusUnauthorized, serve("/api/items", "owner", unknown).Code)
+}
+
+func TestContext(t *testing.T) {
+	_, ok := FromContext(context.Background())
+	require.False(t, ok)
+	p := ProviderWithPrefixKey(redisOptions, "_context_:")
+	currSession := p.New(&s.Config{Valid: time.Minute}, nil)
+	defer p.Del(currSession.Id())
+	fromCtx, ok := FromContext(NewContext(context.Background(), currSession))
+	require.True(t, ok)
+	require.Equal(t, currSession, fromCtx)
+}
@@ -0,0 +1,504 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	r "github.com/go-redis/redis"
+)
+
+// Option configure provider
+type Option func(p *provider)
+
+// WithPrefixKey set the redis key prefix of sessions
+func WithPrefixKey(prefixKey string) Option {
+	return func(p *provider) {
+		p.keyPrefix = prefixKey
+	}
+}
+
+// WithIndex maintain a secondary index over named session fields, searchable by Find
+func WithIndex(fields ...string) Option {
+	return func(p *provider) {
+		for _, field := range fields {
+			p.indexes[field] = struct{}{}
+		}
+	}
+}
+
+// WithMaxSessionsPerUser cap the number of sessions bound to one user, applying policy when exceeded
+func WithMaxSessionsPerUser(max int, policy EvictionPolicy) Option {
+	return func(p *provider) {
+		p.maxUserSessions = max
+		p.evictionPolicy = policy
+	}
+}
+
+// WithMetaCapture record client ip, user agent and the label returned by device
+// into sessions created by NewWithRequest. device may be nil.
+func WithMetaCapture(device func(r *http.Request) string) Option {
+	return func(p *provider) {
+		p.captureMeta = true
+		p.deviceLabel = device
+	}
+}
+
+// WithClientIP set how the client ip is read from a request, e.g. from a header set by a trusted proxy.
+// The remote address is used by default.
+func WithClientIP(fn func(r *http.Request) string) Option {
+	return func(p *provider) {
+		p.clientIPFunc = fn
+	}
+}
+
+// WithIPBinding bind sessions to the network of their client ip, masked to ipv4Bits or ipv6Bits,
+// checked by Validate with mode applied on mismatch
+func WithIPBinding(ipv4Bits, ipv6Bits int, mode BindingMode) Option {
+	return func(p *provider) {
+		p.ipBinding = &ipBinding{ipv4Bits, ipv6Bits, mode}
+	}
+}
+
+// WithFingerprint bind sessions to the fingerprint fn returns for a request, checked by Validate
+// with mode applied on mismatch. fn may be nil to use a hash of the User-Agent.
+func WithFingerprint(fn func(r *http.Request) string, mode BindingMode) Option {
+	return func(p *provider) {
+		p.fingerprintBinding = &fingerprintBinding{fn, mode}
+	}
+}
+
+// WithListener set the listener of rsn specific session events
+func WithListener(listener *Listener) Option {
+	return func(p *provider) {
+		p.listener = listener
+	}
+}
+
+// WithMaxLifetime set the absolute lifetime of sessions. The Valid duration of the session
+// config becomes a sliding idle timeout which activity can't extend past this lifetime.
+func WithMaxLifetime(maxLifetime time.Duration) Option {
+	return func(p *provider) {
+		p.maxLifetime = maxLifetime
+	}
+}
+
+// WithRefreshThreshold make Refresh skip its write while at least ratio of the idle timeout
+// remains and the session was accessed within interval, e.g. 0.8 and 30s.
+// A zero ratio or interval leaves that condition out. Refreshed doesn't fire for skipped refreshes.
+func WithRefreshThreshold(ratio float64, interval time.Duration) Option {
+	return func(p *provider) {
+		p.refreshRatio = ratio
+		p.refreshInterval = interval
+	}
+}
+
+// WithRememberMe enable the remember-me tier: remembered sessions live for valid between
+// accesses and their cookie gets a matching Max-Age. They are stepped down once fresh elapsed
+// since the last Authenticate, so RequireFresh demands re-authentication for sensitive operations.
+func WithRememberMe(valid, fresh time.Duration) Option {
+	return func(p *provider) {
+		p.rememberValid = valid
+		p.rememberFresh = fresh
+	}
+}
+
+// WithExpiryWarning fire the AboutToExpire listener when the ttl of a session drops below threshold.
+// Sessions are checked by the cleaning pass, so threshold should exceed its interval.
+func WithExpiryWarning(threshold time.Duration) Option {
+	return func(p *provider) {
+		p.expiryWarning = threshold
+	}
+}
+
+// WithSoftDelete keep deleted sessions as tombstones for window, so Restore can bring them back
+func WithSoftDelete(window time.Duration) Option {
+	return func(p *provider) {
+		p.softDeleteWindow = window
+	}
+}
+
+// WithRegenerationGrace keep resolving the old id of a regenerated session to the new one for grace,
+// so in-flight requests carrying the old cookie aren't treated as logged out
+func WithRegenerationGrace(grace time.Duration) Option {
+	return func(p *provider) {
+		p.regenerationGrace = grace
+	}
+}
+
+// WithArchiver hand the fields of sessions to archiver before they are deleted or expire
+func WithArchiver(archiver Archiver) Option {
+	return func(p *provider) {
+		p.archiver = archiver
+	}
+}
+
+// WithPayloadLimit limit the size in bytes of a single value to maxField and of a whole
+// session, names and internal fields included, to maxTotal. Zero means unlimited.
+func WithPayloadLimit(maxField, maxTotal int) Option {
+	return func(p *provider) {
+		p.maxFieldSize = maxField
+		p.maxSessionSize = maxTotal
+	}
+}
+
+// WithMaxFields limit the number of values a session holds, internal fields excluded.
+// With evict the least recently written values make room for new ones, otherwise
+// writes exceeding the limit are refused.
+func WithMaxFields(max int, evict bool) Option {
+	return func(p *provider) {
+		p.maxFields = max
+		p.evictFields = evict
+	}
+}
+
+// WithMaxSessions cap the number of live sessions, typically of a tenant view.
+// Once the cap is reached new sessions are refused, the live ones are never destroyed to make room.
+func WithMaxSessions(max int) Option {
+	return func(p *provider) {
+		p.maxSessions = max
+	}
+}
+
+// WithRedisOptions connect to redis with options, e.g. to route a tenant view to another server
+func WithRedisOptions(options *r.Options) Option {
+	return func(p *provider) {
+		p.options = options
+		p.client = nil
+	}
+}
+
+// WithDB store sessions in redis logical database db
+func WithDB(db int) Option {
+	return func(p *provider) {
+		options := *p.options
+		options.DB = db
+		p.options = &options
+		p.client = nil
+	}
+}
+
+// WithClient store sessions through client instead of connecting with the redis options
+func WithClient(client *r.Client) Option {
+	return func(p *provider) {
+		p.client = client
+		p.options = client.Options()
+	}
+}
+
+// WithValid set the idle timeout of sessions, taking precedence over the anoweb session config
+func WithValid(valid time.Duration) Option {
+	return func(p *provider) {
+		p.idleTimeout = valid
+	}
+}
+
+// WithReadReplicas read session values from replicas, taken in turn, while writes go to the primary.
+// A session reads from the primary for pin after writing its values, so a request sees its own writes.
+func WithReadReplicas(pin time.Duration, replicas ...*r.Options) Option {
+	return func(p *provider) {
+		clients := make([]*r.Client, 0, len(replicas))
+		for _, options := range replicas {
+			clients = append(clients, r.NewClient(options))
+		}
+		p.replicas = &readReplicas{clients: clients, pin: pin}
+	}
+}
+
+// WithClock read the current time from clock instead of the system clock, e.g. to fast-forward time in tests
+func WithClock(clock Clock) Option {
+	return func(p *provider) {
+		p.clock = clock
+	}
+}
+
+// WithEntropy draw session ids from entropy instead of the default generator
+func WithEntropy(entropy io.Reader) Option {
+	return func(p *provider) {
+		p.entropy = entropy
+	}
+}
+
+// WithLocalFastPath let Exists trust sessions known to this instance without asking redis.
+// It saves a round trip per request, at the cost of accepting sessions deleted behind its back
+// until the next cleaning pass.
+func WithLocalFastPath() Option {
+	return func(p *provider) {
+		p.localFastPath = true
+	}
+}
+
+// WithResync keep discovering sessions created by peer instances after the initial sync, scanning
+// at most batch keys every interval once Clean has started. A full pass over n sessions thus takes
+// about n/batch intervals, bounding the load put on redis.
+func WithResync(interval time.Duration, batch int64) Option {
+	return func(p *provider) {
+		p.resyncInterval = interval
+		p.resyncBatch = batch
+	}
+}
+
+// WithLocalCache bound the sessions kept in memory to max, dropping the least recently used ones,
+// and drop those unused for longer than idle during cleaning. Zero disables either bound.
+// Dropped sessions stay in redis and are read through again by Get, but expire without
+// firing the Invalidated listener on this instance.
+func WithLocalCache(max int, idle time.Duration) Option {
+	return func(p *provider) {
+		p.cache = newLocalCache(max, idle)
+	}
+}
+
+// WithHooks run every redis command and pipeline of the provider between the hooks.
+// A client given by WithClient is wrapped in place.
+func WithHooks(hooks *Hooks) Option {
+	return func(p *provider) {
+		p.hooks = hooks
+	}
+}
+
+// WithCreationRateLimit let each client ip create perSecond sessions through NewWithRequest, in bursts
+// of up to burst, so bots hammering a page can't fill redis with sessions. NewWithRequest returns nil
+// for clients over the limit. The token buckets are kept in redis, so the limit holds across instances.
+func WithCreationRateLimit(perSecond float64, burst int) Option {
+	return func(p *provider) {
+		if burst < 1 {
+			burst = 1
+		}
+		p.creationRate = perSecond
+		p.creationBurst = burst
+	}
+}
+
+// WithIdValidator set how GetId tells well-formed session ids, for ids not generated by rsn
+func WithIdValidator(valid func(id string) bool) Option {
+	return func(p *provider) {
+		p.idValidator = valid
+	}
+}
+
+// WithFieldPrefix store the internal fields of sessions, such as the id marker or userId, under names
+// starting with prefix instead of "__rsn_", leaving the plain names to the application. Every field
+// starting with prefix is reserved. An empty prefix stores them under their plain names, reserving those
+// names. Sessions stored under another prefix aren't migrated.
+func WithFieldPrefix(prefix string) Option {
+	return func(p *provider) {
+		p.fieldPrefix = prefix
+	}
+}
+
+// WithReservedFields protect named fields from Set, Del and Clear, as internal fields are
+func WithReservedFields(names ...string) Option {
+	return func(p *provider) {
+		if p.reservedFields == nil {
+			p.reservedFields = map[string]struct{}{}
+		}
+		for _, name := range names {
+			p.reservedFields[name] = struct{}{}
+		}
+	}
+}
+
+// WithKeyFunc build the key of the hash of each session with fn instead of the key prefix, e.g. to add
+// the environment or a hash tag such as "prod:{" + id + "}". fn must embed id once and unchanged, so
+// ids can be read back from keys when scanning. Index, user and expiry keys still use the key prefix.
+func WithKeyFunc(fn func(id string) string) Option {
+	return func(p *provider) {
+		p.keyFunc = fn
+	}
+}
+
+// WithSearchIndex maintain the RediSearch index name over fields of sessions, created at start when missing,
+// so Search answers queries such as "admin sessions from a network in the last hour" without scanning.
+// Internal fields are given by their plain names, e.g. "userId", "ip" or "createdAt". The index needs
+// the RediSearch module on the server.
+func WithSearchIndex(name string, fields ...SearchField) Option {
+	return func(p *provider) {
+		p.search = &searchIndex{name: name, fields: fields}
+	}
+}
+
+// WithCookieName name the session cookie name instead of GOSESSID
+func WithCookieName(name string) Option {
+	return func(p *provider) {
+		p.cookieName = name
+	}
+}
+
+// WithLegacyCookieNames let GetId read the session id from the cookies of names, former names of the session
+// cookie, so renaming it with WithCookieName doesn't log every client out. Clients move to the new name as
+// the session cookie is set again, their legacy cookies being deleted by Middleware, Login, Logout
+// and the adapters of the other frameworks.
+func WithLegacyCookieNames(names ...string) Option {
+	return func(p *provider) {
+		p.legacyCookieNames = names
+	}
+}
+
+// WithChangeFeed publish the values set and deleted in sessions, so Watch can follow them on any instance.
+// Each write then costs one more PUBLISH.
+func WithChangeFeed() Option {
+	return func(p *provider) {
+		p.changeFeed = true
+	}
+}
+
+// WithTimeouts bound reads, writes and admin scans by their own timeouts, so a slow redis fails
+// request handlers fast instead of stalling them for the full timeout of the redis options,
+// while scans over many keys keep a longer one. Contexts passed to the admin walks still cancel them.
+func WithTimeouts(timeouts Timeouts) Option {
+	return func(p *provider) {
+		p.timeouts = &timeouts
+	}
+}
+
+// WithSlowLog log every redis operation of the provider taking threshold or longer, with its command,
+// key and the caller which led to it, to debug the tail latency of the session layer. log receives
+// each slow operation, which is printed to stderr when log is nil. It runs beside the hooks of WithHooks.
+func WithSlowLog(threshold time.Duration, log func(op SlowOp)) Option {
+	return func(p *provider) {
+		p.slowLog = &slowLog{threshold: threshold, log: log}
+	}
+}
+
+// WithWriteBehind queue the values set and deleted in sessions, coalescing the writes of a field,
+// and flush them in one pipeline every interval, so chatty handlers cost a fraction of the round
+// trips. A write finding maxPending fields queued flushes them at once, blocking writers meanwhile,
+// 0 leaving the queue unbounded. Reads of a session flush its queued writes first, and SyncSet
+// bypasses the queue for fields which must be durable at once. Queued writes are lost if the
+// process dies, and writes checked by a size limit or an index aren't queued.
+func WithWriteBehind(interval time.Duration, maxPending int) Option {
+	return func(p *provider) {
+		p.writeBehind = newWriteQueue(interval, maxPending)
+	}
+}
+
+// WithWaitReplicas follow the critical writes, binding a user, authenticating, elevating, storing
+// the principal and SyncSet, with WAIT until replicas acknowledged them, so a login or a role change
+// survives a failover. Those writes return ErrNotReplicated when fewer replicas did within timeout,
+// the write being kept on the primary. Login is durable once it bound and authenticated the session.
+// timeout should be positive, WAIT 0 blocking until the replicas acknowledged.
+func WithWaitReplicas(replicas int, timeout time.Duration) Option {
+	return func(p *provider) {
+		p.durability = &durability{replicas: replicas, timeout: timeout}
+	}
+}
+
+// WithFailover keep a copy of the sessions on secondary, a redis server of another region for instance,
+// as high availability without Cluster: writes are mirrored to it and reads go to the primary, until the
+// primary can't be reached and the sessions fail over to secondary, delivering EventFailover. The roles are
+// then swapped, mirroring to the former primary once it answers again. Writes missed by the standby while
+// it was down stay missing until rewritten, transactions aren't atomic on it and pub/sub stays on the primary.
+func WithFailover(secondary *r.Options) Option {
+	return func(p *provider) {
+		p.secondary = secondary
+	}
+}
+
+// WithFaultInjector inject the faults set on injector into every redis operation of the provider,
+// to test how the application behaves when the session store fails or slows down
+func WithFaultInjector(injector *FaultInjector) Option {
+	return func(p *provider) {
+		p.faults = injector
+	}
+}
+
+// WithCommandRecorder record every redis command of the provider into recorder, skipping the mutations
+// when it is in dry-run mode
+func WithCommandRecorder(recorder *CommandRecorder) Option {
+	return func(p *provider) {
+		p.recorder = recorder
+	}
+}
+
+// WithMaintenance pace the initial sync, the cleaning passes and the walks of the admin APIs as set by m,
+// e.g. smaller batches with pauses between them so background maintenance doesn't compete with requests
+func WithMaintenance(m Maintenance) Option {
+	return func(p *provider) {
+		p.maintenance = m
+	}
+}
+
+// WithBeforeCreate run hook before every session created for a request, by NewWithRequest and Login,
+// to enrich its initial fields or veto it. Sessions created by New don't run it.
+func WithBeforeCreate(hook BeforeCreate) Option {
+	return func(p *provider) {
+		p.beforeCreate = hook
+	}
+}
+
+// WithUnknownIdPolicy set what GetOrCreate, NewFromRequest and the middlewares do with a request
+// presenting the id of no live session, CreateOnUnknown by default
+func WithUnknownIdPolicy(policy UnknownIdPolicy) Option {
+	return func(p *provider) {
+		p.unknownIdPolicy = policy
+	}
+}
+
+// WithWebhook post the Created, Invalidated and Destroyed events of the sessions to hook, in the background.
+// Events are dropped, and reported, when the webhook falls too far behind.
+func WithWebhook(hook Webhook) Option {
+	return func(p *provider) {
+		p.publishers = append(p.publishers, newEventQueue(&hook))
+	}
+}
+
+// WithEventPublisher give the Created, Invalidated and Destroyed events of the sessions to publisher,
+// in the background. Events are dropped, and reported, when the publisher falls too far behind.
+func WithEventPublisher(publisher EventPublisher) Option {
+	return func(p *provider) {
+		p.publishers = append(p.publishers, newEventQueue(publisher))
+	}
+}
+
+// WithEventStream deliver the Created, Invalidated and Destroyed events of the sessions to the Handle of stream
+// at least once, through a redis stream, see EventStream
+func WithEventStream(stream EventStream) Option {
+	return func(p *provider) {
+		p.eventStream = &eventStream{EventStream: stream}
+	}
+}
+
+// WithCallbackPool run the listener callbacks on workers goroutines, each taking them from a queue of up to queue
+// callbacks, rather than on a goroutine each, so a burst of expirations can't start thousands of goroutines.
+// The callbacks of a session always go to the same worker. overflow decides what happens to callbacks finding
+// the queue full.
+func WithCallbackPool(workers, queue int, overflow OverflowPolicy) Option {
+	return func(p *provider) {
+		p.callbacks = newCallbackPool(workers, queue, overflow)
+	}
+}
+
+// WithIdFieldName mark the sessions with their id under the field name instead of "__rsn_id",
+// whatever the prefix of WithFieldPrefix. Sessions marked under another name aren't found anymore.
+func WithIdFieldName(name string) Option {
+	return func(p *provider) {
+		p.idField = name
+	}
+}
+
+// WithLegacyIdField mark the sessions with their id under LegacyIdField, as stored before "__rsn_id",
+// so the sessions already stored are still found. The application can't use the field then.
+func WithLegacyIdField() Option {
+	return WithIdFieldName(LegacyIdField)
+}
+
+// WithLegacyFieldNames store the internal fields under their plain names, such as userId or createdAt,
+// and the id marker under LegacyIdField, as sessions were stored before their names were prefixed,
+// so the sessions already stored are still read. The application can't use these names then.
+func WithLegacyFieldNames() Option {
+	return func(p *provider) {
+		p.fieldPrefix = ""
+		p.idField = LegacyIdField
+	}
+}
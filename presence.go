@@ -0,0 +1,94 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	r "github.com/go-redis/redis"
+)
+
+// OnlineUsers return the ids of the users with a bound session accessed within the last within, sorted.
+//
+// The user index is walked with SCAN, and a session counts as accessed when it was created or
+// refreshed, so with WithRefreshThreshold presence is as precise as the threshold allows.
+func (p *provider) OnlineUsers(ctx context.Context, within time.Duration) ([]string, error) {
+	client := p.reads().WithContext(ctx)
+	userKeyPrefix := p.getUserKeyPrefix()
+	since := p.now().Add(-within)
+	online := make([]string, 0)
+	cursor := uint64(0)
+	for {
+		keys, next, err := p.scans().WithContext(ctx).Scan(cursor, userKeyPrefix+"*", p.scanCount()).Result()
+		if err != nil {
+			return nil, wrapErr(err)
+		}
+		users, err := p.onlineAmong(client, keys, userKeyPrefix, since)
+		if err != nil {
+			return nil, wrapErr(err)
+		}
+		online = append(online, users...)
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+	sort.Strings(online)
+	return online, nil
+}
+
+// OnlineCount return the number of users with a bound session accessed within the last within
+func (p *provider) OnlineCount(ctx context.Context, within time.Duration) (int, error) {
+	online, err := p.OnlineUsers(ctx, within)
+	return len(online), err
+}
+
+// onlineAmong return the users of the user index keys with a bound session accessed since
+func (p *provider) onlineAmong(client *r.Client, keys []string, userKeyPrefix string, since time.Time) ([]string, error) {
+	members := make([]*r.StringSliceCmd, len(keys))
+	_, err := client.Pipelined(func(pipe r.Pipeliner) error {
+		for i, key := range keys {
+			members[i] = pipe.SMembers(key)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	accesses := make([][]*r.SliceCmd, len(keys))
+	_, err = client.Pipelined(func(pipe r.Pipeliner) error {
+		for i := range keys {
+			for _, id := range members[i].Val() {
+				accesses[i] = append(accesses[i], pipe.HMGet(p.getRedisKey(id), p.field(userIdName), p.field(accessedAtName)))
+			}
+		}
+		return nil
+	})
+	if err != nil && err != r.Nil {
+		return nil, err
+	}
+	online := make([]string, 0)
+	for i, key := range keys {
+		userId := strings.TrimPrefix(key, userKeyPrefix)
+		for _, access := range accesses[i] {
+			vals := access.Val()
+			if len(vals) == 2 && stringOf(vals[0]) == userId && !parseTime(stringOf(vals[1])).Before(since) {
+				online = append(online, userId)
+				break
+			}
+		}
+	}
+	return online, nil
+}
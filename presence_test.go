@@ -0,0 +1,48 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	s "github.com/go-the-way/anoweb/session"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProviderOnlineUsers(t *testing.T) {
+	clock := &fixedClock{time.Now()}
+	p := ProviderWithOptions(redisOptions, WithPrefixKey("_presence_:"), WithClock(clock))
+	idle := p.New(&s.Config{Valid: time.Hour}, nil).(Session)
+	require.NoError(t, idle.BindUser("bob"))
+	clock.now = clock.now.Add(time.Minute * 10)
+	active := p.New(&s.Config{Valid: time.Hour}, nil).(Session)
+	require.NoError(t, active.BindUser("alice"))
+	other := p.New(&s.Config{Valid: time.Hour}, nil).(Session)
+	require.NoError(t, other.BindUser("alice"))
+	_ = p.New(&s.Config{Valid: time.Hour}, nil)
+
+	online, err := p.OnlineUsers(context.Background(), time.Minute*5)
+	require.NoError(t, err)
+	require.Equal(t, []string{"alice"}, online)
+	count, err := p.OnlineCount(context.Background(), time.Minute*15)
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+
+	p.Refresh(idle, &s.Config{Valid: time.Hour}, nil)
+	online, err = p.OnlineUsers(context.Background(), time.Minute*5)
+	require.NoError(t, err)
+	require.Equal(t, []string{"alice", "bob"}, online)
+	p.Clear()
+}
@@ -0,0 +1,80 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	r "github.com/go-redis/redis"
+)
+
+// Principal is the user a session is logged in as, with their roles
+type Principal struct {
+	ID    string   `json:"id"`
+	Roles []string `json:"roles,omitempty"`
+}
+
+// HasRole report whether principal holds role
+func (pr *Principal) HasRole(role string) bool {
+	if pr == nil {
+		return false
+	}
+	for _, held := range pr.Roles {
+		if held == role {
+			return true
+		}
+	}
+	return false
+}
+
+// SetPrincipal store principal as json in session, binding session to its ID when not empty
+func (s *session) SetPrincipal(principal Principal) error {
+	if s.invalidated {
+		return ErrSessionNotFound
+	}
+	if principal.ID != "" && principal.ID != s.UserId() {
+		if err := s.BindUser(principal.ID); err != nil {
+			return err
+		}
+	}
+	b, err := json.Marshal(principal)
+	if err != nil {
+		return err
+	}
+	return s.provider.criticalWrite(s.client, func(pipe r.Pipeliner) {
+		pipe.HSet(s.key, s.provider.field(principalName), b)
+	})
+}
+
+// Principal return the principal stored in session, nil when none
+func (s *session) Principal() *Principal {
+	b, err := s.reader().HGet(s.key, s.provider.field(principalName)).Bytes()
+	if err != nil {
+		if err != r.Nil {
+			_, _ = fmt.Fprintln(os.Stderr, err)
+		}
+		return nil
+	}
+	principal := &Principal{}
+	if err = json.Unmarshal(b, principal); err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		return nil
+	}
+	return principal
+}
+
+// HasRole report whether the principal of session holds role
+func (s *session) HasRole(role string) bool {
+	return s.Principal().HasRole(role)
+}
@@ -0,0 +1,43 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	s "github.com/go-the-way/anoweb/session"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionPrincipal(t *testing.T) {
+	p := ProviderWithPrefixKey(redisOptions, "_principal_:")
+	currSession := p.New(&s.Config{Valid: time.Minute}, nil).(Session)
+	require.Nil(t, currSession.Principal())
+	require.False(t, currSession.HasRole("admin"))
+
+	require.NoError(t, currSession.SetPrincipal(Principal{ID: "alice", Roles: []string{"admin", "editor"}}))
+	require.Equal(t, &Principal{ID: "alice", Roles: []string{"admin", "editor"}}, currSession.Principal())
+	require.True(t, currSession.HasRole("editor"))
+	require.False(t, currSession.HasRole("owner"))
+	require.Equal(t, "alice", currSession.UserId())
+	ids, err := p.userSessionIds(context.Background(), "alice")
+	require.NoError(t, err)
+	require.Equal(t, []string{currSession.Id()}, ids)
+
+	currSession.Clear()
+	require.True(t, currSession.HasRole("admin"))
+	require.Equal(t, ErrReadOnlySession, currSession.ReadOnly().SetPrincipal(Principal{ID: "bob"}))
+	p.Del(currSession.Id())
+}
@@ -12,15 +12,17 @@
 package rsn
 
 import (
+	"context"
 	"crypto/md5"
+	crand "crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"math/rand"
 	"net/http"
 	"os"
-	"strconv"
-	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	r "github.com/go-redis/redis"
@@ -28,7 +30,58 @@ import (
 	s "github.com/go-the-way/anoweb/session"
 )
 
-const defaultPrefixKey = "session:"
+const (
+	defaultPrefixKey = "session:"
+	// defaultFieldPrefix the prefix of the internal fields, see WithFieldPrefix
+	defaultFieldPrefix = "__rsn_"
+	// cleanInterval the pause between two cleaning passes
+	cleanInterval = time.Minute
+)
+
+// SessionProvider is the anoweb session provider stored in redis, with the extra features of rsn.
+// Handlers depending on it rather than the concrete provider can be tested against rsnmock.
+// Setup and infrastructure accessors such as ForTenant, Client and PoolStats stay on the concrete provider.
+type SessionProvider interface {
+	s.Provider
+	// NewWithRequest return new session recording the client of r
+	NewWithRequest(r *http.Request, config *s.Config, listener *s.Listener) s.Session
+	// NewFromRequest return the valid session of r or a new one, with the cookie to set
+	NewFromRequest(r *http.Request, config *s.Config, listener *s.Listener) (s.Session, *http.Cookie, error)
+	// GetOrCreate return the valid session of r or a new one
+	GetOrCreate(r *http.Request, config *s.Config, listener *s.Listener) (s.Session, error)
+	// Load return the valid session of r, nil if none
+	Load(r *http.Request) (s.Session, error)
+	// LoadId return the valid session of id read elsewhere than from the cookie of r, nil if none
+	LoadId(r *http.Request, id string) (s.Session, error)
+	// ValidId report whether id is well-formed, to check ids read elsewhere than from the cookie
+	ValidId(id string) bool
+	// Cookie return the session cookie to set on the response
+	Cookie(session s.Session, config *s.Config) *http.Cookie
+	// LegacyCookies return the cookies deleting the legacy cookies r still carries
+	LegacyCookies(r *http.Request) []*http.Cookie
+	// Regenerate move session to a new id
+	Regenerate(session s.Session) (s.Session, error)
+	// Restore bring back a soft deleted session
+	Restore(id string) error
+	// Find return the sessions whose indexed field holds value
+	Find(ctx context.Context, field, value string) ([]s.Session, error)
+	// List return a page of session ids
+	List(ctx context.Context, cursor uint64, limit int64) ([]string, uint64, error)
+	// ForEach call fn with every session
+	ForEach(ctx context.Context, fn func(s.Session) error) error
+	// Count return the number of live sessions
+	Count(ctx context.Context) (int64, error)
+	// SessionsByUser describe the live sessions of userId
+	SessionsByUser(ctx context.Context, userId string) ([]SessionInfo, error)
+	// Delete delete session id, reporting whether it existed
+	Delete(id string) (bool, error)
+	// InvalidateUser delete every session of userId
+	InvalidateUser(ctx context.Context, userId string) error
+	// Purge erase every trace of userId
+	Purge(ctx context.Context, userId string) (*PurgeReport, error)
+}
+
+var _ SessionProvider = (*provider)(nil)
 
 type provider struct {
 	mu        *sync.Mutex
@@ -36,6 +89,79 @@ type provider struct {
 	options   *r.Options
 	client    *r.Client
 	sessions  map[string]s.Session
+	indexes   map[string]struct{}
+
+	maxUserSessions int
+	evictionPolicy  EvictionPolicy
+
+	captureMeta  bool
+	deviceLabel  func(r *http.Request) string
+	clientIPFunc func(r *http.Request) string
+	beforeCreate BeforeCreate
+	ipBinding    *ipBinding
+
+	fingerprintBinding *fingerprintBinding
+	listener           *Listener
+	maxLifetime        time.Duration
+	refreshRatio       float64
+	refreshInterval    time.Duration
+	rememberValid      time.Duration
+	rememberFresh      time.Duration
+	expiryWarning      time.Duration
+	softDeleteWindow   time.Duration
+	regenerationGrace  time.Duration
+	archiver           Archiver
+	maxFieldSize       int
+	maxSessionSize     int
+	maxFields          int
+	evictFields        bool
+	maxSessions        int
+	idleTimeout        time.Duration
+	clock              Clock
+	entropy            io.Reader
+	replicas           *readReplicas
+	localFastPath      bool
+	resyncInterval     time.Duration
+	resyncBatch        int64
+	maintenance        Maintenance
+	cache              *localCache
+	hooks              *Hooks
+	slowLog            *slowLog
+	events             *eventBus
+	creationRate       float64
+	creationBurst      int
+	idValidator        func(id string) bool
+	rejectedIds        uint64
+	fieldPrefix        string
+	idField            string
+	reservedFields     map[string]struct{}
+	keyFunc            func(id string) string
+	hashFieldTTL       int32
+	search             *searchIndex
+	cookieName         string
+	legacyCookieNames  []string
+	cookieCodec        *cookieCodec
+	changeFeed         bool
+	timeouts           *Timeouts
+	writeBehind        *writeQueue
+	durability         *durability
+	unknownIdPolicy    UnknownIdPolicy
+	publishers         []*eventQueue
+	eventStream        *eventStream
+	callbacks          *callbackPool
+	serial             *serialQueues
+	secondary          *r.Options
+	failover           *failover
+	faults             *FaultInjector
+	recorder           *CommandRecorder
+	readClient         *r.Client
+	scanClient         *r.Client
+
+	revokeWatchers *revokeWatchers
+
+	tenants       map[string]*provider
+	cleaning      bool
+	cleanListener *s.Listener
 }
 
 // Provider return new provider
@@ -45,68 +171,301 @@ func Provider(options *r.Options) *provider {
 
 // ProviderWithPrefixKey return new provider with prefix key
 func ProviderWithPrefixKey(options *r.Options, prefixKey string) *provider {
-	client := r.NewClient(options)
-	ping := client.Ping()
-	p := &provider{&sync.Mutex{}, prefixKey, options, client, map[string]s.Session{}}
-	if ping.Err() != nil {
-		_, _ = fmt.Fprintln(os.Stderr, ping.Err())
+	return ProviderWithOptions(options, WithPrefixKey(prefixKey))
+}
+
+// ProviderWithOptions return new provider configured by opts
+func ProviderWithOptions(options *r.Options, opts ...Option) *provider {
+	p := newProvider(options, opts...)
+	if p.search != nil {
+		if err := p.createSearchIndex(); err != nil {
+			p.report(err)
+		}
 	}
 	p.syncSession()
 	return p
 }
 
-// CookieName return cookie name
+// newProvider return new provider configured by opts, connected but holding no session yet
+func newProvider(options *r.Options, opts ...Option) *provider {
+	p := &provider{
+		mu:          &sync.Mutex{},
+		keyPrefix:   defaultPrefixKey,
+		fieldPrefix: defaultFieldPrefix,
+		options:     options,
+		sessions:    map[string]s.Session{},
+		indexes:     map[string]struct{}{},
+
+		revokeWatchers: &revokeWatchers{watchers: map[string]map[uint64]func(){}},
+		events:         &eventBus{},
+		serial:         &serialQueues{pending: map[string][]func(){}},
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	if p.client == nil {
+		p.client = r.NewClient(p.options)
+	}
+	p.applyTimeouts()
+	p.applyFailover()
+	p.instrument(p.client)
+	if p.replicas != nil {
+		for _, client := range p.replicas.clients {
+			p.instrument(client)
+		}
+	}
+	if p.writeBehind != nil {
+		p.writeBehind.report = p.report
+		go p.writeBehind.run()
+	}
+	if p.callbacks != nil {
+		p.callbacks.report = p.report
+		p.callbacks.start()
+	}
+	for _, q := range p.publishers {
+		q.report = p.report
+		go q.run()
+	}
+	if p.eventStream != nil {
+		p.eventStream.key = p.getEventStreamKey()
+		go p.consumeEvents()
+	}
+	if ping := p.client.Ping(); ping.Err() != nil {
+		_, _ = fmt.Fprintln(os.Stderr, ping.Err())
+	}
+	return p
+}
+
+// CookieName return cookie name, GOSESSID unless set by WithCookieName
 func (p *provider) CookieName() string {
+	if p.cookieName != "" {
+		return p.cookieName
+	}
 	return "GOSESSID"
 }
 
-// GetId get session id
+// GetId get session id, empty when the cookie is missing or doesn't hold a well-formed id,
+// so garbage never reaches redis key names. Rejected ids are counted by RejectedIds.
+// The legacy cookies of WithLegacyCookieNames are read when the cookie is missing or rejected.
 func (p *provider) GetId(r *http.Request) string {
-	cookie, err := r.Cookie(p.CookieName())
-	if err == nil && cookie != nil {
-		return cookie.Value
+	for _, name := range p.cookieNames() {
+		cookie, err := r.Cookie(name)
+		if err != nil || cookie == nil {
+			continue
+		}
+		id, decoded := cookie.Value, true
+		if p.cookieCodec != nil {
+			id, decoded = p.cookieCodec.decode(cookie.Value)
+		}
+		if !decoded || !p.validId(id) {
+			atomic.AddUint64(&p.rejectedIds, 1)
+			continue
+		}
+		return id
 	}
 	return ""
 }
 
+// RejectedIds return the number of malformed session ids GetId rejected
+func (p *provider) RejectedIds() uint64 {
+	return atomic.LoadUint64(&p.rejectedIds)
+}
+
+// ValidId report whether id is well-formed, as GetId checks the cookie, so ids read elsewhere,
+// e.g. from gRPC metadata, never reach redis key names unchecked
+func (p *provider) ValidId(id string) bool {
+	return p.validId(id)
+}
+
+// validId report whether id is well-formed, as checked by the func set by WithIdValidator,
+// or as generated by default: 32 upper case hexadecimal digits
+func (p *provider) validId(id string) bool {
+	if p.idValidator != nil {
+		return p.idValidator(id)
+	}
+	if len(id) != 32 {
+		return false
+	}
+	for _, c := range id {
+		if (c < '0' || c > '9') && (c < 'A' || c > 'F') {
+			return false
+		}
+	}
+	return true
+}
+
+// Client return the redis client sessions are stored through. Commands wrappers installed on it
+// with WrapProcess and WrapProcessPipeline, e.g. for tracing or slow-command logging, see every
+// command of the session layer.
+func (p *provider) Client() *r.Client {
+	return p.client
+}
+
+// PoolStats return the connection pool statistics of the redis client sessions are stored through
+func (p *provider) PoolStats() *r.PoolStats {
+	return p.client.PoolStats()
+}
+
+// getRedisKey return the key of the hash of session id, built by the func set by WithKeyFunc if any
 func (p *provider) getRedisKey(id string) string {
-	return fmt.Sprintf("%s%s", p.keyPrefix, id)
+	if p.keyFunc != nil {
+		return p.keyFunc(id)
+	}
+	return p.keyPrefix + id
+}
+
+// getKeyPattern return the SCAN pattern matching the keys of the sessions whose id matches glob
+func (p *provider) getKeyPattern(glob string) string {
+	return p.getRedisKey(glob)
 }
 
-// Exists session
+// Exists report whether session id is live in redis, so sessions created by peer instances are
+// found and local ones deleted behind this instance's back are not. A session found in redis but
+// unknown locally is loaded, so Get returns it.
 func (p *provider) Exists(id string) bool {
-	currentSession := p.Get(id)
-	return currentSession != nil && !currentSession.Invalidated()
+	p.mu.Lock()
+	currentSession, have := p.sessions[id]
+	p.mu.Unlock()
+	if !have {
+		return p.Get(id) != nil
+	}
+	if currentSession.Invalidated() || p.localFastPath {
+		return !currentSession.Invalidated()
+	}
+	n, err := p.reads().Exists(p.getRedisKey(id)).Result()
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		return true
+	}
+	if n == 0 {
+		markInvalidated(currentSession)
+		return false
+	}
+	return true
 }
 
-// Get session
+// Get session, reading it through from redis when it isn't known locally,
+// e.g. because a peer instance created it after the last sync
 func (p *provider) Get(id string) s.Session {
+	p.mu.Lock()
 	currentSession, have := p.sessions[id]
-	if !have {
+	if have {
+		p.cacheTouch(id)
+	}
+	p.mu.Unlock()
+	if have {
+		return currentSession
+	}
+	if rs := p.hydrate(id); rs != nil {
+		return rs
+	}
+	if newId := p.successor(id); newId != "" {
+		return p.Get(newId)
+	}
+	return nil
+}
+
+// hydrate add a local session bound to the redis key of id and return it, or the local one
+// added meanwhile. nil is returned when redis doesn't hold session id.
+func (p *provider) hydrate(id string) s.Session {
+	if _, own := p.ownId(p.getRedisKey(id)); !own {
+		return nil
+	}
+	values, err := p.reads().HMGet(p.getRedisKey(id), p.field(sessionIdName), p.field(userIdName)).Result()
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
 		return nil
 	}
-	return currentSession.(s.Session)
+	if values[0] == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if currentSession, have := p.sessions[id]; have {
+		return currentSession
+	}
+	rs := newSession(p, id)
+	rs.userId, _ = values[1].(string)
+	p.store(id, rs)
+	return rs
 }
 
 // Del session
 func (p *provider) Del(id string) {
-	p.del(id, true)
+	if _, err := p.Delete(id); err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+	}
+}
+
+// Delete delete session id and report whether it existed in redis. The local session is
+// invalidated, the Invalidated and Destroyed listeners given to Clean fire, and peer
+// instances are told.
+func (p *provider) Delete(id string) (bool, error) {
+	p.mu.Lock()
+	currentSession, have := p.sessions[id]
+	if have {
+		markInvalidated(currentSession)
+	}
+	existed, err := p.del(id, false)
+	listener := p.cleanListener
+	p.mu.Unlock()
+	if err != nil {
+		return existed, wrapErr(err)
+	}
+	p.publishInvalidation(id)
+	if have {
+		p.emit(EventInvalidated, id)
+		p.emit(EventDestroyed, id)
+	}
+	if have && listener != nil {
+		p.dispatch(id, func() {
+			if listener.Invalidated != nil {
+				listener.Invalidated(currentSession)
+			}
+			if listener.Destroyed != nil {
+				listener.Destroyed(currentSession)
+			}
+		})
+	}
+	return existed, nil
 }
 
-func (p *provider) del(id string, lock bool) {
+func (p *provider) del(id string, lock bool) (bool, error) {
 	if lock {
 		p.mu.Lock()
 		defer p.mu.Unlock()
 	}
-	delCmd := p.client.Del(p.getRedisKey(id))
-	if delCmd.Err() != nil {
-		_, _ = fmt.Fprintln(os.Stderr, delCmd.Err())
-	}
+	existed, err := p.delStored(id)
 	delete(p.sessions, id)
+	return existed, err
 }
 
+// delStored delete what redis holds for session id, leaving its local entry,
+// and report whether its key existed
+func (p *provider) delStored(id string) (bool, error) {
+	p.writeBehind.settle(p.getRedisKey(id))
+	p.unindex(id)
+	p.unbindUser(id)
+	p.archive(id, false)
+	existed, err := p.deleteKey(id)
+	p.unindexExpiry(id)
+	if p.evictFields {
+		p.client.Del(p.getFieldsKey(id))
+	}
+	p.revoked(id)
+	return existed, err
+}
+
+// GetAll return a snapshot of the local sessions, safe to iterate while sessions come and go.
+// Use GetAllStored for the sessions held in redis, or ForEach to walk them without loading all at once.
 func (p *provider) GetAll() map[string]s.Session {
-	return p.sessions
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	sessions := make(map[string]s.Session, len(p.sessions))
+	for id, currentSession := range p.sessions {
+		sessions[id] = currentSession
+	}
+	return sessions
 }
 
 // Clear session's values
@@ -114,40 +473,89 @@ func (p *provider) Clear() {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 	for k := range p.sessions {
-		p.del(k, false)
+		if _, err := p.del(k, false); err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, err)
+		}
 	}
 }
 
 func tmd5(text string) string {
-	hashMd5 := md5.New()
-	_, _ = io.WriteString(hashMd5, text)
-	return fmt.Sprintf("%x", hashMd5.Sum(nil))
+	sum := md5.Sum([]byte(text))
+	return hex.EncodeToString(sum[:])
 }
 
+const upperHexDigits = "0123456789ABCDEF"
+
+// upperHex return b as upper case hex, without the copies of strings.ToUpper
+func upperHex(b []byte) string {
+	out := make([]byte, 2*len(b))
+	for i, c := range b {
+		out[2*i] = upperHexDigits[c>>4]
+		out[2*i+1] = upperHexDigits[c&0x0f]
+	}
+	return string(out)
+}
+
+// sidFallback the source of session ids when crypto/rand fails, seeded once as math/rand
+// sources aren't safe for concurrent use
+var sidFallback = struct {
+	sync.Mutex
+	rand *rand.Rand
+}{rand: rand.New(rand.NewSource(time.Now().UnixNano()))}
+
+// newSID return a new session id, 16 random bytes from crypto/rand as upper case hex
 func newSID() string {
-	nano := time.Now().UnixNano()
-	rand.Seed(nano)
-	rndNum := rand.Int63()
-	return strings.ToUpper(tmd5(tmd5(strconv.FormatInt(nano, 10)) + tmd5(strconv.FormatInt(rndNum, 10))))
+	var b [16]byte
+	if _, err := crand.Read(b[:]); err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		sidFallback.Lock()
+		_, _ = sidFallback.rand.Read(b[:])
+		sidFallback.Unlock()
+	}
+	return upperHex(b[:])
 }
 
-// New return new session
+// New return new session, nil when the cap set by WithMaxSessions is reached
 func (p *provider) New(config *s.Config, listener *s.Listener) s.Session {
-	p.mu.Lock()
-	defer p.mu.Unlock()
-	sessionId := newSID()
-	currentSession := newSession(p.client, sessionId, p.getRedisKey(sessionId))
-	hashSetCmd := p.client.HSet(p.getRedisKey(sessionId), sessionIdName, sessionId)
+	if p.sessionCapReached() {
+		return nil
+	}
+	return p.create(p.newSID(), config, listener, nil)
+}
+
+// create store a new session of sessionId holding fields besides the internal ones, then fire Created
+func (p *provider) create(sessionId string, config *s.Config, listener *s.Listener, fields map[string]interface{}) s.Session {
+	valid := p.valid(config)
+	currentSession := newSession(p, sessionId)
+	now := formatTime(p.now())
+	values := map[string]interface{}{
+		p.field(sessionIdName):  sessionId,
+		p.field(createdAtName):  now,
+		p.field(accessedAtName): now,
+		p.field(lifeTimeName):   int64(valid / time.Millisecond),
+	}
+	if p.maxLifetime > 0 {
+		values[p.field(deadlineName)] = formatTime(p.now().Add(p.maxLifetime))
+	}
+	for k, v := range fields {
+		values[k] = v
+	}
+	hashSetCmd := p.client.HMSet(p.getRedisKey(sessionId), values)
 	if hashSetCmd.Err() != nil {
 		_, _ = fmt.Fprintln(os.Stderr, hashSetCmd.Err())
 		return nil
 	}
-	expireCmd := p.client.Expire(p.getRedisKey(sessionId), config.Valid)
+	ttl := p.initialTTL(valid)
+	expireCmd := p.client.Expire(p.getRedisKey(sessionId), ttl)
 	if expireCmd.Err() != nil {
 		_, _ = fmt.Fprintln(os.Stderr, expireCmd.Err())
 		return nil
 	}
-	p.sessions[sessionId] = currentSession
+	p.indexExpiry(sessionId, ttl)
+	p.mu.Lock()
+	p.store(sessionId, currentSession)
+	p.mu.Unlock()
+	p.emit(EventCreated, sessionId)
 	if listener != nil && listener.Created != nil {
 		listener.Created(currentSession)
 	}
@@ -156,27 +564,67 @@ func (p *provider) New(config *s.Config, listener *s.Listener) s.Session {
 
 // Refresh session
 func (p *provider) Refresh(session s.Session, config *s.Config, listener *s.Listener) {
-	session.Renew(config.Valid)
-	expireCmd := p.client.Expire(p.getRedisKey(session.Id()), config.Valid)
-	if expireCmd.Err() != nil {
-		_, _ = fmt.Fprintln(os.Stderr, expireCmd.Err())
-	} else {
-		go func() {
+	renewed, err := p.renew(session.Id(), p.valid(config), true)
+	if err != nil {
+		if gone(err) {
+			markInvalidated(session)
+		}
+		_, _ = fmt.Fprintln(os.Stderr, err)
+	} else if renewed {
+		rearmExpiryWarning(session)
+		p.dispatch(session.Id(), func() {
 			if listener != nil && listener.Refreshed != nil {
 				listener.Refreshed(session)
 			}
-		}()
+		})
 	}
 }
 
-// Clean session
+// Clean session, starting the cleaning once; a later call only replaces the listener
+// when it isn't nil
 func (p *provider) Clean(_ *s.Config, listener *s.Listener) {
+	p.mu.Lock()
+	if listener != nil {
+		p.cleanListener = listener
+	}
+	started := p.cleaning
+	p.cleaning = true
+	p.mu.Unlock()
+	for _, view := range p.tenantViews() {
+		view.mu.Lock()
+		if listener != nil {
+			view.cleanListener = listener
+		}
+		view.mu.Unlock()
+	}
+	if started {
+		return
+	}
+	p.subscribeInvalidation()
+	for _, view := range p.tenantViews() {
+		view.subscribeInvalidation()
+	}
 	go func() {
 		for {
-			p.cleanSession(listener)
-			time.Sleep(time.Minute)
+			p.cleanSession(p.cleaningListener())
+			time.Sleep(p.cleanEvery())
 		}
 	}()
+	if p.resyncInterval > 0 {
+		go p.resync()
+	}
+}
+
+// cleaningListener return the listener given to Clean
+func (p *provider) cleaningListener() *s.Listener {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.cleanListener
+}
+
+// CleanNow run one cleaning pass at once, as Clean does periodically
+func (p *provider) CleanNow(listener *s.Listener) {
+	p.cleanSession(listener)
 }
 
 func (p *provider) syncSession() {
@@ -185,55 +633,78 @@ func (p *provider) syncSession() {
 	var wg sync.WaitGroup
 	wg.Add(1)
 	go func(wg *sync.WaitGroup) {
-		keysCmd := p.client.Keys(p.keyPrefix + "*")
-		if keysCmd.Err() != nil {
-			_, _ = fmt.Fprintln(os.Stderr, keysCmd.Err())
-		} else {
-			keys := keysCmd.Val()
-			sessionMap := make(map[string]s.Session, 0)
+		defer wg.Done()
+		loaded := 0
+		cursor := uint64(0)
+		for {
+			keys, next, err := p.scans().Scan(cursor, p.getKeyPattern("*"), p.scanCount()).Result()
+			if err != nil {
+				p.report(err)
+				return
+			}
 			for _, key := range keys {
+				if limit := p.maintenance.PassLimit; limit > 0 && loaded >= limit {
+					return
+				}
+				if _, own := p.ownId(key); !own {
+					continue
+				}
 				hashGetAllCmd := p.client.HGetAll(key)
 				if hashGetAllCmd.Err() != nil {
-					_, _ = fmt.Fprintln(os.Stderr, hashGetAllCmd.Err())
+					p.report(hashGetAllCmd.Err())
 					continue
 				}
 				values := hashGetAllCmd.Val()
-				sessionId := values[sessionIdName]
-				rs := newSession(p.client, sessionId, key)
-				sessionMap[sessionId] = rs
-				p.sessions[sessionId] = newSession(p.client, sessionId, key)
+				sessionId := values[p.field(sessionIdName)]
+				if sessionId == "" {
+					continue
+				}
+				rs := newSession(p, sessionId)
+				rs.userId = values[p.field(userIdName)]
+				p.indexExpiryNX(sessionId)
+				p.store(sessionId, rs)
+				loaded++
+			}
+			if next == 0 {
+				return
+			}
+			cursor = next
+			if p.maintenance.BatchPause > 0 {
+				time.Sleep(p.maintenance.BatchPause)
 			}
 		}
-		wg.Done()
 	}(&wg)
 	wg.Wait()
 }
 
 func (p *provider) cleanSession(listener *s.Listener) {
+	now := p.now()
+	for i, id := range p.expiredIds(now) {
+		p.expire(id, listener)
+		p.batchDone(i + 1)
+	}
+	p.archiveExpiring(now)
+	p.expireFields(now)
+	p.warnExpiring(now)
+	p.cacheExpireIdle()
+	for _, view := range p.tenantViews() {
+		view.cleanSession(listener)
+	}
+	destroyed := make([]s.Session, 0)
 	p.mu.Lock()
-	defer p.mu.Unlock()
-	for sessionId, currentSession := range p.GetAll() {
-		key := p.getRedisKey(sessionId)
-		existsCmd := p.client.Exists(key)
-		if existsCmd.Err() != nil {
-			_, _ = fmt.Fprintln(os.Stderr, existsCmd.Err())
-		} else {
-			if existsCmd.Val() <= 0 {
-				currentSession.Invalidate()
-				go func() {
-					if listener != nil && listener.Invalidated != nil {
-						listener.Invalidated(currentSession)
-					}
-				}()
-			}
-		}
+	for sessionId, currentSession := range p.sessions {
 		if currentSession.Invalidated() {
 			delete(p.sessions, sessionId)
-			go func() {
-				if listener != nil && listener.Destroyed != nil {
-					listener.Destroyed(currentSession)
-				}
-			}()
+			p.emit(EventDestroyed, sessionId)
+			destroyed = append(destroyed, currentSession)
 		}
 	}
+	p.mu.Unlock()
+	if listener == nil || listener.Destroyed == nil {
+		return
+	}
+	for _, currentSession := range destroyed {
+		currentSession := currentSession
+		p.dispatch(currentSession.Id(), func() { listener.Destroyed(currentSession) })
+	}
 }
@@ -12,8 +12,11 @@
 package rsn
 
 import (
+	"context"
 	"net/http"
 	"os"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -55,11 +58,71 @@ func TestProviderCookieName(t *testing.T) {
 	require.Equal(t, "GOSESSID", p.CookieName())
 }
 
+func TestProviderClient(t *testing.T) {
+	p := ProviderWithPrefixKey(redisOptions, "_client_:")
+	commands := make([]string, 0)
+	p.Client().WrapProcess(func(old func(cmd rds.Cmder) error) func(cmd rds.Cmder) error {
+		return func(cmd rds.Cmder) error {
+			commands = append(commands, cmd.Name())
+			return old(cmd)
+		}
+	})
+	currSession := p.New(&s.Config{Valid: time.Minute}, nil)
+	require.Contains(t, commands, "hmset")
+	p.Del(currSession.Id())
+}
+
+func TestProviderPoolStats(t *testing.T) {
+	p := Provider(redisOptions)
+	stats := p.PoolStats()
+	require.NotNil(t, stats)
+	require.True(t, stats.TotalConns > 0)
+}
+
 func TestProviderGetId(t *testing.T) {
 	p := Provider(redisOptions)
 	req, _ := http.NewRequest("", "", nil)
-	req.AddCookie(&http.Cookie{Name: p.CookieName(), Value: "hello---cookie---"})
-	require.Equal(t, "hello---cookie---", p.GetId(req))
+	req.AddCookie(&http.Cookie{Name: p.CookieName(), Value: "0123456789ABCDEF0123456789ABCDEF"})
+	require.Equal(t, "0123456789ABCDEF0123456789ABCDEF", p.GetId(req))
+}
+
+func TestProviderGetIdRejectsMalformed(t *testing.T) {
+	p := Provider(redisOptions)
+	for _, id := range []string{"hello---cookie---", "0123456789abcdef0123456789abcdef", strings.Repeat("A", 4096)} {
+		req, _ := http.NewRequest("", "", nil)
+		req.AddCookie(&http.Cookie{Name: p.CookieName(), Value: id})
+		require.Equal(t, "", p.GetId(req))
+	}
+	require.Equal(t, uint64(3), p.RejectedIds())
+
+	custom := ProviderWithOptions(redisOptions, WithIdValidator(func(id string) bool { return strings.HasPrefix(id, "s-") }))
+	req, _ := http.NewRequest("", "", nil)
+	req.AddCookie(&http.Cookie{Name: custom.CookieName(), Value: "s-1"})
+	require.Equal(t, "s-1", custom.GetId(req))
+}
+
+func TestNewSIDConcurrent(t *testing.T) {
+	const goroutines, each = 16, 200
+	ids := make(chan string, goroutines*each)
+	var wg sync.WaitGroup
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < each; j++ {
+				ids <- newSID()
+			}
+		}()
+	}
+	wg.Wait()
+	close(ids)
+	p := Provider(redisOptions)
+	seen := make(map[string]struct{}, goroutines*each)
+	for id := range ids {
+		require.True(t, p.validId(id))
+		seen[id] = struct{}{}
+	}
+	require.Len(t, seen, goroutines*each)
 }
 
 func TestProviderExists(t *testing.T) {
@@ -70,7 +133,7 @@ func TestProviderExists(t *testing.T) {
 	defer func() {
 		_ = c.Close()
 	}()
-	hGetCmd := c.HGet("session:"+currSession.Id(), sessionIdName)
+	hGetCmd := c.HGet("session:"+currSession.Id(), p.field(sessionIdName))
 	if hGetCmd.Err() != nil {
 		require.Error(t, hGetCmd.Err())
 		return
@@ -78,13 +141,35 @@ func TestProviderExists(t *testing.T) {
 	require.Equal(t, currSession.Id(), hGetCmd.Val())
 }
 
+func TestProviderExistsConsultsRedis(t *testing.T) {
+	p := ProviderWithPrefixKey(redisOptions, "_exists_:")
+	peer := ProviderWithPrefixKey(redisOptions, "_exists_:")
+	currSession := p.New(&s.Config{Valid: time.Minute}, nil)
+	require.True(t, peer.Exists(currSession.Id()))
+	require.NotNil(t, peer.Get(currSession.Id()))
+
+	require.NoError(t, p.client.Del(p.getRedisKey(currSession.Id())).Err())
+	require.False(t, p.Exists(currSession.Id()))
+	require.True(t, currSession.Invalidated())
+	require.False(t, peer.Exists("missing"))
+}
+
+func TestProviderExistsLocalFastPath(t *testing.T) {
+	p := ProviderWithOptions(redisOptions, WithPrefixKey("_exists_fast_:"), WithLocalFastPath())
+	currSession := p.New(&s.Config{Valid: time.Minute}, nil)
+	require.NoError(t, p.client.Del(p.getRedisKey(currSession.Id())).Err())
+	require.True(t, p.Exists(currSession.Id()))
+	currSession.Invalidate()
+	require.False(t, p.Exists(currSession.Id()))
+}
+
 func TestProviderGet(t *testing.T) {
 	p := Provider(redisOptions)
 	c := rds.NewClient(redisOptions)
 	defer func() {
 		_ = c.Close()
 	}()
-	hSetCmd := c.HSet("session:xyz", sessionIdName, "xyz")
+	hSetCmd := c.HSet("session:xyz", p.field(sessionIdName), "xyz")
 	if hSetCmd.Err() != nil {
 		require.Error(t, hSetCmd.Err())
 		return
@@ -99,6 +184,18 @@ func TestProviderGet(t *testing.T) {
 	require.NotNil(t, p.Get("xyz"))
 }
 
+func TestProviderGetReadThrough(t *testing.T) {
+	p := ProviderWithPrefixKey(redisOptions, "_get_through_:")
+	peer := ProviderWithPrefixKey(redisOptions, "_get_through_:")
+	currSession := p.New(&s.Config{Valid: time.Minute}, nil).(*session)
+	require.NoError(t, currSession.BindUser("u1"))
+	peerSession := peer.Get(currSession.Id())
+	require.NotNil(t, peerSession)
+	require.Equal(t, "u1", peerSession.(*session).userId)
+	require.Same(t, peerSession, peer.Get(currSession.Id()))
+	require.Nil(t, peer.Get("missing"))
+}
+
 func TestProviderDel(t *testing.T) {
 	p := Provider(redisOptions)
 	currSession := p.New(&s.Config{Valid: time.Minute}, nil)
@@ -116,6 +213,42 @@ func TestProviderDel(t *testing.T) {
 	require.Zero(t, len(keysCmd.Val()))
 }
 
+func TestProviderDelete(t *testing.T) {
+	p := ProviderWithPrefixKey(redisOptions, "_delete_:")
+	destroyed := make(chan string, 1)
+	p.Clean(nil, &s.Listener{Destroyed: func(session s.Session) { destroyed <- session.Id() }})
+	currSession := p.New(&s.Config{Valid: time.Minute}, nil)
+	existed, err := p.Delete(currSession.Id())
+	require.Nil(t, err)
+	require.True(t, existed)
+	require.True(t, currSession.Invalidated())
+	require.Equal(t, currSession.Id(), <-destroyed)
+	existed, err = p.Delete(currSession.Id())
+	require.Nil(t, err)
+	require.False(t, existed)
+}
+
+func TestProviderCleanTwice(t *testing.T) {
+	p := ProviderWithPrefixKey(redisOptions, "_clean_twice_:")
+	peer := ProviderWithPrefixKey(redisOptions, "_clean_twice_:")
+	invalidated := make(chan string, 2)
+	listener := &s.Listener{Invalidated: func(session s.Session) { invalidated <- session.Id() }}
+	p.Clean(nil, listener)
+	p.Clean(nil, nil)
+	require.Same(t, listener, p.cleaningListener())
+	currSession := p.New(&s.Config{Valid: time.Minute}, nil)
+	time.Sleep(100 * time.Millisecond)
+	existed, err := peer.Delete(currSession.Id())
+	require.Nil(t, err)
+	require.True(t, existed)
+	require.Equal(t, currSession.Id(), <-invalidated)
+	select {
+	case id := <-invalidated:
+		t.Fatalf("invalidated %s twice", id)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
 func TestProviderGetAll(t *testing.T) {
 	p := Provider(redisOptions)
 	p.Clear()
@@ -148,3 +281,26 @@ func TestProviderClear(t *testing.T) {
 	require.Equal(t, len(keysCmd.Val()), len(p.GetAll()))
 	require.Equal(t, 0, len(p.GetAll()))
 }
+
+func TestProviderKeyFunc(t *testing.T) {
+	keyFunc := func(id string) string { return "_keyfunc_:{" + id + "}" }
+	p := ProviderWithOptions(redisOptions, WithPrefixKey("_keyfunc_prefix_:"), WithKeyFunc(keyFunc))
+	p.Clear()
+	currSession := p.New(&s.Config{Valid: time.Minute}, nil)
+	require.Equal(t, int64(1), p.client.Exists("_keyfunc_:{"+currSession.Id()+"}").Val())
+	tenant, err := p.ForTenant("acme")
+	require.NoError(t, err)
+	tenantSession := tenant.New(&s.Config{Valid: time.Minute}, nil)
+	require.Equal(t, int64(1), p.client.Exists("_keyfunc_:{acme:"+tenantSession.Id()+"}").Val())
+
+	ids, _, err := p.List(context.Background(), 0, 100)
+	require.NoError(t, err)
+	require.Equal(t, []string{currSession.Id()}, ids)
+	ids, _, err = tenant.List(context.Background(), 0, 100)
+	require.NoError(t, err)
+	require.Equal(t, []string{tenantSession.Id()}, ids)
+	_, own := p.ownId("_keyfunc_:other")
+	require.False(t, own)
+	p.Del(currSession.Id())
+	tenant.Del(tenantSession.Id())
+}
@@ -0,0 +1,127 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// publishBuffer the events waiting for a publisher beyond which new ones are dropped
+const publishBuffer = 1024
+
+// ErrEventDropped reported when an event is dropped because a publisher falls behind
+var ErrEventDropped = errors.New("rsn: event dropped")
+
+// lifecycleEvents the names of the events given to publishers
+var lifecycleEvents = map[EventKind]string{
+	EventCreated:     "created",
+	EventInvalidated: "invalidated",
+	EventDestroyed:   "destroyed",
+}
+
+// EventPublisher publish the Created, Invalidated and Destroyed events of a provider to an event
+// pipeline, e.g. NATSPublisher or KafkaPublisher. Publish is called from one goroutine per publisher,
+// in the order of the events.
+type EventPublisher interface {
+	Publish(e Event) error
+}
+
+// EventPayload the JSON body of the events given to publishers
+type EventPayload struct {
+	Event     string    `json:"event"`
+	SessionId string    `json:"sessionId"`
+	Time      time.Time `json:"time"`
+}
+
+// MarshalEvent return the JSON EventPayload of e
+func MarshalEvent(e Event) ([]byte, error) {
+	return json.Marshal(EventPayload{Event: lifecycleEvents[e.Kind], SessionId: e.SessionId, Time: e.Time})
+}
+
+// NATSConn the method of *nats.Conn used by NATSPublisher
+type NATSConn interface {
+	Publish(subject string, data []byte) error
+}
+
+// NATSPublisher publish events to Conn under Subject followed by the event name, e.g. sessions.created
+type NATSPublisher struct {
+	Conn    NATSConn
+	Subject string
+}
+
+// Publish e
+func (np *NATSPublisher) Publish(e Event) error {
+	data, err := MarshalEvent(e)
+	if err != nil {
+		return err
+	}
+	return np.Conn.Publish(np.Subject+"."+lifecycleEvents[e.Kind], data)
+}
+
+// KafkaPublisher publish events to Topic through Produce, keyed by session id so the events of a session
+// keep their order. Produce adapts the client of choice, e.g. the WriteMessages of a kafka-go Writer
+// or the SendMessage of a sarama SyncProducer.
+type KafkaPublisher struct {
+	Produce func(topic string, key, value []byte) error
+	Topic   string
+}
+
+// Publish e
+func (kp *KafkaPublisher) Publish(e Event) error {
+	data, err := MarshalEvent(e)
+	if err != nil {
+		return err
+	}
+	return kp.Produce(kp.Topic, []byte(e.SessionId), data)
+}
+
+// eventQueue give the events queued to its publisher one by one, in order
+type eventQueue struct {
+	publisher EventPublisher
+	queue     chan Event
+	report    func(err error)
+}
+
+func newEventQueue(publisher EventPublisher) *eventQueue {
+	return &eventQueue{publisher: publisher, queue: make(chan Event, publishBuffer)}
+}
+
+// enqueue queue e when it is a lifecycle event, dropping it when the queue is full
+func (q *eventQueue) enqueue(e Event) {
+	if _, have := lifecycleEvents[e.Kind]; !have {
+		return
+	}
+	select {
+	case q.queue <- e:
+	default:
+		q.report(fmt.Errorf("%w: %s of session %s", ErrEventDropped, lifecycleEvents[e.Kind], e.SessionId))
+	}
+}
+
+// run publish the events queued, for ever
+func (q *eventQueue) run() {
+	for e := range q.queue {
+		if err := q.publisher.Publish(e); err != nil {
+			q.report(err)
+		}
+	}
+}
+
+// publish give e to the publishers of WithEventPublisher and WithWebhook
+func (p *provider) publish(e Event) {
+	for _, q := range p.publishers {
+		q.enqueue(e)
+	}
+}
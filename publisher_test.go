@@ -0,0 +1,77 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	s "github.com/go-the-way/anoweb/session"
+
+	"github.com/stretchr/testify/require"
+)
+
+type natsConn struct {
+	mu       sync.Mutex
+	subjects []string
+}
+
+func (c *natsConn) Publish(subject string, _ []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.subjects = append(c.subjects, subject)
+	return nil
+}
+
+func (c *natsConn) published() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.subjects...)
+}
+
+func TestProviderEventPublisher(t *testing.T) {
+	conn := &natsConn{}
+	var (
+		mu       sync.Mutex
+		keys     []string
+		payloads []EventPayload
+	)
+	kafka := &KafkaPublisher{Topic: "sessions", Produce: func(topic string, key, value []byte) error {
+		mu.Lock()
+		defer mu.Unlock()
+		require.Equal(t, "sessions", topic)
+		var payload EventPayload
+		require.Nil(t, json.Unmarshal(value, &payload))
+		keys = append(keys, string(key))
+		payloads = append(payloads, payload)
+		return nil
+	}}
+	p := ProviderWithOptions(redisOptions, WithPrefixKey("_publisher_:"),
+		WithEventPublisher(&NATSPublisher{Conn: conn, Subject: "sessions"}), WithEventPublisher(kafka))
+	currSession := p.New(&s.Config{Valid: time.Minute}, nil)
+	p.Del(currSession.Id())
+	require.Eventually(t, func() bool {
+		return len(conn.published()) == 3
+	}, time.Second, time.Millisecond*10)
+	require.Equal(t, []string{"sessions.created", "sessions.invalidated", "sessions.destroyed"}, conn.published())
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(payloads) == 3
+	}, time.Second, time.Millisecond*10)
+	mu.Lock()
+	defer mu.Unlock()
+	require.Equal(t, []string{currSession.Id(), currSession.Id(), currSession.Id()}, keys)
+	require.Equal(t, "created", payloads[0].Event)
+}
@@ -0,0 +1,68 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"fmt"
+	"os"
+
+	s "github.com/go-the-way/anoweb/session"
+)
+
+const invalidationChannelPrefix = "rsn-invalidate:"
+
+// getInvalidationChannel return the channel peers sharing keyPrefix announce deleted sessions on
+func (p *provider) getInvalidationChannel() string {
+	return invalidationChannelPrefix + p.keyPrefix
+}
+
+// publishInvalidation tell peer instances that session id no longer exists
+func (p *provider) publishInvalidation(id string) {
+	if err := p.client.Publish(p.getInvalidationChannel(), id).Err(); err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+	}
+}
+
+// subscribeInvalidation drop sessions deleted by peer instances from the local sessions
+func (p *provider) subscribeInvalidation() {
+	pubSub := p.client.Subscribe(p.getInvalidationChannel())
+	go func() {
+		for msg := range pubSub.Channel() {
+			p.invalidateLocal(msg.Payload, p.cleaningListener())
+		}
+	}()
+}
+
+// invalidateLocal invalidate and forget the local session of id
+func (p *provider) invalidateLocal(id string, listener *s.Listener) {
+	p.revoked(id)
+	p.mu.Lock()
+	currentSession, have := p.sessions[id]
+	if have {
+		delete(p.sessions, id)
+	}
+	p.mu.Unlock()
+	if !have {
+		return
+	}
+	markInvalidated(currentSession)
+	p.emit(EventInvalidated, id)
+	p.emit(EventDestroyed, id)
+	p.dispatch(id, func() {
+		if listener != nil && listener.Invalidated != nil {
+			listener.Invalidated(currentSession)
+		}
+		if listener != nil && listener.Destroyed != nil {
+			listener.Destroyed(currentSession)
+		}
+	})
+}
@@ -0,0 +1,139 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"context"
+	"strings"
+
+	r "github.com/go-redis/redis"
+)
+
+// PurgeReport list what Purge deleted for a user
+type PurgeReport struct {
+	UserId string
+	// Sessions ids of deleted live sessions
+	Sessions []string
+	// Tombstones ids of deleted soft deleted sessions
+	Tombstones []string
+	// IndexEntries number of field index and expiry index entries removed
+	IndexEntries int64
+	// Pointers number of regeneration pointers removed
+	Pointers int64
+	// ArchivedCopies number of archived copies removed by an Archiver implementing ArchivePurger
+	ArchivedCopies int
+}
+
+// Purge erase every trace of userId: its sessions, their tombstones, index entries,
+// regeneration pointers and archived copies, and the user index itself. It is meant for data-erasure requests,
+// so soft delete is bypassed and tombstones are found by scanning.
+func (p *provider) Purge(ctx context.Context, userId string) (*PurgeReport, error) {
+	report := &PurgeReport{UserId: userId, Sessions: []string{}, Tombstones: []string{}}
+	client := p.client.WithContext(ctx)
+	ids, err := client.SMembers(p.getUserKey(userId)).Result()
+	if err != nil {
+		return nil, wrapErr(err)
+	}
+	for _, id := range ids {
+		if err = ctx.Err(); err != nil {
+			return report, wrapErr(err)
+		}
+		owner, err := client.HGet(p.getRedisKey(id), p.field(userIdName)).Result()
+		if err == r.Nil || (err == nil && owner != userId) {
+			continue
+		}
+		if err != nil {
+			return report, wrapErr(err)
+		}
+		if err = p.purgeKey(client, report, id, p.getRedisKey(id)); err != nil {
+			return report, wrapErr(err)
+		}
+		report.Sessions = append(report.Sessions, id)
+		p.invalidateLocal(id, nil)
+		p.publishInvalidation(id)
+	}
+	if err = client.Del(p.getUserKey(userId)).Err(); err != nil {
+		return report, wrapErr(err)
+	}
+	if purger, ok := p.archiver.(ArchivePurger); ok {
+		if report.ArchivedCopies, err = purger.PurgeUser(userId); err != nil {
+			return report, wrapErr(err)
+		}
+	}
+	cursor := uint64(0)
+	tombstonePrefix := p.getTombstoneKey("")
+	for {
+		keys, next, err := p.scans().WithContext(ctx).Scan(cursor, tombstonePrefix+"*", p.scanCount()).Result()
+		if err != nil {
+			return report, wrapErr(err)
+		}
+		for _, key := range keys {
+			owner, err := client.HGet(key, p.field(userIdName)).Result()
+			if err == r.Nil || (err == nil && owner != userId) {
+				continue
+			}
+			if err != nil {
+				return report, wrapErr(err)
+			}
+			id := strings.TrimPrefix(key, tombstonePrefix)
+			if strings.Contains(id, tenantSeparator) {
+				continue
+			}
+			if err = p.purgeKey(client, report, id, key); err != nil {
+				return report, wrapErr(err)
+			}
+			report.Tombstones = append(report.Tombstones, id)
+		}
+		if next == 0 {
+			return report, nil
+		}
+		cursor = next
+	}
+}
+
+// purgeKey delete the session hash at key along with the document, index entries and pointers of session id
+func (p *provider) purgeKey(client *r.Client, report *PurgeReport, id, key string) error {
+	values, err := client.HGetAll(key).Result()
+	if err != nil {
+		return err
+	}
+	indexCmds := make([]*r.IntCmd, 0)
+	pointerCmds := make([]*r.IntCmd, 0)
+	_, err = client.TxPipelined(func(pipe r.Pipeliner) error {
+		for field := range p.indexes {
+			if value, have := values[field]; have {
+				indexCmds = append(indexCmds, pipe.SRem(p.getIndexKey(field, value), id))
+			}
+		}
+		indexCmds = append(indexCmds, pipe.ZRem(p.getExpiryKey(), id))
+		pointerCmds = append(pointerCmds, pipe.Del(p.getSuccessorKey(id)))
+		if lineage := values[p.field(lineageName)]; lineage != "" {
+			for _, oldId := range strings.Split(lineage, ",") {
+				pointerCmds = append(pointerCmds, pipe.Del(p.getSuccessorKey(oldId)))
+			}
+		}
+		pipe.Del(p.getFieldsKey(id))
+		pipe.Del(p.getDocumentKey(id))
+		pipe.Del(key)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, cmd := range indexCmds {
+		report.IndexEntries += cmd.Val()
+	}
+	for _, cmd := range pointerCmds {
+		report.Pointers += cmd.Val()
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	s "github.com/go-the-way/anoweb/session"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProviderPurge(t *testing.T) {
+	p := ProviderWithOptions(redisOptions, WithPrefixKey("_purge_:"), WithIndex("role"),
+		WithSoftDelete(time.Minute), WithRegenerationGrace(time.Minute))
+	config := &s.Config{Valid: time.Minute}
+	live := p.New(config, nil).(Session)
+	require.Nil(t, live.BindUser("53"))
+	live.Set("role", "admin")
+	regenerated, err := p.Regenerate(live)
+	require.Nil(t, err)
+	deleted := p.New(config, nil).(Session)
+	require.Nil(t, deleted.BindUser("53"))
+	p.Del(deleted.Id())
+	other := p.New(config, nil).(Session)
+	require.Nil(t, other.BindUser("54"))
+
+	report, err := p.Purge(context.Background(), "53")
+	require.Nil(t, err)
+	require.Equal(t, []string{regenerated.Id()}, report.Sessions)
+	require.Equal(t, []string{deleted.Id()}, report.Tombstones)
+	require.Equal(t, int64(2), report.IndexEntries)
+	require.Equal(t, int64(1), report.Pointers)
+
+	require.Nil(t, p.Get(live.Id()))
+	require.False(t, p.Exists(regenerated.Id()))
+	require.Equal(t, ErrSessionNotFound, p.Restore(deleted.Id()))
+	require.Equal(t, int64(0), p.client.Exists(p.getUserKey("53")).Val())
+	require.True(t, p.Exists(other.Id()))
+	p.Del(other.Id())
+}
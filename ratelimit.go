@@ -0,0 +1,54 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+const rateLimitPrefixKey = "ratelimit-sessions:"
+
+// creationLimitScript take a token from the bucket of a client, refilled at ARGV[1] tokens
+// per millisecond up to ARGV[2], returning 0 when it is empty. ARGV[3] is the current time.
+var creationLimitScript = newScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local tokens = tonumber(redis.call('HGET', KEYS[1], 'tokens')) or burst
+local last = tonumber(redis.call('HGET', KEYS[1], 'last')) or now
+tokens = math.min(burst, tokens + math.max(0, now - last) * rate)
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'last', ARGV[3])
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst / rate) + 1000)
+return allowed
+`)
+
+// getRateLimitKey return the key of the token bucket of the client at ip
+func (p *provider) getRateLimitKey(ip string) string {
+	return rateLimitPrefixKey + p.keyPrefix + ip
+}
+
+// allowCreate report whether the client at ip may create one more session under the limit
+// set by WithCreationRateLimit. Sessions are allowed when redis fails, so an outage of the
+// limiter doesn't lock users out.
+func (p *provider) allowCreate(ip string) bool {
+	if p.creationRate <= 0 {
+		return true
+	}
+	allowed, err := creationLimitScript.Run(p.client, []string{p.getRateLimitKey(ip)},
+		p.creationRate/1000, p.creationBurst, formatTime(p.now())).Int64()
+	if err != nil {
+		p.report(err)
+		return true
+	}
+	return allowed == 1
+}
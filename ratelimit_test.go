@@ -0,0 +1,37 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"testing"
+	"time"
+
+	s "github.com/go-the-way/anoweb/session"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProviderCreationRateLimit(t *testing.T) {
+	clock := &fixedClock{time.Now()}
+	p := ProviderWithOptions(redisOptions, WithPrefixKey("_ratelimit_:"), WithClock(clock), WithCreationRateLimit(1, 2))
+	p.client.Del(p.getRateLimitKey("10.0.0.1"), p.getRateLimitKey("10.0.0.2"))
+	config := &s.Config{Valid: time.Minute}
+	bot := newRequestFrom("10.0.0.1:5000")
+	require.NotNil(t, p.NewWithRequest(bot, config, nil))
+	require.NotNil(t, p.NewWithRequest(bot, config, nil))
+	require.Nil(t, p.NewWithRequest(bot, config, nil))
+	require.NotNil(t, p.NewWithRequest(newRequestFrom("10.0.0.2:5000"), config, nil))
+
+	clock.now = clock.now.Add(time.Second)
+	require.NotNil(t, p.NewWithRequest(bot, config, nil))
+	require.Nil(t, p.NewWithRequest(bot, config, nil))
+}
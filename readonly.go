@@ -0,0 +1,140 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// ErrReadOnlySession returned when writing through a read-only session view
+var ErrReadOnlySession = errors.New("rsn: read-only session")
+
+// readOnlySession is a view of a session refusing writes
+type readOnlySession struct {
+	Session
+}
+
+// ReadOnly return a view of session whose mutating methods return ErrReadOnlySession,
+// or print it when they have no error result, leaving session untouched
+func ReadOnly(session Session) Session {
+	if view, ok := session.(*readOnlySession); ok {
+		return view
+	}
+	return &readOnlySession{Session: session}
+}
+
+// ReadOnly return a view of session refusing writes
+func (s *session) ReadOnly() Session {
+	return ReadOnly(s)
+}
+
+func refuseWrite() {
+	_, _ = fmt.Fprintln(os.Stderr, ErrReadOnlySession)
+}
+
+// ReadOnly return the view itself
+func (ro *readOnlySession) ReadOnly() Session {
+	return ro
+}
+
+// Renew refused
+func (ro *readOnlySession) Renew(time.Duration) {
+	refuseWrite()
+}
+
+// Invalidate refused
+func (ro *readOnlySession) Invalidate() {
+	refuseWrite()
+}
+
+// Set refused
+func (ro *readOnlySession) Set(string, interface{}) {
+	refuseWrite()
+}
+
+// SetAll refused
+func (ro *readOnlySession) SetAll(map[string]interface{}, bool) {
+	refuseWrite()
+}
+
+// Del refused
+func (ro *readOnlySession) Del(string) {
+	refuseWrite()
+}
+
+// Clear refused
+func (ro *readOnlySession) Clear() {
+	refuseWrite()
+}
+
+// BindUser refused
+func (ro *readOnlySession) BindUser(string) error {
+	return ErrReadOnlySession
+}
+
+// Touch refused
+func (ro *readOnlySession) Touch() error {
+	return ErrReadOnlySession
+}
+
+// Remember refused
+func (ro *readOnlySession) Remember() error {
+	return ErrReadOnlySession
+}
+
+// Authenticate refused
+func (ro *readOnlySession) Authenticate() error {
+	return ErrReadOnlySession
+}
+
+// Elevate refused
+func (ro *readOnlySession) Elevate(AuthLevel, time.Duration) error {
+	return ErrReadOnlySession
+}
+
+// SetPrincipal refused
+func (ro *readOnlySession) SetPrincipal(Principal) error {
+	return ErrReadOnlySession
+}
+
+// SetValue refused
+func (ro *readOnlySession) SetValue(string, interface{}) error {
+	return ErrReadOnlySession
+}
+
+// SetValues refused
+func (ro *readOnlySession) SetValues(map[string]interface{}, bool) error {
+	return ErrReadOnlySession
+}
+
+// SetWithTTL refused
+func (ro *readOnlySession) SetWithTTL(string, interface{}, time.Duration) error {
+	return ErrReadOnlySession
+}
+
+// SyncSet refused
+func (ro *readOnlySession) SyncSet(string, interface{}) error {
+	return ErrReadOnlySession
+}
+
+// SetDurable refused
+func (ro *readOnlySession) SetDurable(string, interface{}, int, time.Duration) error {
+	return ErrReadOnlySession
+}
+
+// Merge refused
+func (ro *readOnlySession) Merge(map[string]interface{}) error {
+	return ErrReadOnlySession
+}
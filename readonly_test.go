@@ -0,0 +1,47 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"testing"
+	"time"
+
+	s "github.com/go-the-way/anoweb/session"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionReadOnly(t *testing.T) {
+	p := ProviderWithPrefixKey(redisOptions, "_readonly_:")
+	currSession := p.New(&s.Config{Valid: time.Minute}, nil).(*session)
+	currSession.Set("apple", "100")
+	view := currSession.ReadOnly()
+	require.Same(t, view, view.ReadOnly())
+	require.Equal(t, currSession.Id(), view.Id())
+	require.Equal(t, "100", view.Get("apple"))
+
+	require.Equal(t, ErrReadOnlySession, view.SetValue("apple", "200"))
+	require.Equal(t, ErrReadOnlySession, view.SetValues(map[string]interface{}{"apple": "200"}, true))
+	require.Equal(t, ErrReadOnlySession, view.Merge(map[string]interface{}{}))
+	require.Equal(t, ErrReadOnlySession, view.SyncSet("apple", "200"))
+	require.Equal(t, ErrReadOnlySession, view.SetDurable("apple", "200", 1, time.Millisecond))
+	require.Equal(t, ErrReadOnlySession, view.BindUser("u1"))
+	require.Equal(t, ErrReadOnlySession, view.Touch())
+	require.Equal(t, ErrReadOnlySession, view.Authenticate())
+	view.Set("apple", "200")
+	view.Del("apple")
+	view.Clear()
+	view.Invalidate()
+	require.Equal(t, "100", currSession.Get("apple"))
+	require.False(t, currSession.Invalidated())
+	require.Empty(t, currSession.UserId())
+}
@@ -0,0 +1,207 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	r "github.com/go-redis/redis"
+)
+
+// ReconcileReport count the drifts Reconcile found and repaired between the local sessions,
+// the session keys and the indexes kept beside them
+type ReconcileReport struct {
+	// Stale local sessions whose key was gone, dropped
+	Stale int
+	// Unindexed sessions missing from the expiry index, added back
+	Unindexed int
+	// Unbound sessions bound to a user but missing from the index of the user, added back
+	Unbound int
+	// Dangling entries of the expiry index for sessions gone before expiring, removed
+	Dangling int
+}
+
+// Reconcile find and repair the drifts between the local sessions, the session keys and their indexes,
+// left behind by crashes, keys deleted or evicted behind the back of rsn or failed writes, and report
+// how many of each kind were repaired. Everything is walked in batches, and it stops when ctx is done.
+func (p *provider) Reconcile(ctx context.Context) (*ReconcileReport, error) {
+	report := &ReconcileReport{}
+	if err := p.reconcileLocal(ctx, report); err != nil {
+		return report, wrapErr(err)
+	}
+	if err := p.reconcileKeys(ctx, report); err != nil {
+		return report, wrapErr(err)
+	}
+	if err := p.reconcileExpiry(ctx, report); err != nil {
+		return report, wrapErr(err)
+	}
+	return report, nil
+}
+
+// reconcileLocal drop the local sessions whose key is gone
+func (p *provider) reconcileLocal(ctx context.Context, report *ReconcileReport) error {
+	p.mu.Lock()
+	ids := make([]string, 0, len(p.sessions))
+	for id, currentSession := range p.sessions {
+		if !currentSession.Invalidated() {
+			ids = append(ids, id)
+		}
+	}
+	listener := p.cleanListener
+	p.mu.Unlock()
+	client := p.reads().WithContext(ctx)
+	batchSize := int(p.scanCount())
+	for start := 0; start < len(ids); start += batchSize {
+		end := start + batchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		batch := ids[start:end]
+		exists := make([]*r.IntCmd, len(batch))
+		_, err := client.Pipelined(func(pipe r.Pipeliner) error {
+			for i, id := range batch {
+				exists[i] = pipe.Exists(p.getRedisKey(id))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for i, id := range batch {
+			if exists[i].Val() == 0 {
+				p.invalidateLocal(id, listener)
+				report.Stale++
+			}
+		}
+	}
+	return nil
+}
+
+// reconcileKeys add the session keys missing from the expiry index or the index of their user
+func (p *provider) reconcileKeys(ctx context.Context, report *ReconcileReport) error {
+	client := p.scans().WithContext(ctx)
+	cursor := uint64(0)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		keys, next, err := client.Scan(cursor, p.getKeyPattern("*"), p.scanCount()).Result()
+		if err != nil {
+			return err
+		}
+		if err = p.reindex(client, keys, report); err != nil {
+			return err
+		}
+		if next == 0 {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// reindex add the sessions of keys missing from the expiry index or the index of their user
+func (p *provider) reindex(client *r.Client, keys []string, report *ReconcileReport) error {
+	ids := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if id, own := p.ownId(key); own {
+			ids = append(ids, id)
+		}
+	}
+	scores := make([]*r.FloatCmd, len(ids))
+	ttls := make([]*r.DurationCmd, len(ids))
+	users := make([]*r.StringCmd, len(ids))
+	_, err := client.Pipelined(func(pipe r.Pipeliner) error {
+		for i, id := range ids {
+			scores[i] = pipe.ZScore(p.getExpiryKey(), id)
+			ttls[i] = pipe.PTTL(p.getRedisKey(id))
+			users[i] = pipe.HGet(p.getRedisKey(id), p.field(userIdName))
+		}
+		return nil
+	})
+	if err != nil && err != r.Nil {
+		return err
+	}
+	bound := make([]*r.BoolCmd, len(ids))
+	_, err = client.Pipelined(func(pipe r.Pipeliner) error {
+		for i, id := range ids {
+			if userId := users[i].Val(); userId != "" {
+				bound[i] = pipe.SIsMember(p.getUserKey(userId), id)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	_, err = p.client.Pipelined(func(pipe r.Pipeliner) error {
+		now := p.now()
+		for i, id := range ids {
+			if ttl := ttls[i].Val(); scores[i].Err() == r.Nil && ttl > 0 {
+				pipe.ZAdd(p.getExpiryKey(), r.Z{Score: float64(now.Add(ttl).UnixNano() / int64(time.Millisecond)), Member: id})
+				report.Unindexed++
+			}
+			if bound[i] != nil && !bound[i].Val() {
+				pipe.SAdd(p.getUserKey(users[i].Val()), id)
+				report.Unbound++
+			}
+		}
+		return nil
+	})
+	return err
+}
+
+// reconcileExpiry remove the entries of the expiry index not due yet whose session key is gone.
+// Due entries are left to the cleaning pass, which fires the listeners.
+func (p *provider) reconcileExpiry(ctx context.Context, report *ReconcileReport) error {
+	client := p.scans().WithContext(ctx)
+	now := float64(p.now().UnixNano() / int64(time.Millisecond))
+	cursor := uint64(0)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		members, next, err := client.ZScan(p.getExpiryKey(), cursor, "*", p.scanCount()).Result()
+		if err != nil {
+			return err
+		}
+		ids := make([]string, 0, len(members)/2)
+		for i := 0; i+1 < len(members); i += 2 {
+			if score, err := strconv.ParseFloat(members[i+1], 64); err == nil && score > now {
+				ids = append(ids, members[i])
+			}
+		}
+		exists := make([]*r.IntCmd, len(ids))
+		_, err = client.Pipelined(func(pipe r.Pipeliner) error {
+			for i, id := range ids {
+				exists[i] = pipe.Exists(p.getRedisKey(id))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for i, id := range ids {
+			if exists[i].Val() == 0 {
+				if err = p.client.ZRem(p.getExpiryKey(), id).Err(); err != nil {
+					return err
+				}
+				report.Dangling++
+			}
+		}
+		if next == 0 {
+			return nil
+		}
+		cursor = next
+	}
+}
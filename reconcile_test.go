@@ -0,0 +1,49 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	rds "github.com/go-redis/redis"
+	s "github.com/go-the-way/anoweb/session"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProviderReconcile(t *testing.T) {
+	p := ProviderWithPrefixKey(redisOptions, "_reconcile_:")
+	p.Clear()
+	stale := p.New(&s.Config{Valid: time.Minute}, nil)
+	unindexed := p.New(&s.Config{Valid: time.Minute}, nil)
+	unbound := p.New(&s.Config{Valid: time.Minute}, nil)
+	require.NoError(t, unbound.(Session).BindUser("u1"))
+	p.client.Del(p.getRedisKey(stale.Id()))
+	p.client.ZRem(p.getExpiryKey(), unindexed.Id())
+	p.client.SRem(p.getUserKey("u1"), unbound.Id())
+	p.client.ZAdd(p.getExpiryKey(), rds.Z{Score: float64(p.now().Add(time.Hour).UnixNano() / int64(time.Millisecond)), Member: "gone"})
+
+	report, err := p.Reconcile(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, &ReconcileReport{Stale: 1, Unindexed: 1, Unbound: 1, Dangling: 2}, report)
+	require.True(t, stale.Invalidated())
+	require.NotZero(t, p.client.ZScore(p.getExpiryKey(), unindexed.Id()).Val())
+	require.True(t, p.client.SIsMember(p.getUserKey("u1"), unbound.Id()).Val())
+	require.Equal(t, rds.Nil, p.client.ZScore(p.getExpiryKey(), "gone").Err())
+
+	report, err = p.Reconcile(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, &ReconcileReport{}, report)
+	p.Clear()
+}
@@ -0,0 +1,101 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	r "github.com/go-redis/redis"
+)
+
+// CommandRecorder record the redis commands of the providers given it by WithCommandRecorder,
+// e.g. to debug unexpected session writes or to build golden files for integration tests.
+// In dry-run mode the commands changing data are recorded but not run, and answer empty results,
+// so callers expecting a result from them may fail.
+type CommandRecorder struct {
+	mu       sync.Mutex
+	commands []string
+	dryRun   bool
+	log      func(command string)
+}
+
+// NewCommandRecorder return a recorder, running no mutation if dryRun, passing every command
+// to log when not nil
+func NewCommandRecorder(dryRun bool, log func(command string)) *CommandRecorder {
+	return &CommandRecorder{dryRun: dryRun, log: log}
+}
+
+// Commands return the commands recorded since the last Reset, oldest first, each as its
+// space separated arguments
+func (rec *CommandRecorder) Commands() []string {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	return append([]string(nil), rec.commands...)
+}
+
+// Reset forget the commands recorded
+func (rec *CommandRecorder) Reset() {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rec.commands = nil
+}
+
+// record cmds, returning those to run
+func (rec *CommandRecorder) record(cmds []r.Cmder) []r.Cmder {
+	run := make([]r.Cmder, 0, len(cmds))
+	for _, cmd := range cmds {
+		args := make([]string, 0, len(cmd.Args()))
+		for _, arg := range cmd.Args() {
+			args = append(args, fmt.Sprint(arg))
+		}
+		command := strings.Join(args, " ")
+		rec.mu.Lock()
+		rec.commands = append(rec.commands, command)
+		rec.mu.Unlock()
+		if rec.log != nil {
+			rec.log(command)
+		}
+		if !rec.dryRun || !mutates(cmd.Name()) {
+			run = append(run, cmd)
+		}
+	}
+	return run
+}
+
+// mutates report whether the command name changes data, or has other side effects
+func mutates(name string) bool {
+	_, read := readCommands[name]
+	return !read || name == "publish"
+}
+
+// wrap record the commands and pipelines of client
+func (rec *CommandRecorder) wrap(client *r.Client) {
+	client.WrapProcess(func(old func(cmd r.Cmder) error) func(cmd r.Cmder) error {
+		return func(cmd r.Cmder) error {
+			if len(rec.record([]r.Cmder{cmd})) == 0 {
+				return nil
+			}
+			return old(cmd)
+		}
+	})
+	client.WrapProcessPipeline(func(old func(cmds []r.Cmder) error) func(cmds []r.Cmder) error {
+		return func(cmds []r.Cmder) error {
+			run := rec.record(cmds)
+			if len(run) == 0 {
+				return nil
+			}
+			return old(run)
+		}
+	})
+}
@@ -0,0 +1,61 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	s "github.com/go-the-way/anoweb/session"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProviderCommandRecorder(t *testing.T) {
+	var mu sync.Mutex
+	var logged []string
+	recorder := NewCommandRecorder(false, func(command string) {
+		mu.Lock()
+		defer mu.Unlock()
+		logged = append(logged, command)
+	})
+	p := ProviderWithOptions(redisOptions, WithPrefixKey("_recorder_:"), WithCommandRecorder(recorder))
+	currSession := p.New(&s.Config{Valid: time.Minute}, nil).(*session)
+	recorder.Reset()
+	currSession.Set("apple", "100")
+	require.Equal(t, "100", currSession.Get("apple"))
+	require.Equal(t, []string{
+		"hmset " + currSession.key + " apple 100",
+		"hget " + currSession.key + " apple",
+	}, recorder.Commands())
+	mu.Lock()
+	require.Contains(t, logged, "hget "+currSession.key+" apple")
+	mu.Unlock()
+	p.Del(currSession.Id())
+}
+
+func TestProviderCommandRecorderDryRun(t *testing.T) {
+	p := ProviderWithPrefixKey(redisOptions, "_recorder_:")
+	currSession := p.New(&s.Config{Valid: time.Minute}, nil)
+	currSession.Set("apple", "100")
+
+	recorder := NewCommandRecorder(true, nil)
+	dry := ProviderWithOptions(redisOptions, WithPrefixKey("_recorder_:"), WithCommandRecorder(recorder))
+	drySession := dry.Get(currSession.Id())
+	drySession.Set("apple", "200")
+	drySession.Del("apple")
+	require.Equal(t, "100", drySession.Get("apple"))
+	require.Contains(t, recorder.Commands(), "hmset "+p.getRedisKey(currSession.Id())+" apple 200")
+	require.Contains(t, recorder.Commands(), "hdel "+p.getRedisKey(currSession.Id())+" apple")
+	p.Del(currSession.Id())
+}
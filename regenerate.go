@@ -0,0 +1,170 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	r "github.com/go-redis/redis"
+
+	s "github.com/go-the-way/anoweb/session"
+)
+
+const (
+	successorPrefixKey = "regenerated-sessions:"
+	// maxLineage bound the number of previous ids kept in the lineage of a session
+	maxLineage = 16
+	// maxSuccessorHops bound how many regenerations Get follows from an old id
+	maxSuccessorHops = 4
+)
+
+// regenerateScript move a session hash, its document and its fields set to its new id, appending the old id
+// to its lineage, moving its expiry entry and its field expiries, and leaving a pointer from the old id
+// for the grace period
+var regenerateScript = newScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 or redis.call('EXISTS', KEYS[2]) == 1 then
+	return 0
+end
+redis.call('RENAME', KEYS[1], KEYS[2])
+if redis.call('EXISTS', KEYS[5]) == 1 then
+	redis.call('RENAME', KEYS[5], KEYS[6])
+end
+if redis.call('EXISTS', KEYS[7]) == 1 then
+	redis.call('RENAME', KEYS[7], KEYS[8])
+end
+local cursor = '0'
+repeat
+	local page = redis.call('ZSCAN', KEYS[9], cursor, 'MATCH', ARGV[1] .. ':*')
+	cursor = page[1]
+	for i = 1, #page[2], 2 do
+		local member = page[2][i]
+		redis.call('ZREM', KEYS[9], member)
+		redis.call('ZADD', KEYS[9], page[2][i + 1], ARGV[2] .. string.sub(member, #ARGV[1] + 1))
+	end
+until cursor == '0'
+local lineage = redis.call('HGET', KEYS[2], ARGV[4])
+if lineage then
+	lineage = lineage .. ',' .. ARGV[1]
+else
+	lineage = ARGV[1]
+end
+local kept = {}
+for id in string.gmatch(lineage, '[^,]+') do
+	table.insert(kept, id)
+end
+while #kept > tonumber(ARGV[6]) do
+	table.remove(kept, 1)
+end
+redis.call('HSET', KEYS[2], ARGV[3], ARGV[2], ARGV[4], table.concat(kept, ','))
+local score = redis.call('ZSCORE', KEYS[4], ARGV[1])
+redis.call('ZREM', KEYS[4], ARGV[1])
+if score then
+	redis.call('ZADD', KEYS[4], score, ARGV[2])
+end
+if tonumber(ARGV[5]) > 0 then
+	redis.call('SET', KEYS[3], ARGV[2], 'PX', ARGV[5])
+end
+return 1
+`)
+
+// getSuccessorKey return the key pointing from the old id of a regenerated session to its new id
+func (p *provider) getSuccessorKey(id string) string {
+	return successorPrefixKey + p.keyPrefix + id
+}
+
+// successor return the id session id was regenerated to within the grace period, or empty.
+// A successor regenerated by another instance is loaded from redis.
+func (p *provider) successor(id string) string {
+	if p.regenerationGrace <= 0 {
+		return ""
+	}
+	for hop := 0; hop < maxSuccessorHops; hop++ {
+		newId, err := p.client.Get(p.getSuccessorKey(id)).Result()
+		if err != nil {
+			if err != r.Nil {
+				_, _ = fmt.Fprintln(os.Stderr, err)
+			}
+			break
+		}
+		id = newId
+		p.mu.Lock()
+		_, have := p.sessions[id]
+		p.mu.Unlock()
+		if have || p.hydrate(id) != nil {
+			return id
+		}
+	}
+	return ""
+}
+
+// Regenerate move session to a new id, keeping its values, ttl, user binding and indexes.
+//
+// The returned session replaces the given one, whose cookie must be updated and which is
+// invalidated, so writes through it fail. With WithRegenerationGrace the old id keeps
+// resolving to the new session for a while.
+func (p *provider) Regenerate(session s.Session) (s.Session, error) {
+	oldId := session.Id()
+	newId := p.newSID()
+	keys := []string{p.getRedisKey(oldId), p.getRedisKey(newId), p.getSuccessorKey(oldId), p.getExpiryKey(),
+		p.getDocumentKey(oldId), p.getDocumentKey(newId), p.getFieldsKey(oldId), p.getFieldsKey(newId), p.getFieldExpiryKey()}
+	grace := int64(p.regenerationGrace / time.Millisecond)
+	moved, err := regenerateScript.Run(p.client, keys, oldId, newId, p.field(sessionIdName), p.field(lineageName), grace, maxLineage).Int64()
+	if err != nil {
+		return nil, wrapErr(err)
+	}
+	if moved == 0 {
+		return nil, ErrSessionNotFound
+	}
+	values, err := p.client.HGetAll(p.getRedisKey(newId)).Result()
+	if err != nil {
+		return nil, wrapErr(err)
+	}
+	_, err = p.client.Pipelined(func(pipe r.Pipeliner) error {
+		for field := range p.indexes {
+			if value, have := values[field]; have {
+				pipe.SRem(p.getIndexKey(field, value), oldId)
+				pipe.SAdd(p.getIndexKey(field, value), newId)
+			}
+		}
+		if userId, have := values[p.field(userIdName)]; have {
+			pipe.SRem(p.getUserKey(userId), oldId)
+			pipe.SAdd(p.getUserKey(userId), newId)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, wrapErr(err)
+	}
+	markInvalidated(session)
+	currentSession := newSession(p, newId)
+	currentSession.userId = values[p.field(userIdName)]
+	p.mu.Lock()
+	delete(p.sessions, oldId)
+	p.store(newId, currentSession)
+	p.mu.Unlock()
+	return currentSession, nil
+}
+
+// Lineage return the ids session had before being regenerated, oldest first
+func (s *session) Lineage() ([]string, error) {
+	lineage, err := s.client.HGet(s.key, s.provider.field(lineageName)).Result()
+	if err == r.Nil {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, wrapErr(err)
+	}
+	return strings.Split(lineage, ","), nil
+}
@@ -0,0 +1,110 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	s "github.com/go-the-way/anoweb/session"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProviderRegenerate(t *testing.T) {
+	p := ProviderWithOptions(redisOptions, WithPrefixKey("_regenerate_:"), WithRegenerationGrace(time.Minute))
+	first := p.New(&s.Config{Valid: time.Minute}, nil).(Session)
+	first.Set("apple", "100")
+	require.Nil(t, first.BindUser("52"))
+
+	second, err := p.Regenerate(first)
+	require.Nil(t, err)
+	require.NotEqual(t, first.Id(), second.Id())
+	require.Equal(t, "100", second.Get("apple"))
+	require.Equal(t, second.Id(), second.Get(p.field(sessionIdName)))
+	third, err := p.Regenerate(second)
+	require.Nil(t, err)
+
+	lineage, err := third.(Session).Lineage()
+	require.Nil(t, err)
+	require.Equal(t, []string{first.Id(), second.Id()}, lineage)
+	require.Equal(t, third.Id(), p.Get(first.Id()).Id())
+	require.True(t, p.Exists(second.Id()))
+
+	infos, err := p.SessionsByUser(context.Background(), "52")
+	require.Nil(t, err)
+	require.Equal(t, 1, len(infos))
+	require.Equal(t, third.Id(), infos[0].Id)
+	_, err = p.client.ZScore(p.getExpiryKey(), third.Id()).Result()
+	require.Nil(t, err)
+
+	_, err = p.Regenerate(first)
+	require.Equal(t, ErrSessionNotFound, err)
+	p.Del(third.Id())
+}
+
+func TestProviderRegenerateOldHandle(t *testing.T) {
+	p := ProviderWithOptions(redisOptions, WithPrefixKey("_regenerate_old_:"), WithMaxFields(10, true))
+	old := p.New(&s.Config{Valid: time.Minute}, nil).(Session)
+	old.Set("apple", "100")
+	require.Nil(t, old.SetWithTTL("otp", "123456", time.Minute))
+
+	regenerated, err := p.Regenerate(old)
+	require.Nil(t, err)
+	require.True(t, old.Invalidated())
+	require.Equal(t, ErrSessionNotFound, old.SetValue("apple", "200"))
+	require.Equal(t, ErrSessionNotFound, old.Authenticate())
+	require.Equal(t, int64(0), p.client.Exists(p.getRedisKey(old.Id())).Val())
+	require.Equal(t, "100", regenerated.Get("apple"))
+
+	require.Equal(t, int64(0), p.client.Exists(p.getFieldsKey(old.Id())).Val())
+	fields, err := p.client.ZRange(p.getFieldsKey(regenerated.Id()), 0, -1).Result()
+	require.Nil(t, err)
+	require.ElementsMatch(t, []string{"apple", "otp"}, fields)
+	if atomic.LoadInt32(&p.hashFieldTTL) == hashFieldTTLUnsupported {
+		members, err := p.client.ZRange(p.getFieldExpiryKey(), 0, -1).Result()
+		require.Nil(t, err)
+		require.Contains(t, members, regenerated.Id()+":otp")
+		require.NotContains(t, members, old.Id()+":otp")
+	}
+	p.Del(regenerated.Id())
+}
+
+func TestProviderRegenerateOtherInstance(t *testing.T) {
+	p := ProviderWithOptions(redisOptions, WithPrefixKey("_regenerate_:"), WithRegenerationGrace(time.Minute))
+	other := ProviderWithOptions(redisOptions, WithPrefixKey("_regenerate_:"), WithRegenerationGrace(time.Minute))
+	first := p.New(&s.Config{Valid: time.Minute}, nil)
+	second, err := p.Regenerate(first)
+	require.Nil(t, err)
+	defer p.Del(second.Id())
+
+	found := other.Get(first.Id())
+	require.NotNil(t, found)
+	require.Equal(t, second.Id(), found.Id())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_ = other.Get(first.Id())
+		}()
+		go func() {
+			defer wg.Done()
+			other.Del(other.New(&s.Config{Valid: time.Minute}, nil).Id())
+		}()
+	}
+	wg.Wait()
+}
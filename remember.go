@@ -0,0 +1,118 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	r "github.com/go-redis/redis"
+	s "github.com/go-the-way/anoweb/session"
+)
+
+var (
+	// ErrRememberMeDisabled returned by Remember when the provider has no remember-me tier
+	ErrRememberMeDisabled = errors.New("rsn: remember-me is not enabled")
+	// ErrReauthenticationRequired returned by RequireFresh when the last authentication is too old
+	ErrReauthenticationRequired = errors.New("rsn: re-authentication required")
+)
+
+// Remember move session to the remember-me tier set by WithRememberMe,
+// extending it to the remember-me lifetime
+func (s *session) Remember() error {
+	valid := s.provider.rememberValid
+	if valid <= 0 {
+		return ErrRememberMeDisabled
+	}
+	if s.invalidated {
+		return ErrSessionNotFound
+	}
+	err := s.client.HMSet(s.key, map[string]interface{}{
+		s.provider.field(rememberName): 1,
+		s.provider.field(lifeTimeName): int64(valid / time.Millisecond),
+	}).Err()
+	if err != nil {
+		return wrapErr(err)
+	}
+	_, err = s.provider.renew(s.id, valid, false)
+	return wrapErr(err)
+}
+
+// Remembered report whether session is in the remember-me tier
+func (s *session) Remembered() bool {
+	remembered, err := s.client.HExists(s.key, s.provider.field(rememberName)).Result()
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+	}
+	return remembered
+}
+
+// Authenticate record that the user of session just proved their identity, e.g. by password
+func (s *session) Authenticate() error {
+	if s.invalidated {
+		return ErrSessionNotFound
+	}
+	return s.provider.criticalWrite(s.client, func(pipe r.Pipeliner) {
+		pipe.HSet(s.key, s.provider.field(authAtName), formatTime(s.provider.now()))
+	})
+}
+
+// RequireFresh return ErrReauthenticationRequired when session is remembered and its last
+// Authenticate is older than the fresh duration of WithRememberMe. Sessions outside the
+// remember-me tier are always fresh.
+func (s *session) RequireFresh() error {
+	vals, err := s.client.HMGet(s.key, s.provider.field(rememberName), s.provider.field(authAtName)).Result()
+	if err != nil {
+		return wrapErr(err)
+	}
+	if vals[0] == nil {
+		return nil
+	}
+	authAt := parseTime(stringOf(vals[1]))
+	if authAt.IsZero() || s.provider.now().Sub(authAt) > s.provider.rememberFresh {
+		return ErrReauthenticationRequired
+	}
+	return nil
+}
+
+// Cookie return the session cookie to set on the response, with a Max-Age matching its tier
+func (p *provider) Cookie(session s.Session, config *s.Config) *http.Cookie {
+	valid := p.valid(config)
+	if rs, ok := session.(Session); ok && p.rememberValid > 0 && rs.Remembered() {
+		valid = p.rememberValid
+	}
+	return &http.Cookie{
+		Name:     p.CookieName(),
+		Value:    p.cookieValue(session.Id()),
+		Path:     "/",
+		Expires:  p.now().Add(valid),
+		MaxAge:   int(valid / time.Second),
+		HttpOnly: true,
+	}
+}
+
+// cookieCodec turn session ids into cookie values and back, for session stores shared with other frameworks
+type cookieCodec struct {
+	encode func(id string) string
+	decode func(value string) (string, bool)
+}
+
+// cookieValue return the session cookie value of id
+func (p *provider) cookieValue(id string) string {
+	if p.cookieCodec == nil {
+		return id
+	}
+	return p.cookieCodec.encode(id)
+}
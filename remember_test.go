@@ -0,0 +1,60 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"testing"
+	"time"
+
+	rds "github.com/go-redis/redis"
+
+	s "github.com/go-the-way/anoweb/session"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionRemember(t *testing.T) {
+	config := &s.Config{Valid: time.Minute}
+	{
+		p := ProviderWithPrefixKey(redisOptions, "_remember_:")
+		currSession := p.New(config, nil).(Session)
+		require.Equal(t, ErrRememberMeDisabled, currSession.Remember())
+		require.Nil(t, currSession.RequireFresh())
+		require.Equal(t, 60, p.Cookie(currSession, config).MaxAge)
+		p.Del(currSession.Id())
+	}
+	{
+		p := ProviderWithOptions(redisOptions, WithPrefixKey("_remember_:"), WithRememberMe(time.Hour, time.Minute))
+		currSession := p.New(config, nil).(Session)
+		require.False(t, currSession.Remembered())
+		require.Nil(t, currSession.Authenticate())
+		require.Nil(t, currSession.Remember())
+		require.True(t, currSession.Remembered())
+		require.Equal(t, 3600, p.Cookie(currSession, config).MaxAge)
+
+		p.Refresh(currSession, config, nil)
+		ttl, err := currSession.TTL()
+		require.Nil(t, err)
+		require.True(t, ttl > 59*time.Minute)
+		require.Nil(t, currSession.RequireFresh())
+
+		c := rds.NewClient(redisOptions)
+		defer func() {
+			_ = c.Close()
+		}()
+		require.Nil(t, c.HSet("_remember_:"+currSession.Id(), p.field(authAtName), formatTime(time.Now().Add(-2*time.Minute))).Err())
+		require.Equal(t, ErrReauthenticationRequired, currSession.RequireFresh())
+		require.Nil(t, currSession.Authenticate())
+		require.Nil(t, currSession.RequireFresh())
+		p.Del(currSession.Id())
+	}
+}
@@ -0,0 +1,54 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"sync/atomic"
+	"time"
+
+	r "github.com/go-redis/redis"
+)
+
+// readReplicas the clients session values are read from, taken in turn
+type readReplicas struct {
+	clients []*r.Client
+	next    uint32
+	// pin how long a session keeps reading from the primary after a write
+	pin time.Duration
+}
+
+func (rr *readReplicas) client() *r.Client {
+	n := atomic.AddUint32(&rr.next, 1)
+	return rr.clients[int(n)%len(rr.clients)]
+}
+
+// reader return the client to read the values of s from: a replica,
+// unless s was written within the read-your-writes pin. Writes of s still
+// queued by WithWriteBehind are flushed first.
+func (s *session) reader() *r.Client {
+	s.provider.writeBehind.settle(s.key)
+	replicas := s.provider.replicas
+	if replicas == nil || len(replicas.clients) == 0 {
+		return s.provider.reads()
+	}
+	if wrote := atomic.LoadInt64(&s.wroteAt); wrote > 0 && s.provider.now().Sub(time.Unix(0, wrote)) < replicas.pin {
+		return s.provider.reads()
+	}
+	return replicas.client()
+}
+
+// wrote record a write of the values of s, pinning its reads to the primary
+func (s *session) wrote() {
+	if s.provider.replicas != nil {
+		atomic.StoreInt64(&s.wroteAt, s.provider.now().UnixNano())
+	}
+}
@@ -0,0 +1,38 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"testing"
+	"time"
+
+	s "github.com/go-the-way/anoweb/session"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionReadReplicas(t *testing.T) {
+	// a replica which never catches up, so reads show where they were routed
+	replica := *redisOptions
+	replica.DB = 2
+	p := ProviderWithOptions(redisOptions, WithPrefixKey("_replica_:"), WithReadReplicas(time.Millisecond*50, &replica))
+	currSession := p.New(&s.Config{Valid: time.Minute}, nil)
+	currSession.Set("apple", "100")
+	require.Equal(t, "100", currSession.Get("apple"))
+	time.Sleep(time.Millisecond * 60)
+	require.Nil(t, currSession.Get("apple"))
+	require.Empty(t, currSession.GetAll())
+	currSession.Del("apple")
+	require.Equal(t, currSession.Id(), currSession.Get(p.field(sessionIdName)))
+	require.Nil(t, currSession.Get("apple"))
+	p.Del(currSession.Id())
+}
@@ -0,0 +1,47 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import "time"
+
+// resync scan session keys batch by batch every resyncInterval, for ever,
+// loading the sessions this instance doesn't know yet
+func (p *provider) resync() {
+	cursor := uint64(0)
+	for {
+		time.Sleep(p.resyncInterval)
+		cursor = p.resyncStep(cursor)
+	}
+}
+
+// resyncStep load the unknown sessions among the next batch of keys from cursor,
+// and return the cursor to continue from, 0 starting a new pass
+func (p *provider) resyncStep(cursor uint64) uint64 {
+	keys, next, err := p.scans().Scan(cursor, p.getKeyPattern("*"), p.resyncBatch).Result()
+	if err != nil {
+		p.report(err)
+		return cursor
+	}
+	for _, key := range keys {
+		id, own := p.ownId(key)
+		if !own {
+			continue
+		}
+		p.mu.Lock()
+		_, have := p.sessions[id]
+		p.mu.Unlock()
+		if !have {
+			p.hydrate(id)
+		}
+	}
+	return next
+}
@@ -0,0 +1,49 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"testing"
+	"time"
+
+	s "github.com/go-the-way/anoweb/session"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProviderResync(t *testing.T) {
+	p := ProviderWithOptions(redisOptions, WithPrefixKey("_resync_:"), WithResync(time.Millisecond*10, 2))
+	peer := ProviderWithPrefixKey(redisOptions, "_resync_:")
+	p.Clean(nil, nil)
+	ids := make([]string, 0, 5)
+	for i := 0; i < 5; i++ {
+		ids = append(ids, peer.New(&s.Config{Valid: time.Minute}, nil).Id())
+	}
+	require.Eventually(t, func() bool {
+		all := p.GetAll()
+		for _, id := range ids {
+			if _, have := all[id]; !have {
+				return false
+			}
+		}
+		return true
+	}, time.Second*2, time.Millisecond*10)
+}
+
+func TestProviderResyncStep(t *testing.T) {
+	p := ProviderWithOptions(redisOptions, WithPrefixKey("_resync_step_:"), WithResync(time.Hour, 100))
+	peer := ProviderWithPrefixKey(redisOptions, "_resync_step_:")
+	id := peer.New(&s.Config{Valid: time.Minute}, nil).Id()
+	require.Equal(t, uint64(0), p.resyncStep(0))
+	_, have := p.GetAll()[id]
+	require.True(t, have)
+}
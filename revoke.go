@@ -0,0 +1,71 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// revokeWatchers the functions to call when a session ends, by session id
+type revokeWatchers struct {
+	mu       sync.Mutex
+	next     uint64
+	watchers map[string]map[uint64]func()
+}
+
+// OnRevoke call fn once session id ends: when it is deleted, expires, or is destroyed by another
+// instance, the latter requiring Clean to be running as it does under the anoweb middleware.
+// It is meant for long-lived connections such as websockets, which outlive the request that
+// checked the session. The returned cancel stops watching, e.g. when the connection closes first.
+func (p *provider) OnRevoke(id string, fn func()) (cancel func()) {
+	rw := p.revokeWatchers
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	rw.next++
+	n := rw.next
+	if rw.watchers[id] == nil {
+		rw.watchers[id] = map[uint64]func(){}
+	}
+	rw.watchers[id][n] = fn
+	return func() {
+		rw.mu.Lock()
+		defer rw.mu.Unlock()
+		delete(rw.watchers[id], n)
+		if len(rw.watchers[id]) == 0 {
+			delete(rw.watchers, id)
+		}
+	}
+}
+
+// BindConn close conn once session id ends, see OnRevoke
+func (p *provider) BindConn(id string, conn io.Closer) (unbind func()) {
+	return p.OnRevoke(id, func() {
+		if err := conn.Close(); err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, err)
+		}
+	})
+}
+
+// revoked call and forget the watchers of session id
+func (p *provider) revoked(id string) {
+	rw := p.revokeWatchers
+	rw.mu.Lock()
+	watchers := rw.watchers[id]
+	delete(rw.watchers, id)
+	rw.mu.Unlock()
+	for _, fn := range watchers {
+		go fn()
+	}
+}
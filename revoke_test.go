@@ -0,0 +1,61 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"testing"
+	"time"
+
+	s "github.com/go-the-way/anoweb/session"
+
+	"github.com/stretchr/testify/require"
+)
+
+type closer chan struct{}
+
+func (c closer) Close() error {
+	close(c)
+	return nil
+}
+
+func TestProviderOnRevoke(t *testing.T) {
+	p := ProviderWithPrefixKey(redisOptions, "_revoke_:")
+	currSession := p.New(&s.Config{Valid: time.Minute}, nil)
+	conn := make(closer)
+	p.BindConn(currSession.Id(), conn)
+	cancelled := false
+	cancel := p.OnRevoke(currSession.Id(), func() { cancelled = true })
+	cancel()
+	p.Del(currSession.Id())
+	select {
+	case <-conn:
+	case <-time.After(time.Second):
+		t.Fatal("conn not closed")
+	}
+	require.False(t, cancelled)
+}
+
+func TestProviderOnRevokeRemote(t *testing.T) {
+	p := ProviderWithPrefixKey(redisOptions, "_revoke_:")
+	p.Clean(nil, nil)
+	other := ProviderWithPrefixKey(redisOptions, "_revoke_:")
+	currSession := other.New(&s.Config{Valid: time.Minute}, nil)
+	revoked := make(chan struct{})
+	p.OnRevoke(currSession.Id(), func() { close(revoked) })
+	time.Sleep(time.Millisecond * 50)
+	other.destroy(currSession.Id())
+	select {
+	case <-revoked:
+	case <-time.After(time.Second):
+		t.Fatal("not revoked")
+	}
+}
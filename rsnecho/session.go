@@ -0,0 +1,97 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package rsnecho adapt rsn to Echo. It is a module of its own, so rsn doesn't depend on Echo.
+package rsnecho
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/go-the-way/rsn"
+	"github.com/labstack/echo/v4"
+
+	s "github.com/go-the-way/anoweb/session"
+)
+
+// sessionKey the key of the session in echo.Context
+const sessionKey = "github.com/go-the-way/rsn/session"
+
+// Option configure the middleware
+type Option func(m *middleware)
+
+type middleware struct {
+	skip []func(c echo.Context) bool
+}
+
+// SkipPaths create no session for requests whose path starts with one of prefixes, e.g. static assets
+func SkipPaths(prefixes ...string) Option {
+	return Skip(func(c echo.Context) bool {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(c.Request().URL.Path, prefix) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// Skip create no session for requests fn returns true for
+func Skip(fn func(c echo.Context) bool) Option {
+	return func(m *middleware) {
+		m.skip = append(m.skip, fn)
+	}
+}
+
+// Session return an echo middleware giving every request a session of provider, read with Default,
+// or with rsn.FromContext from the context of the request.
+// It behaves as rsn.Middleware: the session is loaded or created, its cookie set before the handler runs,
+// and it is refreshed once the handler returns. Legacy cookies of rsn.WithLegacyCookieNames are deleted along.
+func Session(provider rsn.SessionProvider, config *s.Config, opts ...Option) echo.MiddlewareFunc {
+	m := &middleware{}
+	for _, opt := range opts {
+		opt(m)
+	}
+	go provider.Clean(config, nil)
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			for _, skip := range m.skip {
+				if skip(c) {
+					return next(c)
+				}
+			}
+			currentSession, err := provider.GetOrCreate(c.Request(), config, nil)
+			if err == rsn.ErrUnknownSession {
+				return echo.ErrUnauthorized
+			}
+			if currentSession == nil {
+				return next(c)
+			}
+			http.SetCookie(c.Response(), provider.Cookie(currentSession, config))
+			for _, cookie := range provider.LegacyCookies(c.Request()) {
+				http.SetCookie(c.Response(), cookie)
+			}
+			c.Set(sessionKey, currentSession)
+			c.SetRequest(c.Request().WithContext(rsn.NewContext(c.Request().Context(), currentSession)))
+			err = next(c)
+			if !currentSession.Invalidated() {
+				provider.Refresh(currentSession, config, nil)
+			}
+			return err
+		}
+	}
+}
+
+// Default return the session of c, nil for skipped requests
+func Default(c echo.Context) s.Session {
+	currentSession, _ := c.Get(sessionKey).(s.Session)
+	return currentSession
+}
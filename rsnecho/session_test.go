@@ -0,0 +1,81 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsnecho
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-the-way/rsn"
+	"github.com/go-the-way/rsn/rsntest"
+	"github.com/labstack/echo/v4"
+
+	s "github.com/go-the-way/anoweb/session"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSession(t *testing.T) {
+	h := rsntest.New(t, rsn.WithPrefixKey("_echo_:"))
+	e := echo.New()
+	e.Use(Session(h.Provider, &s.Config{Valid: time.Minute}, SkipPaths("/static/")))
+	e.GET("/", func(c echo.Context) error {
+		Default(c).Set("user", "alice")
+		fromCtx, _ := rsn.FromContext(c.Request().Context())
+		require.Equal(t, Default(c), fromCtx)
+		return c.String(http.StatusOK, Default(c).Id())
+	})
+	e.GET("/static/app.js", func(c echo.Context) error {
+		require.Nil(t, Default(c))
+		return c.String(http.StatusOK, "")
+	})
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	cookie := rec.Result().Cookies()[0]
+	require.Equal(t, rec.Body.String(), cookie.Value)
+	require.Equal(t, "alice", h.Provider.Get(cookie.Value).Get("user"))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(cookie)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	require.Equal(t, cookie.Value, rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/static/app.js", nil))
+	require.Empty(t, rec.Result().Cookies())
+}
+
+func TestSessionLegacyCookie(t *testing.T) {
+	h := rsntest.New(t, rsn.WithPrefixKey("_echo_:"), rsn.WithCookieName("SID"), rsn.WithLegacyCookieNames("OLDSID"))
+	currSession := h.Provider.New(&s.Config{Valid: time.Minute}, nil)
+	e := echo.New()
+	e.Use(Session(h.Provider, &s.Config{Valid: time.Minute}))
+	e.GET("/", func(c echo.Context) error {
+		return c.String(http.StatusOK, Default(c).Id())
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(&http.Cookie{Name: "OLDSID", Value: currSession.Id()})
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	require.Equal(t, currSession.Id(), rec.Body.String())
+	cookies := rec.Result().Cookies()
+	require.Len(t, cookies, 2)
+	require.Equal(t, "SID", cookies[0].Name)
+	require.Equal(t, currSession.Id(), cookies[0].Value)
+	require.Equal(t, "OLDSID", cookies[1].Name)
+	require.Equal(t, -1, cookies[1].MaxAge)
+}
@@ -0,0 +1,13 @@
+module github.com/go-the-way/rsn/rsnfiber
+
+go 1.16
+
+replace github.com/go-the-way/rsn => ../
+
+require (
+	github.com/go-the-way/anoweb v1.0.0
+	github.com/go-the-way/rsn v0.0.0-00010101000000-000000000000
+	github.com/gofiber/fiber/v2 v2.30.0
+	github.com/stretchr/testify v1.7.0
+	github.com/valyala/fasthttp v1.34.0
+)
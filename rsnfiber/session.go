@@ -0,0 +1,126 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package rsnfiber adapt rsn to Fiber. It is a module of its own, so rsn doesn't depend on Fiber.
+package rsnfiber
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/go-the-way/rsn"
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp/fasthttpadaptor"
+
+	s "github.com/go-the-way/anoweb/session"
+)
+
+// sessionKey the key of the session in the locals of fiber.Ctx
+const sessionKey = "github.com/go-the-way/rsn/session"
+
+// Option configure the middleware
+type Option func(m *middleware)
+
+type middleware struct {
+	skip []func(c *fiber.Ctx) bool
+}
+
+// SkipPaths create no session for requests whose path starts with one of prefixes, e.g. static assets
+func SkipPaths(prefixes ...string) Option {
+	return Skip(func(c *fiber.Ctx) bool {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(c.Path(), prefix) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// Skip create no session for requests fn returns true for
+func Skip(fn func(c *fiber.Ctx) bool) Option {
+	return func(m *middleware) {
+		m.skip = append(m.skip, fn)
+	}
+}
+
+// Session return a fiber handler giving every request a session of provider, read with Default,
+// or with rsn.FromContext from the user context of c.
+// It behaves as rsn.Middleware: the session is loaded or created through GetOrCreate, its cookie set
+// before the next handlers run, and it is refreshed once they return. A request presenting an unknown id
+// under rsn.RejectUnknown gets fiber.ErrUnauthorized. Legacy cookies of rsn.WithLegacyCookieNames are deleted along.
+func Session(provider rsn.SessionProvider, config *s.Config, opts ...Option) fiber.Handler {
+	m := &middleware{}
+	for _, opt := range opts {
+		opt(m)
+	}
+	go provider.Clean(config, nil)
+	return func(c *fiber.Ctx) error {
+		for _, skip := range m.skip {
+			if skip(c) {
+				return c.Next()
+			}
+		}
+		// the request is copied, as fiber reuses its buffers once the handler returns
+		req := &http.Request{}
+		if err := fasthttpadaptor.ConvertRequest(c.Context(), req, true); err != nil {
+			return err
+		}
+		currentSession, err := provider.GetOrCreate(req.WithContext(c.UserContext()), config, nil)
+		if err == rsn.ErrUnknownSession {
+			return fiber.ErrUnauthorized
+		}
+		if currentSession == nil {
+			return c.Next()
+		}
+		c.Cookie(fiberCookie(provider.Cookie(currentSession, config)))
+		for _, cookie := range provider.LegacyCookies(req) {
+			c.Cookie(fiberCookie(cookie))
+		}
+		c.Locals(sessionKey, currentSession)
+		c.SetUserContext(rsn.NewContext(c.UserContext(), currentSession))
+		err = c.Next()
+		if !currentSession.Invalidated() {
+			provider.Refresh(currentSession, config, nil)
+		}
+		return err
+	}
+}
+
+// Default return the session of c, nil for skipped requests
+func Default(c *fiber.Ctx) s.Session {
+	currentSession, _ := c.Locals(sessionKey).(s.Session)
+	return currentSession
+}
+
+// fiberCookie convert cookie to its fiber form
+func fiberCookie(cookie *http.Cookie) *fiber.Cookie {
+	sameSite := ""
+	switch cookie.SameSite {
+	case http.SameSiteLaxMode:
+		sameSite = fiber.CookieSameSiteLaxMode
+	case http.SameSiteStrictMode:
+		sameSite = fiber.CookieSameSiteStrictMode
+	case http.SameSiteNoneMode:
+		sameSite = fiber.CookieSameSiteNoneMode
+	}
+	return &fiber.Cookie{
+		Name:     cookie.Name,
+		Value:    cookie.Value,
+		Path:     cookie.Path,
+		Domain:   cookie.Domain,
+		MaxAge:   cookie.MaxAge,
+		Expires:  cookie.Expires,
+		Secure:   cookie.Secure,
+		HTTPOnly: cookie.HttpOnly,
+		SameSite: sameSite,
+	}
+}
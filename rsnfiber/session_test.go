@@ -0,0 +1,133 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsnfiber
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-the-way/rsn"
+	"github.com/go-the-way/rsn/rsntest"
+	"github.com/gofiber/fiber/v2"
+
+	s "github.com/go-the-way/anoweb/session"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSession(t *testing.T) {
+	h := rsntest.New(t, rsn.WithPrefixKey("_fiber_:"))
+	app := fiber.New()
+	app.Use(Session(h.Provider, &s.Config{Valid: time.Minute}, SkipPaths("/static/")))
+	app.Get("/", func(c *fiber.Ctx) error {
+		Default(c).Set("user", "alice")
+		if fromCtx, _ := rsn.FromContext(c.UserContext()); fromCtx != Default(c) {
+			return c.SendStatus(http.StatusInternalServerError)
+		}
+		return c.SendString(Default(c).Id())
+	})
+	app.Get("/static/app.js", func(c *fiber.Ctx) error {
+		if Default(c) != nil {
+			return c.SendStatus(http.StatusInternalServerError)
+		}
+		return c.SendString("")
+	})
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Nil(t, err)
+	body, _ := ioutil.ReadAll(resp.Body)
+	cookie := resp.Cookies()[0]
+	require.Equal(t, string(body), cookie.Value)
+	require.True(t, cookie.HttpOnly)
+	require.Equal(t, "alice", h.Provider.Get(cookie.Value).Get("user"))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(cookie)
+	resp, err = app.Test(req)
+	require.Nil(t, err)
+	body, _ = ioutil.ReadAll(resp.Body)
+	require.Equal(t, cookie.Value, string(body))
+
+	resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/static/app.js", nil))
+	require.Nil(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Empty(t, resp.Cookies())
+}
+
+func TestSessionValidate(t *testing.T) {
+	h := rsntest.New(t, rsn.WithPrefixKey("_fiber_:"), rsn.WithFingerprint(nil, rsn.RejectMismatch))
+	config := &s.Config{Valid: time.Minute}
+	app := fiber.New()
+	app.Use(Session(h.Provider, config))
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString(Default(c).Id())
+	})
+	serve := func(userAgent string, cookie *http.Cookie) string {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("User-Agent", userAgent)
+		req.AddCookie(cookie)
+		resp, err := app.Test(req)
+		require.Nil(t, err)
+		body, _ := ioutil.ReadAll(resp.Body)
+		return string(body)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("User-Agent", "owner")
+	currSession := h.Provider.NewWithRequest(req, config, nil)
+	cookie := h.Provider.Cookie(currSession, config)
+	require.Equal(t, currSession.Id(), serve("owner", cookie))
+	require.NotEqual(t, currSession.Id(), serve("thief", cookie))
+	require.NotEqual(t, "a:b", serve("owner", &http.Cookie{Name: cookie.Name, Value: "a:b"}))
+}
+
+func TestSessionRejectUnknown(t *testing.T) {
+	h := rsntest.New(t, rsn.WithPrefixKey("_fiber_:"), rsn.WithUnknownIdPolicy(rsn.RejectUnknown))
+	app := fiber.New()
+	app.Use(Session(h.Provider, &s.Config{Valid: time.Minute}))
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString(Default(c).Id())
+	})
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(&http.Cookie{Name: h.Provider.CookieName(), Value: "0123456789ABCDEF0123456789ABCDEF"})
+	resp, err := app.Test(req)
+	require.Nil(t, err)
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestSessionLegacyCookie(t *testing.T) {
+	h := rsntest.New(t, rsn.WithPrefixKey("_fiber_:"), rsn.WithCookieName("SID"), rsn.WithLegacyCookieNames("OLDSID"))
+	currSession := h.Provider.New(&s.Config{Valid: time.Minute}, nil)
+	app := fiber.New()
+	app.Use(Session(h.Provider, &s.Config{Valid: time.Minute}))
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString(Default(c).Id())
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(&http.Cookie{Name: "OLDSID", Value: currSession.Id()})
+	resp, err := app.Test(req)
+	require.Nil(t, err)
+	body, _ := ioutil.ReadAll(resp.Body)
+	require.Equal(t, currSession.Id(), string(body))
+	cookies := map[string]*http.Cookie{}
+	for _, cookie := range resp.Cookies() {
+		cookies[cookie.Name] = cookie
+	}
+	require.Len(t, cookies, 2)
+	require.Equal(t, currSession.Id(), cookies["SID"].Value)
+	require.Empty(t, cookies["OLDSID"].Value)
+	require.True(t, cookies["OLDSID"].Expires.Before(time.Now()))
+}
@@ -0,0 +1,98 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package rsngin adapt rsn to Gin. It is a module of its own, so rsn doesn't depend on Gin.
+package rsngin
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-the-way/rsn"
+
+	s "github.com/go-the-way/anoweb/session"
+)
+
+// sessionKey the key of the session in gin.Context
+const sessionKey = "github.com/go-the-way/rsn/session"
+
+// Option configure the middleware
+type Option func(m *middleware)
+
+type middleware struct {
+	skip []func(c *gin.Context) bool
+}
+
+// SkipPaths create no session for requests whose path starts with one of prefixes, e.g. static assets
+func SkipPaths(prefixes ...string) Option {
+	return Skip(func(c *gin.Context) bool {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(c.Request.URL.Path, prefix) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// Skip create no session for requests fn returns true for
+func Skip(fn func(c *gin.Context) bool) Option {
+	return func(m *middleware) {
+		m.skip = append(m.skip, fn)
+	}
+}
+
+// Session return a gin middleware giving every request a session of provider, read with Default,
+// or with rsn.FromContext from the context of the request.
+// It behaves as rsn.Middleware: the session is loaded or created, its cookie set before the handlers run,
+// and it is refreshed once they return. Legacy cookies of rsn.WithLegacyCookieNames are deleted along.
+func Session(provider rsn.SessionProvider, config *s.Config, opts ...Option) gin.HandlerFunc {
+	m := &middleware{}
+	for _, opt := range opts {
+		opt(m)
+	}
+	go provider.Clean(config, nil)
+	return func(c *gin.Context) {
+		for _, skip := range m.skip {
+			if skip(c) {
+				c.Next()
+				return
+			}
+		}
+		currentSession, err := provider.GetOrCreate(c.Request, config, nil)
+		if err == rsn.ErrUnknownSession {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		if currentSession == nil {
+			c.Next()
+			return
+		}
+		http.SetCookie(c.Writer, provider.Cookie(currentSession, config))
+		for _, cookie := range provider.LegacyCookies(c.Request) {
+			http.SetCookie(c.Writer, cookie)
+		}
+		c.Set(sessionKey, currentSession)
+		c.Request = c.Request.WithContext(rsn.NewContext(c.Request.Context(), currentSession))
+		c.Next()
+		if !currentSession.Invalidated() {
+			provider.Refresh(currentSession, config, nil)
+		}
+	}
+}
+
+// Default return the session of c, nil for skipped requests
+func Default(c *gin.Context) s.Session {
+	val, _ := c.Get(sessionKey)
+	currentSession, _ := val.(s.Session)
+	return currentSession
+}
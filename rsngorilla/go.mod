@@ -0,0 +1,12 @@
+module github.com/go-the-way/rsn/rsngorilla
+
+go 1.16
+
+replace github.com/go-the-way/rsn => ../
+
+require (
+	github.com/go-the-way/anoweb v1.0.0
+	github.com/go-the-way/rsn v0.0.0-00010101000000-000000000000
+	github.com/gorilla/sessions v1.2.1
+	github.com/stretchr/testify v1.7.0
+)
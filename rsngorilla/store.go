@@ -0,0 +1,127 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package rsngorilla implement the gorilla/sessions Store on top of rsn,
+// so apps outside anoweb can keep their sessions in redis with the gorilla API.
+package rsngorilla
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-the-way/rsn"
+	"github.com/gorilla/sessions"
+
+	s "github.com/go-the-way/anoweb/session"
+)
+
+// valuesName the session field holding the gob encoded values of a gorilla session
+const valuesName = "gorilla"
+
+// defaultMaxAge the MaxAge of the options of NewStore, a day in seconds
+const defaultMaxAge = 86400
+
+// Store is a gorilla/sessions Store keeping each gorilla session in an rsn session.
+// The cookie holds the rsn session id, and the values are gob encoded into one field,
+// so types stored in them must be registered with gob as for the other gorilla stores.
+type Store struct {
+	Provider rsn.SessionProvider
+	Options  *sessions.Options
+}
+
+var _ sessions.Store = (*Store)(nil)
+
+// NewStore return new store keeping sessions in provider, for a day by default
+func NewStore(provider rsn.SessionProvider) *Store {
+	return &Store{
+		Provider: provider,
+		Options:  &sessions.Options{Path: "/", MaxAge: defaultMaxAge, HttpOnly: true},
+	}
+}
+
+// Get return the session of name registered for r, loading it on the first call
+func (st *Store) Get(r *http.Request, name string) (*sessions.Session, error) {
+	return sessions.GetRegistry(r).Get(st, name)
+}
+
+// New return the session of name loaded from redis, or a new one when the cookie of r names no live session
+// or holds a malformed id
+func (st *Store) New(r *http.Request, name string) (*sessions.Session, error) {
+	session := sessions.NewSession(st, name)
+	opts := *st.Options
+	session.Options = &opts
+	session.IsNew = true
+	cookie, err := r.Cookie(name)
+	if err != nil || !st.Provider.ValidId(cookie.Value) || !st.Provider.Exists(cookie.Value) {
+		return session, nil
+	}
+	currentSession := st.Provider.Get(cookie.Value)
+	if encoded, ok := currentSession.Get(valuesName).(string); ok {
+		err = gob.NewDecoder(bytes.NewBufferString(encoded)).Decode(&session.Values)
+		if err != nil {
+			return session, err
+		}
+	}
+	session.ID = currentSession.Id()
+	session.IsNew = false
+	return session, nil
+}
+
+// Save store session and set its cookie, or delete it when its MaxAge is negative.
+// A session whose MaxAge is 0, kept by a browser-session cookie, lives in redis as long as the MaxAge
+// of the store, a day when that is 0 too.
+func (st *Store) Save(_ *http.Request, w http.ResponseWriter, session *sessions.Session) error {
+	if session.Options.MaxAge < 0 {
+		if session.ID != "" {
+			st.Provider.Del(session.ID)
+		}
+		http.SetCookie(w, sessions.NewCookie(session.Name(), "", session.Options))
+		return nil
+	}
+	valid := time.Duration(st.maxAge(session)) * time.Second
+	currentSession := st.Provider.Get(session.ID)
+	if currentSession == nil {
+		currentSession = st.Provider.New(&s.Config{Valid: valid}, nil)
+		if currentSession == nil {
+			return fmt.Errorf("rsngorilla: can't create session %s", session.Name())
+		}
+		session.ID = currentSession.Id()
+	} else {
+		currentSession.Renew(valid)
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(session.Values); err != nil {
+		return err
+	}
+	if rs, ok := currentSession.(rsn.Session); ok {
+		if err := rs.SetValue(valuesName, buf.String()); err != nil {
+			return err
+		}
+	} else {
+		currentSession.Set(valuesName, buf.String())
+	}
+	http.SetCookie(w, sessions.NewCookie(session.Name(), session.ID, session.Options))
+	return nil
+}
+
+// maxAge return the MaxAge of session in seconds, the one of the store for a browser-session cookie
+func (st *Store) maxAge(session *sessions.Session) int {
+	if session.Options.MaxAge > 0 {
+		return session.Options.MaxAge
+	}
+	if st.Options.MaxAge > 0 {
+		return st.Options.MaxAge
+	}
+	return defaultMaxAge
+}
@@ -0,0 +1,103 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsngorilla
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-the-way/rsn"
+	"github.com/go-the-way/rsn/rsnmock"
+	"github.com/go-the-way/rsn/rsntest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore(t *testing.T) {
+	h := rsntest.New(t, rsn.WithPrefixKey("_gorilla_:"))
+	store := NewStore(h.Provider)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	session, err := store.Get(req, "app")
+	require.Nil(t, err)
+	require.True(t, session.IsNew)
+	session.Values["user"] = "alice"
+	session.Values[42] = 7
+	rec := httptest.NewRecorder()
+	require.Nil(t, session.Save(req, rec))
+	cookie := rec.Result().Cookies()[0]
+	require.Equal(t, "app", cookie.Name)
+	require.Equal(t, session.ID, cookie.Value)
+	require.True(t, h.Provider.Exists(session.ID))
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(cookie)
+	loaded, err := store.Get(req, "app")
+	require.Nil(t, err)
+	require.False(t, loaded.IsNew)
+	require.Equal(t, "alice", loaded.Values["user"])
+	require.Equal(t, 7, loaded.Values[42])
+
+	loaded.Options.MaxAge = -1
+	rec = httptest.NewRecorder()
+	require.Nil(t, loaded.Save(req, rec))
+	require.False(t, h.Provider.Exists(session.ID))
+	require.Equal(t, -1, rec.Result().Cookies()[0].MaxAge)
+}
+
+func TestStoreBrowserSession(t *testing.T) {
+	h := rsntest.New(t, rsn.WithPrefixKey("_gorilla_:"))
+	store := NewStore(h.Provider)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	session, err := store.Get(req, "app")
+	require.Nil(t, err)
+	session.Options.MaxAge = 0
+	session.Values["user"] = "alice"
+	rec := httptest.NewRecorder()
+	require.Nil(t, session.Save(req, rec))
+	cookie := rec.Result().Cookies()[0]
+	require.Equal(t, 0, cookie.MaxAge)
+	require.True(t, h.Provider.Exists(session.ID))
+	ttl, err := h.Provider.Get(session.ID).(rsn.Session).TTL()
+	require.Nil(t, err)
+	require.Equal(t, time.Duration(defaultMaxAge)*time.Second, ttl)
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(cookie)
+	loaded, err := store.Get(req, "app")
+	require.Nil(t, err)
+	loaded.Options.MaxAge = 0
+	require.Nil(t, loaded.Save(req, httptest.NewRecorder()))
+	require.True(t, h.Provider.Exists(session.ID))
+}
+
+func TestStoreMalformedId(t *testing.T) {
+	h := rsntest.New(t, rsn.WithPrefixKey("_gorilla_:"))
+	store := NewStore(&rsnmock.Provider{
+		ValidIdFunc: h.Provider.ValidId,
+		ExistsFunc: func(id string) bool {
+			t.Fatalf("looked %s up", id)
+			return false
+		},
+	})
+	for _, id := range []string{"*", "a:b"} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(&http.Cookie{Name: "app", Value: id})
+		session, err := store.New(req, "app")
+		require.Nil(t, err)
+		require.True(t, session.IsNew)
+		require.Empty(t, session.ID)
+	}
+}
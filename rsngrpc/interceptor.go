@@ -0,0 +1,157 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package rsngrpc propagate rsn sessions over gRPC, so mixed HTTP and gRPC services share one session store.
+// It is a module of its own, so rsn doesn't depend on gRPC.
+package rsngrpc
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/go-the-way/rsn"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	s "github.com/go-the-way/anoweb/session"
+)
+
+// Option configure the interceptors
+type Option func(i *interceptor)
+
+type interceptor struct {
+	provider rsn.SessionProvider
+	config   *s.Config
+	key      string
+}
+
+// WithMetadataKey read the session id from the metadata key, the lower-cased cookie name of the provider by default
+func WithMetadataKey(key string) Option {
+	return func(i *interceptor) {
+		i.key = strings.ToLower(key)
+	}
+}
+
+func newInterceptor(provider rsn.SessionProvider, config *s.Config, opts []Option) *interceptor {
+	i := &interceptor{provider: provider, config: config, key: strings.ToLower(provider.CookieName())}
+	for _, opt := range opts {
+		opt(i)
+	}
+	return i
+}
+
+// load return ctx carrying the session named by its metadata, or ctx itself when there is none,
+// the session being validated against the peer and metadata of the call as rsn.Load validates requests.
+// An unknown id under rsn.RejectUnknown fails with codes.Unauthenticated, a redis failure with codes.Unavailable.
+func (i *interceptor) load(ctx context.Context) (context.Context, s.Session, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ctx, nil, nil
+	}
+	ids := md.Get(i.key)
+	if len(ids) == 0 {
+		return ctx, nil, nil
+	}
+	currentSession, err := i.provider.LoadId(request(ctx, md), ids[0])
+	if err == rsn.ErrUnknownSession {
+		return ctx, nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	if err != nil {
+		return ctx, nil, status.Error(codes.Unavailable, err.Error())
+	}
+	if currentSession == nil {
+		return ctx, nil, nil
+	}
+	return rsn.NewContext(ctx, currentSession), currentSession, nil
+}
+
+// request return the http request standing for the call of ctx, from its peer address and metadata
+func request(ctx context.Context, md metadata.MD) *http.Request {
+	r := (&http.Request{Method: http.MethodPost, URL: &url.URL{}, Header: http.Header{}}).WithContext(ctx)
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		r.RemoteAddr = p.Addr.String()
+	}
+	for key, values := range md {
+		for _, value := range values {
+			r.Header.Add(key, value)
+		}
+	}
+	return r
+}
+
+// refresh extend currentSession once the call is handled
+func (i *interceptor) refresh(currentSession s.Session) {
+	if currentSession != nil && !currentSession.Invalidated() {
+		i.provider.Refresh(currentSession, i.config, nil)
+	}
+}
+
+// UnaryServerInterceptor return an interceptor loading the session named by the call metadata into its
+// context for FromContext, and refreshing it once handled. Calls without a live session go on without one.
+// The session is validated as rsn.Load does, an unknown id under rsn.RejectUnknown failing the call.
+func UnaryServerInterceptor(provider rsn.SessionProvider, config *s.Config, opts ...Option) grpc.UnaryServerInterceptor {
+	i := newInterceptor(provider, config, opts)
+	return func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, currentSession, err := i.load(ctx)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := handler(ctx, req)
+		i.refresh(currentSession)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor return the stream counterpart of UnaryServerInterceptor
+func StreamServerInterceptor(provider rsn.SessionProvider, config *s.Config, opts ...Option) grpc.StreamServerInterceptor {
+	i := newInterceptor(provider, config, opts)
+	return func(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, currentSession, err := i.load(ss.Context())
+		if err != nil {
+			return err
+		}
+		err = handler(srv, &sessionStream{ss, ctx})
+		i.refresh(currentSession)
+		return err
+	}
+}
+
+// sessionStream is a server stream whose context carries the session
+type sessionStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context return the context carrying the session
+func (ss *sessionStream) Context() context.Context {
+	return ss.ctx
+}
+
+// FromContext return the session loaded by the interceptors and whether there is one, as rsn.FromContext does
+func FromContext(ctx context.Context) (s.Session, bool) {
+	return rsn.FromContext(ctx)
+}
+
+// UnaryClientInterceptor return a client interceptor sending the session id returned by id
+// under the metadata key, e.g. to forward the session of an HTTP request to a gRPC backend
+func UnaryClientInterceptor(key string, id func(ctx context.Context) string) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if sessionId := id(ctx); sessionId != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, strings.ToLower(key), sessionId)
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
@@ -0,0 +1,147 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsngrpc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-the-way/rsn"
+	"github.com/go-the-way/rsn/rsntest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	s "github.com/go-the-way/anoweb/session"
+
+	"github.com/stretchr/testify/require"
+)
+
+type stream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (ss *stream) Context() context.Context {
+	return ss.ctx
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	h := rsntest.New(t, rsn.WithPrefixKey("_grpc_:"))
+	config := &s.Config{Valid: time.Minute}
+	currSession := h.Provider.New(config, nil)
+	interceptor := UnaryServerInterceptor(h.Provider, config)
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("gosessid", currSession.Id()))
+	h.Advance(time.Second * 30)
+	resp, err := interceptor(ctx, nil, nil, func(ctx context.Context, _ interface{}) (interface{}, error) {
+		currentSession, ok := FromContext(ctx)
+		require.True(t, ok)
+		return currentSession.Id(), nil
+	})
+	require.Nil(t, err)
+	require.Equal(t, currSession.Id(), resp)
+	ttl, err := currSession.(rsn.Session).TTL()
+	require.Nil(t, err)
+	require.Equal(t, time.Minute, ttl)
+
+	ctx = metadata.NewIncomingContext(context.Background(), metadata.Pairs("gosessid", "unknown"))
+	_, err = interceptor(ctx, nil, nil, func(ctx context.Context, _ interface{}) (interface{}, error) {
+		_, ok := FromContext(ctx)
+		require.False(t, ok)
+		return nil, nil
+	})
+	require.Nil(t, err)
+}
+
+func TestUnaryServerInterceptorMalformedId(t *testing.T) {
+	h := rsntest.New(t, rsn.WithPrefixKey("_grpc_:"))
+	config := &s.Config{Valid: time.Minute}
+	currSession := h.Provider.New(config, nil)
+	interceptor := UnaryServerInterceptor(h.Provider, config)
+	call := func(id string) s.Session {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("gosessid", id))
+		resp, err := interceptor(ctx, nil, nil, func(ctx context.Context, _ interface{}) (interface{}, error) {
+			currentSession, _ := FromContext(ctx)
+			return currentSession, nil
+		})
+		require.Nil(t, err)
+		currentSession, _ := resp.(s.Session)
+		return currentSession
+	}
+
+	rejected := h.Provider.(interface{ RejectedIds() uint64 })
+	require.Nil(t, call("*"))
+	require.Nil(t, call("a:b"))
+	require.Nil(t, call(""))
+	require.Equal(t, uint64(2), rejected.RejectedIds())
+	require.Equal(t, currSession.Id(), call(currSession.Id()).Id())
+}
+
+func TestUnaryServerInterceptorValidate(t *testing.T) {
+	h := rsntest.New(t, rsn.WithPrefixKey("_grpc_:"), rsn.WithFingerprint(nil, rsn.RejectMismatch),
+		rsn.WithUnknownIdPolicy(rsn.RejectUnknown))
+	config := &s.Config{Valid: time.Minute}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("User-Agent", "owner")
+	currSession := h.Provider.NewWithRequest(req, config, nil)
+	interceptor := UnaryServerInterceptor(h.Provider, config)
+	call := func(userAgent string) (s.Session, error) {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("gosessid", currSession.Id(), "user-agent", userAgent))
+		resp, err := interceptor(ctx, nil, nil, func(ctx context.Context, _ interface{}) (interface{}, error) {
+			currentSession, _ := FromContext(ctx)
+			return currentSession, nil
+		})
+		currentSession, _ := resp.(s.Session)
+		return currentSession, err
+	}
+
+	loaded, err := call("owner")
+	require.Nil(t, err)
+	require.Equal(t, currSession.Id(), loaded.Id())
+	loaded, err = call("thief")
+	require.Nil(t, err)
+	require.Nil(t, loaded)
+	require.True(t, currSession.Invalidated())
+	_, err = call("owner")
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	h := rsntest.New(t, rsn.WithPrefixKey("_grpc_:"))
+	config := &s.Config{Valid: time.Minute}
+	currSession := h.Provider.New(config, nil)
+	interceptor := StreamServerInterceptor(h.Provider, config, WithMetadataKey("Session-Id"))
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("session-id", currSession.Id()))
+	err := interceptor(nil, &stream{ctx: ctx}, nil, func(_ interface{}, ss grpc.ServerStream) error {
+		currentSession, _ := FromContext(ss.Context())
+		require.Equal(t, currSession.Id(), currentSession.Id())
+		return nil
+	})
+	require.Nil(t, err)
+}
+
+func TestUnaryClientInterceptor(t *testing.T) {
+	interceptor := UnaryClientInterceptor("GOSESSID", func(context.Context) string { return "ID" })
+	err := interceptor(context.Background(), "/svc/method", nil, nil, nil,
+		func(ctx context.Context, _ string, _, _ interface{}, _ *grpc.ClientConn, _ ...grpc.CallOption) error {
+			md, _ := metadata.FromOutgoingContext(ctx)
+			require.Equal(t, []string{"ID"}, md.Get("gosessid"))
+			return nil
+		})
+	require.Nil(t, err)
+}
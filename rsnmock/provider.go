@@ -0,0 +1,278 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package rsnmock provide a mock of rsn.SessionProvider, so handlers depending on rsn
+// can be unit tested without redis.
+package rsnmock
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/go-the-way/rsn"
+
+	s "github.com/go-the-way/anoweb/session"
+)
+
+// Provider mock rsn.SessionProvider. Each method calls the function field of the same name
+// with a Func suffix, or returns zero values when it is nil.
+type Provider struct {
+	CookieNameFunc     func() string
+	ExistsFunc         func(id string) bool
+	GetIdFunc          func(r *http.Request) string
+	DelFunc            func(id string)
+	GetFunc            func(id string) s.Session
+	GetAllFunc         func() map[string]s.Session
+	ClearFunc          func()
+	NewFunc            func(config *s.Config, listener *s.Listener) s.Session
+	RefreshFunc        func(session s.Session, config *s.Config, listener *s.Listener)
+	CleanFunc          func(config *s.Config, listener *s.Listener)
+	NewWithRequestFunc func(r *http.Request, config *s.Config, listener *s.Listener) s.Session
+	NewFromRequestFunc func(r *http.Request, config *s.Config, listener *s.Listener) (s.Session, *http.Cookie, error)
+	GetOrCreateFunc    func(r *http.Request, config *s.Config, listener *s.Listener) (s.Session, error)
+	LoadFunc           func(r *http.Request) (s.Session, error)
+	LoadIdFunc         func(r *http.Request, id string) (s.Session, error)
+	ValidIdFunc        func(id string) bool
+	CookieFunc         func(session s.Session, config *s.Config) *http.Cookie
+	LegacyCookiesFunc  func(r *http.Request) []*http.Cookie
+	RegenerateFunc     func(session s.Session) (s.Session, error)
+	RestoreFunc        func(id string) error
+	FindFunc           func(ctx context.Context, field, value string) ([]s.Session, error)
+	ListFunc           func(ctx context.Context, cursor uint64, limit int64) ([]string, uint64, error)
+	ForEachFunc        func(ctx context.Context, fn func(s.Session) error) error
+	CountFunc          func(ctx context.Context) (int64, error)
+	SessionsByUserFunc func(ctx context.Context, userId string) ([]rsn.SessionInfo, error)
+	DeleteFunc         func(id string) (bool, error)
+	InvalidateUserFunc func(ctx context.Context, userId string) error
+	PurgeFunc          func(ctx context.Context, userId string) (*rsn.PurgeReport, error)
+}
+
+var _ rsn.SessionProvider = (*Provider)(nil)
+
+// CookieName call CookieNameFunc
+func (m *Provider) CookieName() string {
+	if m.CookieNameFunc != nil {
+		return m.CookieNameFunc()
+	}
+	return ""
+}
+
+// Exists call ExistsFunc
+func (m *Provider) Exists(id string) bool {
+	if m.ExistsFunc != nil {
+		return m.ExistsFunc(id)
+	}
+	return false
+}
+
+// GetId call GetIdFunc
+func (m *Provider) GetId(r *http.Request) string {
+	if m.GetIdFunc != nil {
+		return m.GetIdFunc(r)
+	}
+	return ""
+}
+
+// Del call DelFunc
+func (m *Provider) Del(id string) {
+	if m.DelFunc != nil {
+		m.DelFunc(id)
+	}
+}
+
+// Get call GetFunc
+func (m *Provider) Get(id string) s.Session {
+	if m.GetFunc != nil {
+		return m.GetFunc(id)
+	}
+	return nil
+}
+
+// GetAll call GetAllFunc
+func (m *Provider) GetAll() map[string]s.Session {
+	if m.GetAllFunc != nil {
+		return m.GetAllFunc()
+	}
+	return map[string]s.Session{}
+}
+
+// Clear call ClearFunc
+func (m *Provider) Clear() {
+	if m.ClearFunc != nil {
+		m.ClearFunc()
+	}
+}
+
+// New call NewFunc
+func (m *Provider) New(config *s.Config, listener *s.Listener) s.Session {
+	if m.NewFunc != nil {
+		return m.NewFunc(config, listener)
+	}
+	return nil
+}
+
+// Refresh call RefreshFunc
+func (m *Provider) Refresh(session s.Session, config *s.Config, listener *s.Listener) {
+	if m.RefreshFunc != nil {
+		m.RefreshFunc(session, config, listener)
+	}
+}
+
+// Clean call CleanFunc
+func (m *Provider) Clean(config *s.Config, listener *s.Listener) {
+	if m.CleanFunc != nil {
+		m.CleanFunc(config, listener)
+	}
+}
+
+// NewWithRequest call NewWithRequestFunc
+func (m *Provider) NewWithRequest(r *http.Request, config *s.Config, listener *s.Listener) s.Session {
+	if m.NewWithRequestFunc != nil {
+		return m.NewWithRequestFunc(r, config, listener)
+	}
+	return nil
+}
+
+// NewFromRequest call NewFromRequestFunc
+func (m *Provider) NewFromRequest(r *http.Request, config *s.Config, listener *s.Listener) (s.Session, *http.Cookie, error) {
+	if m.NewFromRequestFunc != nil {
+		return m.NewFromRequestFunc(r, config, listener)
+	}
+	return nil, nil, nil
+}
+
+// GetOrCreate call GetOrCreateFunc
+func (m *Provider) GetOrCreate(r *http.Request, config *s.Config, listener *s.Listener) (s.Session, error) {
+	if m.GetOrCreateFunc != nil {
+		return m.GetOrCreateFunc(r, config, listener)
+	}
+	return nil, nil
+}
+
+// Load call LoadFunc
+func (m *Provider) Load(r *http.Request) (s.Session, error) {
+	if m.LoadFunc != nil {
+		return m.LoadFunc(r)
+	}
+	return nil, nil
+}
+
+// LoadId call LoadIdFunc
+func (m *Provider) LoadId(r *http.Request, id string) (s.Session, error) {
+	if m.LoadIdFunc != nil {
+		return m.LoadIdFunc(r, id)
+	}
+	return nil, nil
+}
+
+// ValidId call ValidIdFunc
+func (m *Provider) ValidId(id string) bool {
+	if m.ValidIdFunc != nil {
+		return m.ValidIdFunc(id)
+	}
+	return false
+}
+
+// Cookie call CookieFunc
+func (m *Provider) Cookie(session s.Session, config *s.Config) *http.Cookie {
+	if m.CookieFunc != nil {
+		return m.CookieFunc(session, config)
+	}
+	return nil
+}
+
+// LegacyCookies call LegacyCookiesFunc
+func (m *Provider) LegacyCookies(r *http.Request) []*http.Cookie {
+	if m.LegacyCookiesFunc != nil {
+		return m.LegacyCookiesFunc(r)
+	}
+	return nil
+}
+
+// Regenerate call RegenerateFunc
+func (m *Provider) Regenerate(session s.Session) (s.Session, error) {
+	if m.RegenerateFunc != nil {
+		return m.RegenerateFunc(session)
+	}
+	return nil, nil
+}
+
+// Restore call RestoreFunc
+func (m *Provider) Restore(id string) error {
+	if m.RestoreFunc != nil {
+		return m.RestoreFunc(id)
+	}
+	return nil
+}
+
+// Find call FindFunc
+func (m *Provider) Find(ctx context.Context, field, value string) ([]s.Session, error) {
+	if m.FindFunc != nil {
+		return m.FindFunc(ctx, field, value)
+	}
+	return nil, nil
+}
+
+// List call ListFunc
+func (m *Provider) List(ctx context.Context, cursor uint64, limit int64) ([]string, uint64, error) {
+	if m.ListFunc != nil {
+		return m.ListFunc(ctx, cursor, limit)
+	}
+	return nil, 0, nil
+}
+
+// ForEach call ForEachFunc
+func (m *Provider) ForEach(ctx context.Context, fn func(s.Session) error) error {
+	if m.ForEachFunc != nil {
+		return m.ForEachFunc(ctx, fn)
+	}
+	return nil
+}
+
+// Count call CountFunc
+func (m *Provider) Count(ctx context.Context) (int64, error) {
+	if m.CountFunc != nil {
+		return m.CountFunc(ctx)
+	}
+	return 0, nil
+}
+
+// SessionsByUser call SessionsByUserFunc
+func (m *Provider) SessionsByUser(ctx context.Context, userId string) ([]rsn.SessionInfo, error) {
+	if m.SessionsByUserFunc != nil {
+		return m.SessionsByUserFunc(ctx, userId)
+	}
+	return nil, nil
+}
+
+// Delete call DeleteFunc
+func (m *Provider) Delete(id string) (bool, error) {
+	if m.DeleteFunc != nil {
+		return m.DeleteFunc(id)
+	}
+	return false, nil
+}
+
+// InvalidateUser call InvalidateUserFunc
+func (m *Provider) InvalidateUser(ctx context.Context, userId string) error {
+	if m.InvalidateUserFunc != nil {
+		return m.InvalidateUserFunc(ctx, userId)
+	}
+	return nil
+}
+
+// Purge call PurgeFunc
+func (m *Provider) Purge(ctx context.Context, userId string) (*rsn.PurgeReport, error) {
+	if m.PurgeFunc != nil {
+		return m.PurgeFunc(ctx, userId)
+	}
+	return nil, nil
+}
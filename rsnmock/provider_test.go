@@ -0,0 +1,38 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsnmock
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProvider(t *testing.T) {
+	m := &Provider{}
+	require.Equal(t, "", m.CookieName())
+	require.False(t, m.Exists("id"))
+	require.Nil(t, m.Get("id"))
+	m.Del("id")
+
+	deleted := ""
+	m.DelFunc = func(id string) { deleted = id }
+	m.ExistsFunc = func(id string) bool { return id == "known" }
+	m.CountFunc = func(ctx context.Context) (int64, error) { return 3, nil }
+	m.Del("known")
+	require.Equal(t, "known", deleted)
+	require.True(t, m.Exists("known"))
+	count, err := m.Count(context.Background())
+	require.Nil(t, err)
+	require.Equal(t, int64(3), count)
+}
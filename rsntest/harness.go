@@ -0,0 +1,75 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package rsntest run rsn against an in-process miniredis, so tests of expiry
+// and cleanup run deterministically in CI without a redis server.
+package rsntest
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis"
+	"github.com/go-the-way/rsn"
+
+	s "github.com/go-the-way/anoweb/session"
+)
+
+// Harness is a provider stored in an in-process miniredis, sharing a fake clock with it
+type Harness struct {
+	// Redis is the miniredis server, e.g. to inspect keys
+	Redis *miniredis.Miniredis
+	// Provider is the provider under test
+	Provider rsn.SessionProvider
+
+	mu  sync.Mutex
+	now time.Time
+}
+
+// New start miniredis and return a harness whose provider is configured by opts.
+// Both are closed when t ends.
+func New(t testing.TB, opts ...rsn.Option) *Harness {
+	mr := miniredis.RunT(t)
+	h := &Harness{Redis: mr, now: time.Now()}
+	mr.SetTime(h.now)
+	p := rsn.ProviderWithOptions(h.Options(), append([]rsn.Option{rsn.WithClock(h)}, opts...)...)
+	t.Cleanup(func() { _ = p.Client().Close() })
+	h.Provider = p
+	return h
+}
+
+// Options return the redis options of the miniredis server, to build more providers against it
+func (h *Harness) Options() *redis.Options {
+	return &redis.Options{Addr: h.Redis.Addr()}
+}
+
+// Now return the time of the fake clock
+func (h *Harness) Now() time.Time {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.now
+}
+
+// Advance move the fake clock forward by d, expiring the keys whose ttl ran out
+func (h *Harness) Advance(d time.Duration) {
+	h.mu.Lock()
+	h.now = h.now.Add(d)
+	h.Redis.SetTime(h.now)
+	h.mu.Unlock()
+	h.Redis.FastForward(d)
+}
+
+// Clean run one cleaning pass of the provider, firing listener as Clean would
+func (h *Harness) Clean(listener *s.Listener) {
+	h.Provider.(interface{ CleanNow(*s.Listener) }).CleanNow(listener)
+}
@@ -0,0 +1,50 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsntest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-the-way/rsn"
+
+	s "github.com/go-the-way/anoweb/session"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHarness(t *testing.T) {
+	h := New(t, rsn.WithPrefixKey("_harness_:"))
+	currSession := h.Provider.New(&s.Config{Valid: time.Minute}, nil).(rsn.Session)
+	currSession.Set("apple", "100")
+	require.True(t, h.Redis.Exists("_harness_:"+currSession.Id()))
+
+	h.Advance(time.Second * 40)
+	ttl, err := currSession.TTL()
+	require.Nil(t, err)
+	require.Equal(t, time.Second*20, ttl)
+
+	h.Advance(time.Second * 20)
+	_, err = currSession.TTL()
+	require.Equal(t, rsn.ErrSessionNotFound, err)
+	invalidated := make(chan string, 1)
+	h.Clean(&s.Listener{Invalidated: func(session s.Session) { invalidated <- session.Id() }})
+	require.Equal(t, currSession.Id(), <-invalidated)
+	require.False(t, h.Provider.Exists(currSession.Id()))
+
+	other := h.Provider.New(&s.Config{Valid: time.Minute}, nil)
+	other.Invalidate()
+	destroyed := make(chan string, 1)
+	h.Clean(&s.Listener{Destroyed: func(session s.Session) { destroyed <- session.Id() }})
+	require.Equal(t, other.Id(), <-destroyed)
+	require.False(t, h.Provider.Exists(other.Id()))
+}
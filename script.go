@@ -0,0 +1,25 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import r "github.com/go-redis/redis"
+
+// scriptSources the source of every script by its sha1, to load the scripts into a server
+// receiving EVALSHA without having seen them, such as the standby of WithFailover
+var scriptSources = map[string]string{}
+
+// newScript return the script of src, registered in scriptSources
+func newScript(src string) *r.Script {
+	script := r.NewScript(src)
+	scriptSources[script.Hash()] = src
+	return script
+}
@@ -0,0 +1,75 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"time"
+
+	r "github.com/go-redis/redis"
+)
+
+// SCSStore implement the Store interface of alexedwards/scs, keeping each scs session
+// as a session of the provider whose id is the scs token, so they share key prefix,
+// client, listing and cleanup. The scs payload is kept in an internal field.
+type SCSStore struct {
+	p *provider
+}
+
+// SCSStore return the scs store of p
+func (p *provider) SCSStore() *SCSStore {
+	return &SCSStore{p}
+}
+
+// Find return the payload of the session of token, and whether it was found
+func (st *SCSStore) Find(token string) ([]byte, bool, error) {
+	b, err := st.p.client.HGet(st.p.getRedisKey(token), st.p.field(scsDataName)).Bytes()
+	if err == r.Nil {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, wrapErr(err)
+	}
+	return b, true, nil
+}
+
+// Commit store the payload b of the session of token until expiry
+func (st *SCSStore) Commit(token string, b []byte, expiry time.Time) error {
+	p := st.p
+	key := p.getRedisKey(token)
+	now := formatTime(p.now())
+	_, err := p.client.TxPipelined(func(pipe r.Pipeliner) error {
+		pipe.HSetNX(key, st.p.field(createdAtName), now)
+		pipe.HMSet(key, map[string]interface{}{
+			st.p.field(sessionIdName):  token,
+			st.p.field(accessedAtName): now,
+			st.p.field(scsDataName):    b,
+		})
+		pipe.PExpireAt(key, expiry)
+		return nil
+	})
+	if err != nil {
+		return wrapErr(err)
+	}
+	p.indexExpiry(token, expiry.Sub(p.now()))
+	p.mu.Lock()
+	if _, have := p.sessions[token]; !have {
+		p.store(token, newSession(p, token))
+	}
+	p.mu.Unlock()
+	return nil
+}
+
+// Delete remove the session of token
+func (st *SCSStore) Delete(token string) error {
+	st.p.destroy(token)
+	return nil
+}
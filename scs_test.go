@@ -0,0 +1,51 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// scsStore mirror the Store interface of alexedwards/scs
+type scsStore interface {
+	Find(token string) (b []byte, found bool, err error)
+	Commit(token string, b []byte, expiry time.Time) error
+	Delete(token string) error
+}
+
+func TestSCSStore(t *testing.T) {
+	p := ProviderWithPrefixKey(redisOptions, "_scs_:")
+	var store scsStore = p.SCSStore()
+	token := newSID()
+	_, found, err := store.Find(token)
+	require.Nil(t, err)
+	require.False(t, found)
+
+	require.Nil(t, store.Commit(token, []byte("payload"), time.Now().Add(time.Minute)))
+	b, found, err := store.Find(token)
+	require.Nil(t, err)
+	require.True(t, found)
+	require.Equal(t, []byte("payload"), b)
+	require.True(t, p.Exists(token))
+	ttl, err := p.Get(token).(Session).TTL()
+	require.Nil(t, err)
+	require.True(t, ttl > time.Second*50)
+	require.Equal(t, ErrReservedField, p.Get(token).(Session).SetValue(p.field(scsDataName), "other"))
+
+	require.Nil(t, store.Delete(token))
+	_, found, err = store.Find(token)
+	require.Nil(t, err)
+	require.False(t, found)
+}
@@ -0,0 +1,89 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// ErrNoSearchIndex returned by Search when no index was declared by WithSearchIndex
+var ErrNoSearchIndex = errors.New("rsn: no search index")
+
+// SearchFieldType is the RediSearch type a session field is indexed as
+type SearchFieldType string
+
+const (
+	// SearchTag index exact values, such as user ids, roles or ips, matched with @field:{value}
+	SearchTag SearchFieldType = "TAG"
+	// SearchText index full text
+	SearchText SearchFieldType = "TEXT"
+	// SearchNumeric index numbers, such as the createdAt and accessedAt unix milliseconds, matched with @field:[min max]
+	SearchNumeric SearchFieldType = "NUMERIC"
+)
+
+// SearchField is a session field indexed by RediSearch
+type SearchField struct {
+	Name string
+	Type SearchFieldType
+}
+
+type searchIndex struct {
+	name   string
+	fields []SearchField
+}
+
+// createSearchIndex create the RediSearch index declared by WithSearchIndex over the session hashes,
+// which RediSearch then keeps in step with every write. An existing index is left as is.
+func (p *provider) createSearchIndex() error {
+	args := []interface{}{"FT.CREATE", p.search.name, "ON", "HASH", "PREFIX", 1, strings.SplitN(p.getKeyPattern("*"), "*", 2)[0], "SCHEMA"}
+	for _, field := range p.search.fields {
+		name := field.Name
+		if _, internal := reservedNames[name]; internal {
+			name = p.field(name)
+		}
+		args = append(args, name, string(field.Type))
+	}
+	err := p.client.Do(args...).Err()
+	if err != nil && strings.Contains(err.Error(), "already exists") {
+		return nil
+	}
+	return wrapErr(err)
+}
+
+// Search return the ids of the sessions matching the RediSearch query, e.g.
+// "@role:{admin} @ip:{10\.0\.0\.*} @createdAt:[1650000000000 +inf]", from offset and at most limit of them,
+// with the total number of matches.
+func (p *provider) Search(ctx context.Context, query string, offset, limit int64) ([]string, int64, error) {
+	if p.search == nil {
+		return nil, 0, ErrNoSearchIndex
+	}
+	reply, err := p.client.WithContext(ctx).Do("FT.SEARCH", p.search.name, query, "NOCONTENT", "LIMIT", offset, limit).Result()
+	if err != nil {
+		return nil, 0, wrapErr(err)
+	}
+	values, _ := reply.([]interface{})
+	if len(values) == 0 {
+		return nil, 0, nil
+	}
+	total, _ := values[0].(int64)
+	ids := make([]string, 0, len(values)-1)
+	for _, value := range values[1:] {
+		if key, ok := value.(string); ok {
+			if id, own := p.ownId(key); own {
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids, total, nil
+}
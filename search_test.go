@@ -0,0 +1,47 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	s "github.com/go-the-way/anoweb/session"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProviderSearch(t *testing.T) {
+	_, _, err := Provider(redisOptions).Search(context.Background(), "*", 0, 10)
+	require.Equal(t, ErrNoSearchIndex, err)
+
+	p := ProviderWithOptions(redisOptions, WithPrefixKey("_search_:"),
+		WithSearchIndex("_search_idx_", SearchField{"role", SearchTag}, SearchField{"createdAt", SearchNumeric}))
+	if err = p.createSearchIndex(); err != nil && strings.Contains(strings.ToLower(err.Error()), "unknown") {
+		t.Skip("RediSearch module not loaded")
+	}
+	require.NoError(t, err)
+	admin := p.New(&s.Config{Valid: time.Minute}, nil)
+	admin.Set("role", "admin")
+	user := p.New(&s.Config{Valid: time.Minute}, nil)
+	user.Set("role", "user")
+	time.Sleep(time.Millisecond * 100)
+
+	ids, total, err := p.Search(context.Background(), "@role:{admin}", 0, 10)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), total)
+	require.Equal(t, []string{admin.Id()}, ids)
+	p.Del(admin.Id())
+	p.Del(user.Id())
+}
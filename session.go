@@ -12,8 +12,12 @@
 package rsn
 
 import (
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	rds "github.com/go-redis/redis"
@@ -21,18 +25,169 @@ import (
 	se "github.com/go-the-way/anoweb/session"
 )
 
+// Session is the anoweb session stored in redis, with the extra features of rsn
+type Session interface {
+	se.Session
+	// BindUser bind session to user id
+	BindUser(userId string) error
+	// UserId return bound user id
+	UserId() string
+	// Meta return the device metadata captured at creation
+	Meta() Meta
+	// Validate check request against the client bound to session
+	Validate(r *http.Request) error
+	// TTL return remaining time to live
+	TTL() (time.Duration, error)
+	// Touch mark session accessed
+	Touch() error
+	// Remember move session to the remember-me tier
+	Remember() error
+	// Remembered report whether session is in the remember-me tier
+	Remembered() bool
+	// Authenticate record that the user just proved their identity
+	Authenticate() error
+	// RequireFresh return an error unless the user authenticated recently enough for sensitive operations
+	RequireFresh() error
+	// Elevate record that the user just authenticated at level, granted for valid
+	Elevate(level AuthLevel, valid time.Duration) error
+	// AuthLevel return the highest level granted now
+	AuthLevel() AuthLevel
+	// ElevatedUntil return when level stops being granted, zero time when it isn't
+	ElevatedUntil(level AuthLevel) time.Time
+	// RequireLevel return an error unless level is granted now
+	RequireLevel(level AuthLevel) error
+	// SetPrincipal store the user session is logged in as, binding session to them
+	SetPrincipal(principal Principal) error
+	// Principal return the user session is logged in as, nil when none
+	Principal() *Principal
+	// HasRole report whether the principal holds role
+	HasRole(role string) bool
+	// Lineage return the ids session had before being regenerated, oldest first
+	Lineage() ([]string, error)
+	// SetValue set named val, returning why it was refused
+	SetValue(name string, val interface{}) error
+	// SetValues set values, returning why they were refused
+	SetValues(data map[string]interface{}, flush bool) error
+	// SetWithTTL set named val, expiring it alone after ttl
+	SetWithTTL(name string, val interface{}, ttl time.Duration) error
+	// SyncSet set named val at once, bypassing the write-behind queue
+	SyncSet(name string, val interface{}) error
+	// SetDurable set named val at once, waiting for replicas to acknowledge it
+	SetDurable(name string, val interface{}, replicas int, timeout time.Duration) error
+	// Diff return the fields to set and to delete for session to hold exactly other
+	Diff(other map[string]interface{}) (map[string]interface{}, []string)
+	// Merge make session hold exactly data, writing only what changed
+	Merge(data map[string]interface{}) error
+	// Snapshot return an immutable copy of session with its ttl
+	Snapshot() (*Snapshot, error)
+	// ReadOnly return a view of session refusing writes
+	ReadOnly() Session
+}
+
 type session struct {
 	id          string
 	key         string
 	invalidated bool
 	client      *rds.Client
+	provider    *provider
+	userId      string
+	warned      bool
+	wroteAt     int64
+}
+
+var _ Session = (*session)(nil)
+
+func newSession(p *provider, id string) *session {
+	return &session{id: id, key: p.getRedisKey(id), client: p.client, provider: p}
+}
+
+// LegacyIdField the name sessions were marked with their id under before "__rsn_id", see WithLegacyIdField
+const LegacyIdField = "sessionId"
+
+// the plain names of the internal fields, stored prefixed as set by WithFieldPrefix
+const (
+	sessionIdName    = "id"
+	userIdName       = "userId"
+	createdAtName    = "createdAt"
+	accessedAtName   = "accessedAt"
+	ipName           = "ip"
+	userAgentName    = "userAgent"
+	deviceName       = "device"
+	boundNetworkName = "boundNetwork"
+	fingerprintName  = "fingerprint"
+	lifeTimeName     = "lifeTime"
+	deadlineName     = "deadline"
+	rememberName     = "remember"
+	authAtName       = "authenticatedAt"
+	lineageName      = "lineage"
+	scsDataName      = "scsData"
+	authLevelsName   = "authLevels"
+	principalName    = "principal"
+)
+
+var reservedNames = map[string]struct{}{
+	sessionIdName:    {},
+	userIdName:       {},
+	createdAtName:    {},
+	accessedAtName:   {},
+	ipName:           {},
+	userAgentName:    {},
+	deviceName:       {},
+	boundNetworkName: {},
+	fingerprintName:  {},
+	lifeTimeName:     {},
+	deadlineName:     {},
+	rememberName:     {},
+	authAtName:       {},
+	lineageName:      {},
+	scsDataName:      {},
+	authLevelsName:   {},
+	principalName:    {},
+}
+
+// field return the name internal field name is stored under, prefixed as set by WithFieldPrefix,
+// the id marker being stored under the name set by WithIdFieldName if any
+func (p *provider) field(name string) string {
+	if name == sessionIdName && p.idField != "" {
+		return p.idField
+	}
+	return p.fieldPrefix + name
+}
+
+// reserved report whether name is an internal field, or one reserved by WithReservedFields,
+// which can't be changed by Set or Del. With a field prefix every name starting with it is internal.
+func (p *provider) reserved(name string) bool {
+	if _, have := p.reservedFields[name]; have {
+		return true
+	}
+	if p.idField != "" {
+		if name == p.idField {
+			return true
+		}
+		if name == sessionIdName && p.fieldPrefix == "" {
+			return false
+		}
+	}
+	if p.fieldPrefix != "" {
+		return strings.HasPrefix(name, p.fieldPrefix)
+	}
+	_, have := reservedNames[name]
+	return have
 }
 
-func newSession(client *rds.Client, id, key string) se.Session {
-	return &session{id, key, false, client}
+// formatTime return t as unix milliseconds, the format of time fields
+func formatTime(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano()/int64(time.Millisecond), 10)
 }
 
-const sessionIdName = "sessionId"
+// parseTime parse a time field, returning zero time if val is malformed
+func parseTime(val string) time.Time {
+	ms, err := strconv.ParseInt(val, 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(0, ms*int64(time.Millisecond))
+}
 
 // Id return session id
 func (s *session) Id() string {
@@ -41,7 +196,67 @@ func (s *session) Id() string {
 
 // Renew session
 func (s *session) Renew(lifeTime time.Duration) {
-	s.client.Expire(s.key, lifeTime)
+	if _, err := s.provider.renew(s.id, lifeTime, false); gone(err) {
+		s.invalidated = true
+	} else {
+		rearmExpiryWarning(s)
+	}
+}
+
+// touchScript update the last access time, and extend the ttl, and the document's, to the full
+// lifetime once less than half of it remains, never past the deadline
+var touchScript = newScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return 0
+end
+redis.call('HSET', KEYS[1], ARGV[1], ARGV[2])
+local lifeTime = tonumber(redis.call('HGET', KEYS[1], ARGV[3]))
+if lifeTime then
+	local ttl = redis.call('PTTL', KEYS[1])
+	if ttl >= 0 and ttl < lifeTime / 2 then
+		local deadline = tonumber(redis.call('HGET', KEYS[1], ARGV[4]))
+		if deadline and deadline - tonumber(ARGV[2]) < lifeTime then
+			lifeTime = deadline - tonumber(ARGV[2])
+		end
+		if lifeTime > ttl then
+			redis.call('PEXPIRE', KEYS[1], lifeTime)
+			redis.call('PEXPIRE', KEYS[3], lifeTime)
+			redis.call('ZADD', KEYS[2], tonumber(ARGV[2]) + lifeTime, ARGV[5])
+		end
+	end
+end
+return 1
+`)
+
+// Touch mark session accessed, extending its ttl only when less than half of the lifetime remains.
+// It is cheaper than Refresh and meant for middlewares running on every request.
+func (s *session) Touch() error {
+	p := s.provider
+	touched, err := touchScript.Run(s.client, []string{s.key, p.getExpiryKey(), p.getDocumentKey(s.id)},
+		p.field(accessedAtName), formatTime(p.now()), p.field(lifeTimeName), p.field(deadlineName), s.id).Int64()
+	if err != nil {
+		return wrapErr(err)
+	}
+	if touched == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// ErrSessionNotFound returned when the session no longer exists in redis, or by the writes
+// through a session handle invalidated or regenerated meanwhile
+var ErrSessionNotFound = errors.New("rsn: session not found")
+
+// TTL return the remaining time to live of session, negative if it never expires
+func (s *session) TTL() (time.Duration, error) {
+	ttl, err := s.client.PTTL(s.key).Result()
+	if err != nil {
+		return 0, wrapErr(err)
+	}
+	if ttl == -2*time.Millisecond {
+		return 0, ErrSessionNotFound
+	}
+	return ttl, nil
 }
 
 // Invalidated session
@@ -49,14 +264,36 @@ func (s *session) Invalidated() bool {
 	return s.invalidated
 }
 
-// Invalidate session
+// Invalidate session, deleting it from redis and telling peer instances, so it stops
+// being accepted everywhere at once. The local entry stays until the next cleaning
+// pass, which fires the Destroyed listener.
 func (s *session) Invalidate() {
+	if s.invalidated {
+		return
+	}
 	s.invalidated = true
+	p := s.provider
+	p.mu.Lock()
+	_, err := p.delStored(s.id)
+	p.mu.Unlock()
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+	}
+	p.publishInvalidation(s.id)
+}
+
+// markInvalidated flag currentSession invalidated without touching redis, for sessions already gone
+func markInvalidated(currentSession se.Session) {
+	if rs, ok := currentSession.(*session); ok {
+		rs.invalidated = true
+		return
+	}
+	currentSession.Invalidate()
 }
 
 // Get session named val
 func (s *session) Get(name string) interface{} {
-	getCmd := s.client.HGet(s.key, name)
+	getCmd := s.reader().HGet(s.key, name)
 	val := ""
 	err := getCmd.Scan(&val)
 	if err != nil {
@@ -70,7 +307,7 @@ func (s *session) Get(name string) interface{} {
 
 // GetAll session's values
 func (s *session) GetAll() map[string]interface{} {
-	getAllCmd := s.client.HGetAll(s.key)
+	getAllCmd := s.reader().HGetAll(s.key)
 	values := getAllCmd.Val()
 	newValues := make(map[string]interface{}, 0)
 	for k, v := range values {
@@ -79,46 +316,100 @@ func (s *session) GetAll() map[string]interface{} {
 	return newValues
 }
 
-// Set named val into session
+// Set named val into session, reporting the write of an internal field as other errors are
 func (s *session) Set(name string, val interface{}) {
-	s.supportedHandle(name, func() {
-		s.client.HSet(s.key, name, val)
-	})
+	if err := s.SetValue(name, val); err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+	}
 }
 
 // SetAll values into session
 func (s *session) SetAll(data map[string]interface{}, flush bool) {
-	if flush {
-		s.Clear()
-	}
-	_, have := data[sessionIdName]
-	if have {
-		delete(data, sessionIdName)
+	if err := s.SetValues(data, flush); err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
 	}
-	s.client.HMSet(s.key, data)
 }
 
 // Del named val from session
 func (s *session) Del(name string) {
 	s.supportedHandle(name, func() {
-		s.client.HDel(s.key, name)
+		if s.provider.indexed(name) {
+			s.indexDel(name)
+		} else if err := s.hdel(name); err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, err)
+		}
+		s.wrote()
+		s.provider.valuesDel(s, name)
 	})
 }
 
+// clearScript delete every field of a session hash but the reserved ones in one step,
+// dropping the session from the value sets of indexed fields and forgetting the write
+// times kept for field eviction. The ttl is left untouched and the deleted names are returned.
+//
+// KEYS are the session hash and its sorted set of field write times. ARGV holds the
+// session id, the reserved names joined by commas, then a field and index key prefix
+// pair per indexed field.
+var clearScript = newScript(`
+for i = 3, #ARGV, 2 do
+	local old = redis.call('HGET', KEYS[1], ARGV[i])
+	if old then
+		redis.call('SREM', ARGV[i + 1] .. old, ARGV[1])
+	end
+end
+local reserved = {}
+for name in string.gmatch(ARGV[2], '[^,]+') do
+	reserved[name] = true
+end
+local cleared = {}
+for _, name in ipairs(redis.call('HKEYS', KEYS[1])) do
+	if not reserved[name] then
+		redis.call('HDEL', KEYS[1], name)
+		table.insert(cleared, name)
+	end
+end
+redis.call('DEL', KEYS[2])
+return cleared
+`)
+
 // Clear session's values
 func (s *session) Clear() {
-	all := s.GetAll()
-	ks := make([]string, 0)
-	for k := range all {
-		if k != sessionIdName {
-			ks = append(ks, k)
+	p := s.provider
+	p.writeBehind.forget(s.key)
+	cleared, err := clearScript.Run(s.client, []string{s.key, p.getFieldsKey(s.id)}, s.clearArgs()...).Result()
+	s.wrote()
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	p.valuesDel(s, clearedNames(cleared)...)
+}
+
+// clearArgs return the ARGV of clearScript clearing s
+func (s *session) clearArgs() []interface{} {
+	p := s.provider
+	args := make([]interface{}, 0, 2+len(p.indexes)*2)
+	args = append(args, s.id, p.reservedList())
+	for field := range p.indexes {
+		args = append(args, field, p.getIndexKeyPrefix(field))
+	}
+	return args
+}
+
+// clearedNames return the names deleted by clearScript, from its result cleared
+func clearedNames(cleared interface{}) []string {
+	values, _ := cleared.([]interface{})
+	names := make([]string, 0, len(values))
+	for _, name := range values {
+		if name, ok := name.(string); ok {
+			names = append(names, name)
 		}
 	}
-	s.client.HDel(s.key, ks...)
+	return names
 }
 
 func (s *session) supportedHandle(name string, fn func()) {
-	if name != sessionIdName {
+	if !s.provider.reserved(name) {
 		fn()
 	}
 }
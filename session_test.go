@@ -19,10 +19,14 @@ import (
 	"testing"
 	"time"
 
+	rds "github.com/go-redis/redis"
+
 	"github.com/go-the-way/anoweb"
 	"github.com/go-the-way/anoweb/context"
 	"github.com/go-the-way/anoweb/middleware"
 	s "github.com/go-the-way/anoweb/session"
+
+	"github.com/stretchr/testify/require"
 )
 
 var _port = int32(10000)
@@ -464,3 +468,175 @@ func TestSessionClear(t *testing.T) {
 		t.Log("test ok")
 	}
 }
+
+func TestSessionTTL(t *testing.T) {
+	p := Provider(redisOptions)
+	currSession := p.New(&s.Config{Valid: time.Minute}, nil).(Session)
+	ttl, err := currSession.TTL()
+	require.Nil(t, err)
+	require.True(t, ttl > 50*time.Second && ttl <= time.Minute)
+	p.Del(currSession.Id())
+	_, err = currSession.TTL()
+	require.Equal(t, ErrSessionNotFound, err)
+}
+
+func TestSessionTouch(t *testing.T) {
+	p := Provider(redisOptions)
+	currSession := p.New(&s.Config{Valid: time.Minute}, nil).(Session)
+	accessedAt := currSession.Get(p.field(accessedAtName))
+	time.Sleep(time.Millisecond * 5)
+	require.Nil(t, currSession.Touch())
+	require.NotEqual(t, accessedAt, currSession.Get(p.field(accessedAtName)))
+
+	c := rds.NewClient(redisOptions)
+	defer func() {
+		_ = c.Close()
+	}()
+	key := "session:" + currSession.Id()
+	require.Nil(t, c.Expire(key, 40*time.Second).Err())
+	require.Nil(t, currSession.Touch())
+	ttl, _ := currSession.TTL()
+	require.True(t, ttl <= 40*time.Second)
+	require.Nil(t, c.Expire(key, 20*time.Second).Err())
+	require.Nil(t, currSession.Touch())
+	ttl, _ = currSession.TTL()
+	require.True(t, ttl > 50*time.Second)
+
+	p.Del(currSession.Id())
+	require.Equal(t, ErrSessionNotFound, currSession.Touch())
+}
+
+func TestSessionInvalidateDeletes(t *testing.T) {
+	p := ProviderWithPrefixKey(redisOptions, "_invalidate_:")
+	peer := ProviderWithPrefixKey(redisOptions, "_invalidate_:")
+	peer.Clean(nil, nil)
+	currSession := p.New(&s.Config{Valid: time.Minute}, nil)
+	peer.mu.Lock()
+	peer.sessions[currSession.Id()] = newSession(peer, currSession.Id())
+	peer.mu.Unlock()
+	time.Sleep(time.Millisecond * 50)
+
+	currSession.Invalidate()
+	require.True(t, currSession.Invalidated())
+	require.Equal(t, int64(0), p.client.Exists(p.getRedisKey(currSession.Id())).Val())
+	require.Eventually(t, func() bool { return !peer.Exists(currSession.Id()) }, time.Second, time.Millisecond*10)
+
+	destroyed := make(chan string, 1)
+	p.cleanSession(&s.Listener{Destroyed: func(session s.Session) { destroyed <- session.Id() }})
+	require.Equal(t, currSession.Id(), <-destroyed)
+}
+
+func TestSessionClearAtomic(t *testing.T) {
+	p := ProviderWithOptions(redisOptions, WithPrefixKey("_clear_:"), WithIndex("tenant"), WithMaxFields(10, true))
+	currSession := p.New(&s.Config{Valid: time.Minute}, nil).(*session)
+	require.NoError(t, currSession.SetValues(map[string]interface{}{"apple": "100", "tenant": "acme"}, false))
+	require.NoError(t, currSession.BindUser("u1"))
+	ttl, err := currSession.TTL()
+	require.NoError(t, err)
+
+	currSession.Clear()
+	require.Nil(t, currSession.Get("apple"))
+	require.Nil(t, currSession.Get("tenant"))
+	require.Equal(t, currSession.Id(), currSession.Get(p.field(sessionIdName)))
+	require.Equal(t, "u1", currSession.UserId())
+	require.False(t, p.client.SIsMember(p.getIndexKey("tenant", "acme"), currSession.Id()).Val())
+	require.Equal(t, int64(0), p.client.Exists(p.getFieldsKey(currSession.Id())).Val())
+	left, err := currSession.TTL()
+	require.NoError(t, err)
+	require.InDelta(t, float64(ttl), float64(left), float64(time.Second))
+}
+
+func TestSessionValueListener(t *testing.T) {
+	events := make(chan string, 4)
+	p := ProviderWithOptions(redisOptions, WithPrefixKey("_value_listener_:"), WithListener(&Listener{
+		Set:    func(session s.Session, name string, val interface{}) { events <- "set " + name + "=" + val.(string) },
+		Del:    func(session s.Session, name string) { events <- "del " + name },
+		Fields: []string{"role", "cart"},
+	}))
+	currSession := p.New(&s.Config{Valid: time.Minute}, nil)
+	currSession.Set("role", "admin")
+	require.Equal(t, "set role=admin", <-events)
+	currSession.Set("theme", "dark")
+	currSession.Del("role")
+	require.Equal(t, "del role", <-events)
+	currSession.Set("cart", "3")
+	require.Equal(t, "set cart=3", <-events)
+	currSession.Clear()
+	require.Equal(t, "del cart", <-events)
+	require.Len(t, events, 0)
+}
+
+func TestSessionFieldPrefix(t *testing.T) {
+	p := ProviderWithOptions(redisOptions, WithPrefixKey("_field_prefix_:"), WithFieldPrefix("__rsn:"), WithReservedFields("csrf"))
+	currSession := p.New(&s.Config{Valid: time.Minute}, nil).(*session)
+	require.Equal(t, currSession.Id(), currSession.Get("__rsn:"+sessionIdName))
+	require.Nil(t, currSession.Get(sessionIdName))
+
+	require.NoError(t, currSession.SetValue("userId", "app"))
+	require.NoError(t, currSession.BindUser("u1"))
+	require.Equal(t, "u1", currSession.UserId())
+	require.Equal(t, "app", currSession.Get("userId"))
+	require.Equal(t, ErrReservedField, currSession.SetValue("csrf", "x"))
+	require.Equal(t, ErrReservedField, currSession.SetValue("__rsn:userId", "x"))
+
+	require.NoError(t, p.client.HSet(currSession.key, "csrf", "token").Err())
+	currSession.Clear()
+	require.Nil(t, currSession.Get("userId"))
+	require.Equal(t, "token", currSession.Get("csrf"))
+	require.Equal(t, "u1", currSession.UserId())
+	require.NoError(t, currSession.Touch())
+	p.Refresh(currSession, &s.Config{Valid: time.Minute}, nil)
+	require.False(t, currSession.Invalidated())
+}
+
+func TestSessionIdField(t *testing.T) {
+	p := ProviderWithPrefixKey(redisOptions, "_id_field_:")
+	currSession := p.New(&s.Config{Valid: time.Minute}, nil).(*session)
+	defer p.Del(currSession.Id())
+	require.NoError(t, currSession.SetValue(LegacyIdField, "app"))
+	require.Equal(t, "app", currSession.Get(LegacyIdField))
+	require.Equal(t, currSession.Id(), currSession.Get("__rsn_id"))
+	require.Equal(t, ErrReservedField, currSession.SetValue("__rsn_id", "other"))
+
+	custom := ProviderWithOptions(redisOptions, WithPrefixKey("_id_field_:"), WithIdFieldName("_sid"))
+	customSession := custom.New(&s.Config{Valid: time.Minute}, nil).(*session)
+	defer custom.Del(customSession.Id())
+	require.Equal(t, customSession.Id(), customSession.Get("_sid"))
+	require.Nil(t, customSession.Get("__rsn_id"))
+	require.Equal(t, ErrReservedField, customSession.SetValue("_sid", "other"))
+	require.NoError(t, customSession.SetValue("id", "app"))
+
+	legacy := ProviderWithOptions(redisOptions, WithPrefixKey("_id_field_:"), WithLegacyIdField())
+	require.NoError(t, legacy.client.HSet(legacy.getRedisKey("OLD"), LegacyIdField, "OLD").Err())
+	defer legacy.Del("OLD")
+	stored := legacy.Get("OLD")
+	require.NotNil(t, stored)
+	require.Equal(t, "OLD", stored.Id())
+	require.Equal(t, ErrReservedField, stored.(*session).SetValue(LegacyIdField, "other"))
+	require.Nil(t, p.Get("OLD"))
+}
+
+func TestSessionInternalFieldNames(t *testing.T) {
+	p := ProviderWithPrefixKey(redisOptions, "_field_names_:")
+	currSession := p.New(&s.Config{Valid: time.Minute}, nil).(*session)
+	defer p.Del(currSession.Id())
+	require.NoError(t, currSession.BindUser("u1"))
+	for _, name := range []string{"userId", "ip", "userAgent", "device", "createdAt", "principal"} {
+		require.NoError(t, currSession.SetValue(name, "app"))
+		require.Equal(t, "app", currSession.Get(name))
+	}
+	require.Equal(t, "u1", currSession.UserId())
+	require.Equal(t, "u1", currSession.Get("__rsn_userId"))
+	require.Equal(t, ErrReservedField, currSession.SetValue("__rsn_userId", "u2"))
+	currSession.Clear()
+	require.Nil(t, currSession.Get("userId"))
+	require.Equal(t, "u1", currSession.UserId())
+
+	legacy := ProviderWithOptions(redisOptions, WithPrefixKey("_field_names_:"), WithLegacyFieldNames())
+	require.NoError(t, legacy.client.HMSet(legacy.getRedisKey("OLD"), map[string]interface{}{LegacyIdField: "OLD", "userId": "u3"}).Err())
+	defer legacy.Del("OLD")
+	stored := legacy.Get("OLD").(Session)
+	require.Equal(t, "u3", stored.UserId())
+	require.Equal(t, ErrReservedField, stored.SetValue("userId", "u4"))
+	require.NoError(t, stored.SetValue("id", "app"))
+}
@@ -0,0 +1,239 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	r "github.com/go-redis/redis"
+
+	s "github.com/go-the-way/anoweb/session"
+)
+
+// shardCheckInterval the pause between two health checks of the shards
+const shardCheckInterval = 5 * time.Second
+
+type shard struct {
+	name     string
+	provider *provider
+	down     int32
+}
+
+func (sh *shard) healthy() bool {
+	return atomic.LoadInt32(&sh.down) == 0
+}
+
+// setHealthy record the health of sh, delivering EventShardDown or EventShardUp when it changed
+func (sh *shard) setHealthy(healthy bool) {
+	down, kind := int32(1), EventShardDown
+	if healthy {
+		down, kind = 0, EventShardUp
+	}
+	if atomic.SwapInt32(&sh.down, down) != down {
+		sh.provider.events.emit(Event{Kind: kind, Shard: sh.name, Time: sh.provider.now()})
+	}
+}
+
+type shardedProvider struct {
+	shards []*shard
+	events *eventBus
+}
+
+// Sharded return a provider spreading sessions over standalone redis servers, for deployments without Cluster.
+// Every shard is a provider configured by opts.
+//
+// Session ids are placed by rendezvous hashing: each id ranks the shards, a new session goes to the first
+// one, or to the second while the first is unhealthy, and lookups read these two shards only. Adding a shard
+// therefore only draws about 1/n of the new sessions to it, while the existing sessions it now ranks first
+// stay readable on their former shard, ranked second, so shards can be added without moving keys.
+// Add them one at a time, once the sessions placed before the previous addition expired.
+// Removing a shard loses its sessions, so drain it first by keeping it until the sessions it holds expire.
+func Sharded(shards []*r.Options, opts ...Option) *shardedProvider {
+	sp := &shardedProvider{shards: make([]*shard, 0, len(shards)), events: &eventBus{}}
+	for _, options := range shards {
+		sh := &shard{
+			name:     fmt.Sprintf("%s/%d", options.Addr, options.DB),
+			provider: ProviderWithOptions(options, opts...),
+		}
+		sh.provider.events = sp.events
+		sp.shards = append(sp.shards, sh)
+	}
+	return sp
+}
+
+// rank return the shards ordered by their rendezvous score for id
+func (sp *shardedProvider) rank(id string) []*shard {
+	type scored struct {
+		shard *shard
+		score uint64
+	}
+	scores := make([]scored, 0, len(sp.shards))
+	for _, sh := range sp.shards {
+		scores = append(scores, scored{sh, fnv64a(sh.name, id)})
+	}
+	sort.Slice(scores, func(i, j int) bool { return scores[i].score > scores[j].score })
+	ranked := make([]*shard, 0, len(scores))
+	for _, sc := range scores {
+		ranked = append(ranked, sc.shard)
+	}
+	return ranked
+}
+
+// fnv64a return the 64-bit FNV-1a hash of texts one after the other, as hash/fnv without allocating a hasher
+func fnv64a(texts ...string) uint64 {
+	h := uint64(14695981039346656037)
+	for _, text := range texts {
+		for i := 0; i < len(text); i++ {
+			h ^= uint64(text[i])
+			h *= 1099511628211
+		}
+	}
+	return h
+}
+
+// owners return the shards session id may be held by: the one it ranks first, and the one it ranks second,
+// which took id while the first was down or held it before the first was added
+func (sp *shardedProvider) owners(id string) []*shard {
+	ranked := sp.rank(id)
+	if len(ranked) > 2 {
+		ranked = ranked[:2]
+	}
+	return ranked
+}
+
+// holder return the shard holding session id, nil if none does
+func (sp *shardedProvider) holder(id string) *shard {
+	for _, sh := range sp.owners(id) {
+		if sh.provider.Get(id) != nil {
+			return sh
+		}
+	}
+	return nil
+}
+
+// ShardOf return the shard provider holding session id, for the features not offered by the sharded provider
+// such as Find or Regenerate. It returns nil when no shard holds id.
+func (sp *shardedProvider) ShardOf(id string) *provider {
+	if sh := sp.holder(id); sh != nil {
+		return sh.provider
+	}
+	return nil
+}
+
+// Events return the channel delivering the events of every shard, and shard health changes
+func (sp *shardedProvider) Events() <-chan Event {
+	return sp.events.channel()
+}
+
+// Healthy report, for every shard named by its address and database, whether it passed its last health check
+func (sp *shardedProvider) Healthy() map[string]bool {
+	health := make(map[string]bool, len(sp.shards))
+	for _, sh := range sp.shards {
+		health[sh.name] = sh.healthy()
+	}
+	return health
+}
+
+// checkHealth ping every shard, recording which ones answer
+func (sp *shardedProvider) checkHealth() {
+	for _, sh := range sp.shards {
+		sh.setHealthy(sh.provider.client.Ping().Err() == nil)
+	}
+}
+
+// CookieName return cookie name
+func (sp *shardedProvider) CookieName() string {
+	return sp.shards[0].provider.CookieName()
+}
+
+// GetId get session id
+func (sp *shardedProvider) GetId(r *http.Request) string {
+	return sp.shards[0].provider.GetId(r)
+}
+
+// Exists session
+func (sp *shardedProvider) Exists(id string) bool {
+	sh := sp.holder(id)
+	return sh != nil && sh.provider.Exists(id)
+}
+
+// Get session
+func (sp *shardedProvider) Get(id string) s.Session {
+	if sh := sp.holder(id); sh != nil {
+		return sh.provider.Get(id)
+	}
+	return nil
+}
+
+// Del session
+func (sp *shardedProvider) Del(id string) {
+	if sh := sp.holder(id); sh != nil {
+		sh.provider.Del(id)
+	}
+}
+
+// GetAll return the sessions of every shard
+func (sp *shardedProvider) GetAll() map[string]s.Session {
+	sessions := make(map[string]s.Session)
+	for _, sh := range sp.shards {
+		for id, currentSession := range sh.provider.GetAll() {
+			sessions[id] = currentSession
+		}
+	}
+	return sessions
+}
+
+// Clear the sessions of every shard
+func (sp *shardedProvider) Clear() {
+	for _, sh := range sp.shards {
+		sh.provider.Clear()
+	}
+}
+
+// New return new session, stored on the first healthy shard of the two its id ranks first
+func (sp *shardedProvider) New(config *s.Config, listener *s.Listener) s.Session {
+	sessionId := sp.shards[0].provider.newSID()
+	for _, sh := range sp.owners(sessionId) {
+		if !sh.healthy() {
+			continue
+		}
+		if currentSession := sh.provider.create(sessionId, config, listener, nil); currentSession != nil {
+			return currentSession
+		}
+		sh.setHealthy(false)
+	}
+	return nil
+}
+
+// Refresh session
+func (sp *shardedProvider) Refresh(session s.Session, config *s.Config, listener *s.Listener) {
+	if sh := sp.holder(session.Id()); sh != nil {
+		sh.provider.Refresh(session, config, listener)
+	}
+}
+
+// Clean the sessions of every shard, and check their health
+func (sp *shardedProvider) Clean(config *s.Config, listener *s.Listener) {
+	for _, sh := range sp.shards {
+		sh.provider.Clean(config, listener)
+	}
+	go func() {
+		for {
+			sp.checkHealth()
+			time.Sleep(shardCheckInterval)
+		}
+	}()
+}
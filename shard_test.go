@@ -0,0 +1,84 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"testing"
+	"time"
+
+	rds "github.com/go-redis/redis"
+
+	s "github.com/go-the-way/anoweb/session"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestShardedProvider(t *testing.T) {
+	first, second := *redisOptions, *redisOptions
+	first.DB, second.DB = 3, 4
+	sp := Sharded([]*rds.Options{&first, &second}, WithPrefixKey("_shard_:"))
+	var _ s.Provider = sp
+
+	placed := map[*provider]int{}
+	ids := make([]string, 0)
+	for i := 0; i < 20; i++ {
+		currSession := sp.New(&s.Config{Valid: time.Minute}, nil)
+		require.NotNil(t, currSession)
+		require.True(t, sp.Exists(currSession.Id()))
+		require.Equal(t, currSession, sp.Get(currSession.Id()))
+		placed[sp.ShardOf(currSession.Id())]++
+		ids = append(ids, currSession.Id())
+	}
+	require.Len(t, placed, 2)
+	require.Len(t, sp.GetAll(), 20)
+
+	sp.shards[0].setHealthy(false)
+	require.False(t, sp.Healthy()[sp.shards[0].name])
+	for i := 0; i < 5; i++ {
+		currSession := sp.New(&s.Config{Valid: time.Minute}, nil)
+		require.True(t, sp.ShardOf(currSession.Id()) == sp.shards[1].provider)
+	}
+	for _, id := range ids {
+		require.True(t, sp.Exists(id))
+	}
+	sp.checkHealth()
+	require.True(t, sp.Healthy()[sp.shards[0].name])
+
+	sp.Del(ids[0])
+	require.False(t, sp.Exists(ids[0]))
+	sp.Clear()
+	require.Empty(t, sp.GetAll())
+}
+
+func TestShardedProviderAddShard(t *testing.T) {
+	first, second, third := *redisOptions, *redisOptions, *redisOptions
+	first.DB, second.DB, third.DB = 3, 4, 5
+	before := Sharded([]*rds.Options{&first, &second}, WithPrefixKey("_shard_add_:"))
+	ids := make([]string, 0)
+	for i := 0; i < 20; i++ {
+		ids = append(ids, before.New(&s.Config{Valid: time.Minute}, nil).Id())
+	}
+
+	after := Sharded([]*rds.Options{&first, &second, &third}, WithPrefixKey("_shard_add_:"))
+	for _, id := range ids {
+		require.True(t, after.Exists(id))
+	}
+
+	// a session on the shard its id ranks third isn't looked up
+	id := after.shards[0].provider.newSID()
+	ranked := after.rank(id)
+	require.NotNil(t, ranked[2].provider.create(id, &s.Config{Valid: time.Minute}, nil, nil))
+	require.Nil(t, after.Get(id))
+	require.Nil(t, after.ShardOf(id))
+	ranked[2].provider.Del(id)
+	after.Clear()
+}
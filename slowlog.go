@@ -0,0 +1,94 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// SlowOp describe a redis operation which took longer than the threshold of WithSlowLog
+type SlowOp struct {
+	// Op the lower case command name, or "pipeline"
+	Op string
+	// Key the first key touched, empty if none
+	Key string
+	// Took how long the operation took
+	Took time.Duration
+	// Caller the file:line of the code outside rsn which led to the operation, or of the rsn
+	// function running it when there is none, e.g. for the cleaning pass
+	Caller string
+	// Err of the operation, nil when a key was just missing
+	Err error
+}
+
+// String return op as a log line
+func (op SlowOp) String() string {
+	return fmt.Sprintf("rsn: slow %s %q took %s at %s", op.Op, op.Key, op.Took, op.Caller)
+}
+
+type slowLog struct {
+	threshold time.Duration
+	log       func(op SlowOp)
+}
+
+// wrap return hooks calling h and logging the operations slower than the threshold
+func (l *slowLog) wrap(h *Hooks) *Hooks {
+	wrapped := &Hooks{}
+	if h != nil {
+		wrapped.BeforeOp = h.BeforeOp
+	}
+	wrapped.AfterOp = func(op, key string, took time.Duration, err error) {
+		if h != nil && h.AfterOp != nil {
+			h.AfterOp(op, key, took, err)
+		}
+		if took < l.threshold {
+			return
+		}
+		slow := SlowOp{Op: op, Key: key, Took: took, Caller: caller(), Err: err}
+		if l.log != nil {
+			l.log(slow)
+			return
+		}
+		_, _ = fmt.Fprintln(os.Stderr, slow)
+	}
+	return wrapped
+}
+
+const (
+	rsnPackage   = "github.com/go-the-way/rsn."
+	redisPackage = "github.com/go-redis/"
+)
+
+// caller return the file:line of the first frame outside rsn and the redis client,
+// or of the first rsn frame past the hooks when the operation wasn't started from outside
+func caller() string {
+	pcs := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs)])
+	inner := ""
+	for {
+		frame, more := frames.Next()
+		switch {
+		case strings.HasPrefix(frame.Function, "runtime."), strings.HasPrefix(frame.Function, redisPackage):
+		case !strings.HasPrefix(frame.Function, rsnPackage), strings.HasSuffix(frame.File, "_test.go"):
+			return fmt.Sprintf("%s:%d", frame.File, frame.Line)
+		case inner == "" && !strings.HasSuffix(frame.File, "/hooks.go") && !strings.HasSuffix(frame.File, "/slowlog.go"):
+			inner = fmt.Sprintf("%s:%d", frame.File, frame.Line)
+		}
+		if !more {
+			return inner
+		}
+	}
+}
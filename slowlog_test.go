@@ -0,0 +1,73 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	s "github.com/go-the-way/anoweb/session"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProviderSlowLog(t *testing.T) {
+	var mu sync.Mutex
+	var slow []SlowOp
+	hooked := 0
+	p := ProviderWithOptions(redisOptions, WithPrefixKey("_slowlog_:"),
+		WithSlowLog(0, func(op SlowOp) {
+			mu.Lock()
+			defer mu.Unlock()
+			slow = append(slow, op)
+		}),
+		WithHooks(&Hooks{AfterOp: func(op, key string, took time.Duration, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			hooked++
+		}}))
+	currSession := p.New(&s.Config{Valid: time.Minute}, nil)
+	require.Nil(t, currSession.Get("missing"))
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.NotZero(t, hooked)
+	var get *SlowOp
+	for i := range slow {
+		if slow[i].Op == "hget" {
+			get = &slow[i]
+		}
+	}
+	require.NotNil(t, get)
+	require.Equal(t, p.getRedisKey(currSession.Id()), get.Key)
+	require.True(t, strings.Contains(get.Caller, "slowlog_test.go:"), get.Caller)
+	require.Contains(t, get.String(), "rsn: slow hget")
+}
+
+func TestProviderSlowLogThreshold(t *testing.T) {
+	var mu sync.Mutex
+	logged := 0
+	p := ProviderWithOptions(redisOptions, WithPrefixKey("_slowlog_:"),
+		WithSlowLog(time.Hour, func(op SlowOp) {
+			mu.Lock()
+			defer mu.Unlock()
+			logged++
+		}))
+	currSession := p.New(&s.Config{Valid: time.Minute}, nil)
+	currSession.Set("apple", "100")
+	p.Del(currSession.Id())
+	mu.Lock()
+	defer mu.Unlock()
+	require.Zero(t, logged)
+}
@@ -0,0 +1,123 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"time"
+
+	r "github.com/go-redis/redis"
+
+	s "github.com/go-the-way/anoweb/session"
+)
+
+// Snapshot is an immutable copy of a session, internal fields included, with its remaining ttl
+type Snapshot struct {
+	id     string
+	values map[string]string
+	ttl    time.Duration
+}
+
+// NewSnapshot return a snapshot of session id holding values, with ttl left, negative if it never expires
+func NewSnapshot(id string, values map[string]string, ttl time.Duration) *Snapshot {
+	copied := make(map[string]string, len(values))
+	for k, v := range values {
+		copied[k] = v
+	}
+	return &Snapshot{id: id, values: copied, ttl: ttl}
+}
+
+// Id return the id of the session taken
+func (snap *Snapshot) Id() string {
+	return snap.id
+}
+
+// Get return named val, empty if the session didn't hold it
+func (snap *Snapshot) Get(name string) string {
+	return snap.values[name]
+}
+
+// Values return a copy of the values of the session taken
+func (snap *Snapshot) Values() map[string]string {
+	values := make(map[string]string, len(snap.values))
+	for k, v := range snap.values {
+		values[k] = v
+	}
+	return values
+}
+
+// TTL return the time the session had left to live, negative if it never expires
+func (snap *Snapshot) TTL() time.Duration {
+	return snap.ttl
+}
+
+// Snapshot return a copy of session with its ttl, read in one transaction
+func (s *session) Snapshot() (*Snapshot, error) {
+	var getAllCmd *r.StringStringMapCmd
+	var ttlCmd *r.DurationCmd
+	_, err := s.client.TxPipelined(func(pipe r.Pipeliner) error {
+		getAllCmd = pipe.HGetAll(s.key)
+		ttlCmd = pipe.PTTL(s.key)
+		return nil
+	})
+	if err != nil {
+		return nil, wrapErr(err)
+	}
+	if len(getAllCmd.Val()) == 0 {
+		return nil, ErrSessionNotFound
+	}
+	return &Snapshot{id: s.id, values: getAllCmd.Val(), ttl: ttlCmd.Val()}, nil
+}
+
+// RestoreSnapshot recreate the session taken by snap under its id, replacing what the id holds now,
+// with the ttl it had left and its indexes and user binding, e.g. to undo an impersonation.
+func (p *provider) RestoreSnapshot(snap *Snapshot) (s.Session, error) {
+	id := snap.id
+	p.unindex(id)
+	p.unbindUser(id)
+	key := p.getRedisKey(id)
+	values := make(map[string]interface{}, len(snap.values))
+	for k, v := range snap.values {
+		values[k] = v
+	}
+	_, err := p.client.TxPipelined(func(pipe r.Pipeliner) error {
+		pipe.Del(key)
+		if len(values) > 0 {
+			pipe.HMSet(key, values)
+		}
+		if snap.ttl > 0 {
+			pipe.PExpire(key, snap.ttl)
+		}
+		for field := range p.indexes {
+			if value, have := snap.values[field]; have {
+				pipe.SAdd(p.getIndexKey(field, value), id)
+			}
+		}
+		if userId, have := snap.values[p.field(userIdName)]; have {
+			pipe.SAdd(p.getUserKey(userId), id)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, wrapErr(err)
+	}
+	if snap.ttl > 0 {
+		p.indexExpiry(id, snap.ttl)
+	} else {
+		p.unindexExpiry(id)
+	}
+	currentSession := newSession(p, id)
+	currentSession.userId = snap.values[p.field(userIdName)]
+	p.mu.Lock()
+	p.store(id, currentSession)
+	p.mu.Unlock()
+	return currentSession, nil
+}
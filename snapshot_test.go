@@ -0,0 +1,55 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"testing"
+	"time"
+
+	s "github.com/go-the-way/anoweb/session"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionSnapshot(t *testing.T) {
+	p := ProviderWithOptions(redisOptions, WithPrefixKey("_snapshot_:"), WithIndex("role"))
+	currSession := p.New(&s.Config{Valid: time.Minute}, nil).(*session)
+	require.NoError(t, currSession.SetValue("role", "user"))
+	require.NoError(t, currSession.BindUser("u1"))
+
+	snap, err := currSession.Snapshot()
+	require.NoError(t, err)
+	require.Equal(t, currSession.Id(), snap.Id())
+	require.Equal(t, "user", snap.Get("role"))
+	require.Equal(t, currSession.Id(), snap.Get(p.field(sessionIdName)))
+	require.InDelta(t, float64(time.Minute), float64(snap.TTL()), float64(time.Second))
+	snap.Values()["role"] = "changed"
+	require.Equal(t, "user", snap.Get("role"))
+
+	require.NoError(t, currSession.SetValue("role", "admin"))
+	require.NoError(t, currSession.BindUser("admin"))
+	restored, err := p.RestoreSnapshot(snap)
+	require.NoError(t, err)
+	require.Equal(t, "user", restored.Get("role"))
+	require.Equal(t, "u1", restored.(Session).UserId())
+	require.False(t, p.client.SIsMember(p.getIndexKey("role", "admin"), currSession.Id()).Val())
+	require.True(t, p.client.SIsMember(p.getIndexKey("role", "user"), currSession.Id()).Val())
+	require.False(t, p.client.SIsMember(p.getUserKey("admin"), currSession.Id()).Val())
+	require.True(t, p.client.SIsMember(p.getUserKey("u1"), currSession.Id()).Val())
+	ttl, err := restored.(Session).TTL()
+	require.NoError(t, err)
+	require.True(t, ttl > 0)
+
+	p.Del(currSession.Id())
+	_, err = currSession.Snapshot()
+	require.Equal(t, ErrSessionNotFound, err)
+}
@@ -0,0 +1,467 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	r "github.com/go-redis/redis"
+
+	s "github.com/go-the-way/anoweb/session"
+)
+
+// SpringValueCodec encode the values of hash fields as the RedisSerializer of Spring Session does
+type SpringValueCodec interface {
+	// Encode return the stored form of val
+	Encode(val interface{}) ([]byte, error)
+	// Decode return the value of stored b
+	Decode(b []byte) (interface{}, error)
+}
+
+// SpringJSONCodec is the SpringValueCodec matching GenericJackson2JsonRedisSerializer for plain values
+type SpringJSONCodec struct{}
+
+// Encode return val as json
+func (SpringJSONCodec) Encode(val interface{}) ([]byte, error) {
+	return json.Marshal(val)
+}
+
+// Decode return the value of json b
+func (SpringJSONCodec) Decode(b []byte) (interface{}, error) {
+	var val interface{}
+	if err := json.Unmarshal(b, &val); err != nil {
+		return nil, err
+	}
+	return val, nil
+}
+
+const (
+	springCreationTime        = "creationTime"
+	springLastAccessedTime    = "lastAccessedTime"
+	springMaxInactiveInterval = "maxInactiveInterval"
+	springAttrPrefix          = "sessionAttr:"
+)
+
+// springExpiryGrace how much longer than its max inactive interval Spring Session keeps a session hash,
+// so listeners of the expiry get to read it
+const springExpiryGrace = 5 * time.Minute
+
+type springProvider struct {
+	p     *provider
+	codec SpringValueCodec
+}
+
+var _ s.Provider = (*springProvider)(nil)
+
+// SpringSessionProvider return new provider sharing sessions with Java services using Spring Session
+// Data Redis with its indexed repository: the spring:session: key prefix, session hashes holding
+// sessionAttr: fields, the expires keys and the per-minute expirations sets, and the SESSION cookie
+// holding the base64 encoded id. Values are encoded by codec, SpringJSONCodec when nil, which
+// needs Spring configured with GenericJackson2JsonRedisSerializer. opts may override the key prefix.
+func SpringSessionProvider(options *r.Options, codec SpringValueCodec, opts ...Option) *springProvider {
+	if codec == nil {
+		codec = SpringJSONCodec{}
+	}
+	springOpts := []Option{
+		WithPrefixKey("spring:session:"),
+		WithCookieName("SESSION"),
+		WithIdValidator(springId),
+		func(p *provider) {
+			p.cookieCodec = &cookieCodec{
+				encode: func(id string) string {
+					return base64.StdEncoding.EncodeToString([]byte(id))
+				},
+				decode: func(value string) (string, bool) {
+					id, err := base64.StdEncoding.DecodeString(value)
+					return string(id), err == nil
+				},
+			}
+		},
+	}
+	return &springProvider{p: newProvider(options, append(springOpts, opts...)...), codec: codec}
+}
+
+// springId report whether id is well-formed as generated by Spring Session, a uuid, or by rsn
+func springId(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, c := range id {
+		if (c < '0' || c > '9') && (c < 'A' || c > 'F') && (c < 'a' || c > 'f') && c != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+// sessionKey return the key of the hash of session id
+func (sp *springProvider) sessionKey(id string) string {
+	return sp.p.keyPrefix + "sessions:" + id
+}
+
+// expiresKey return the key whose expiry tells Spring session id expired
+func (sp *springProvider) expiresKey(id string) string {
+	return sp.p.keyPrefix + "sessions:expires:" + id
+}
+
+// expirationsKey return the key of the set of the sessions expiring within the minute before expiresAt
+func (sp *springProvider) expirationsKey(expiresAt int64) string {
+	minute := int64(time.Minute / time.Millisecond)
+	return sp.p.keyPrefix + "expirations:" + strconv.FormatInt((expiresAt+minute-1)/minute*minute, 10)
+}
+
+// CookieName return session cookie name
+func (sp *springProvider) CookieName() string {
+	return sp.p.CookieName()
+}
+
+// GetId get session id, empty when the cookie is missing or malformed
+func (sp *springProvider) GetId(r *http.Request) string {
+	return sp.p.GetId(r)
+}
+
+// Cookie return the session cookie to set on the response
+func (sp *springProvider) Cookie(session s.Session, config *s.Config) *http.Cookie {
+	return sp.p.Cookie(session, config)
+}
+
+// Exists session
+func (sp *springProvider) Exists(id string) bool {
+	return sp.Get(id) != nil
+}
+
+// Get load session id, nil when it doesn't exist or expired
+func (sp *springProvider) Get(id string) s.Session {
+	fields, err := sp.p.client.HGetAll(sp.sessionKey(id)).Result()
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		return nil
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	ss := &springSession{provider: sp, id: id, values: map[string]interface{}{}}
+	for name, stored := range fields {
+		val, err := sp.codec.Decode([]byte(stored))
+		if err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, err)
+			continue
+		}
+		switch {
+		case strings.HasPrefix(name, springAttrPrefix):
+			ss.values[strings.TrimPrefix(name, springAttrPrefix)] = val
+		case name == springLastAccessedTime:
+			ss.lastAccessed = springInt(val)
+		case name == springMaxInactiveInterval:
+			ss.maxInactive = springInt(val)
+		}
+	}
+	if ss.maxInactive > 0 && ss.expiresAt() <= sp.p.now().UnixNano()/int64(time.Millisecond) {
+		return nil
+	}
+	return ss
+}
+
+// springInt return the number val decoded from a field, 0 when it isn't one
+func springInt(val interface{}) int64 {
+	switch v := val.(type) {
+	case float64:
+		return int64(v)
+	case int64:
+		return v
+	case string:
+		i, _ := strconv.ParseInt(v, 10, 64)
+		return i
+	}
+	return 0
+}
+
+// GetAll load every session
+func (sp *springProvider) GetAll() map[string]s.Session {
+	sessions := map[string]s.Session{}
+	prefix := sp.sessionKey("")
+	iter := sp.p.scans().Scan(0, prefix+"*", sp.p.scanCount()).Iterator()
+	for iter.Next() {
+		id := strings.TrimPrefix(iter.Val(), prefix)
+		if strings.Contains(id, ":") {
+			continue
+		}
+		if currentSession := sp.Get(id); currentSession != nil {
+			sessions[id] = currentSession
+		}
+	}
+	if err := iter.Err(); err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+	}
+	return sessions
+}
+
+// Del session
+func (sp *springProvider) Del(id string) {
+	if currentSession := sp.Get(id); currentSession != nil {
+		currentSession.Invalidate()
+		return
+	}
+	if err := sp.p.client.Del(sp.sessionKey(id), sp.expiresKey(id)).Err(); err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+	}
+}
+
+// Clear sessions
+func (sp *springProvider) Clear() {
+	for _, currentSession := range sp.GetAll() {
+		currentSession.Invalidate()
+	}
+}
+
+// New return new session, stored as Spring Session does
+func (sp *springProvider) New(config *s.Config, listener *s.Listener) s.Session {
+	now := sp.p.now().UnixNano() / int64(time.Millisecond)
+	ss := &springSession{provider: sp, id: sp.p.newSID(), values: map[string]interface{}{}}
+	creationTime, err := sp.codec.Encode(now)
+	if err == nil {
+		err = sp.p.client.HSet(sp.sessionKey(ss.id), springCreationTime, creationTime).Err()
+	}
+	if err == nil {
+		err = ss.renew(sp.p.valid(config))
+	}
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		return nil
+	}
+	sp.p.dispatch(ss.Id(), func() {
+		if listener != nil && listener.Created != nil {
+			listener.Created(ss)
+		}
+	})
+	return ss
+}
+
+// Refresh session
+func (sp *springProvider) Refresh(session s.Session, config *s.Config, listener *s.Listener) {
+	session.Renew(sp.p.valid(config))
+	sp.p.dispatch(session.Id(), func() {
+		if listener != nil && listener.Refreshed != nil {
+			listener.Refreshed(session)
+		}
+	})
+}
+
+// Clean do nothing, redis and the Spring services expiring the sessions
+func (sp *springProvider) Clean(*s.Config, *s.Listener) {}
+
+type springSession struct {
+	provider     *springProvider
+	id           string
+	mu           sync.Mutex
+	values       map[string]interface{}
+	lastAccessed int64
+	maxInactive  int64
+	invalidated  bool
+}
+
+var _ s.Session = (*springSession)(nil)
+
+// expiresAt return when session expires in unix milliseconds, the lock held
+func (ss *springSession) expiresAt() int64 {
+	return ss.lastAccessed + ss.maxInactive*1000
+}
+
+// Id return session id
+func (ss *springSession) Id() string {
+	return ss.id
+}
+
+// Renew session, marking it accessed now and inactive for at most lifeTime
+func (ss *springSession) Renew(lifeTime time.Duration) {
+	if err := ss.renew(lifeTime); err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+	}
+}
+
+// renew store the access time and max inactive interval of session, and move it to the expirations set
+// of its new expiry as Spring Session does
+func (ss *springSession) renew(lifeTime time.Duration) error {
+	sp := ss.provider
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	oldExpiresAt := ss.expiresAt()
+	ss.lastAccessed = sp.p.now().UnixNano() / int64(time.Millisecond)
+	ss.maxInactive = int64(lifeTime / time.Second)
+	lastAccessed, err := sp.codec.Encode(ss.lastAccessed)
+	if err != nil {
+		return err
+	}
+	maxInactive, err := sp.codec.Encode(ss.maxInactive)
+	if err != nil {
+		return err
+	}
+	expiresAt := ss.expiresAt()
+	_, err = sp.p.client.TxPipelined(func(pipe r.Pipeliner) error {
+		pipe.HMSet(sp.sessionKey(ss.id), map[string]interface{}{
+			springLastAccessedTime:    lastAccessed,
+			springMaxInactiveInterval: maxInactive,
+		})
+		if oldExpiresAt > 0 && sp.expirationsKey(oldExpiresAt) != sp.expirationsKey(expiresAt) {
+			pipe.SRem(sp.expirationsKey(oldExpiresAt), "expires:"+ss.id)
+		}
+		pipe.SAdd(sp.expirationsKey(expiresAt), "expires:"+ss.id)
+		pipe.PExpire(sp.expirationsKey(expiresAt), lifeTime+springExpiryGrace)
+		pipe.Set(sp.expiresKey(ss.id), "", lifeTime)
+		pipe.PExpire(sp.sessionKey(ss.id), lifeTime+springExpiryGrace)
+		return nil
+	})
+	return err
+}
+
+// Invalidate session, deleting it as Spring Session does
+func (ss *springSession) Invalidate() {
+	sp := ss.provider
+	ss.mu.Lock()
+	ss.invalidated = true
+	expiresAt := ss.expiresAt()
+	ss.mu.Unlock()
+	_, err := sp.p.client.TxPipelined(func(pipe r.Pipeliner) error {
+		pipe.Del(sp.sessionKey(ss.id), sp.expiresKey(ss.id))
+		pipe.SRem(sp.expirationsKey(expiresAt), "expires:"+ss.id)
+		return nil
+	})
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+	}
+}
+
+// Invalidated session
+func (ss *springSession) Invalidated() bool {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	return ss.invalidated
+}
+
+// Get session named val, as loaded with the session
+func (ss *springSession) Get(name string) interface{} {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	return ss.values[name]
+}
+
+// GetAll session's values, as loaded with the session
+func (ss *springSession) GetAll() map[string]interface{} {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	values := make(map[string]interface{}, len(ss.values))
+	for k, v := range ss.values {
+		values[k] = v
+	}
+	return values
+}
+
+// Set named val into session
+func (ss *springSession) Set(name string, val interface{}) {
+	ss.SetAll(map[string]interface{}{name: val}, false)
+}
+
+// SetAll values into session, encoding every value before anything is cleared or written,
+// then clearing its values if flush and writing the new ones in one transaction
+func (ss *springSession) SetAll(data map[string]interface{}, flush bool) {
+	fields := make(map[string]interface{}, len(data))
+	for name, val := range data {
+		stored, err := ss.provider.codec.Encode(val)
+		if err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, err)
+			return
+		}
+		fields[springAttrPrefix+name] = stored
+	}
+	key := ss.provider.sessionKey(ss.id)
+	client := ss.provider.p.client
+	stale := make([]string, 0)
+	if flush {
+		names, err := client.HKeys(key).Result()
+		if err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, err)
+			return
+		}
+		for _, name := range names {
+			if _, kept := fields[name]; !kept && strings.HasPrefix(name, springAttrPrefix) {
+				stale = append(stale, name)
+			}
+		}
+	}
+	if len(fields) > 0 || len(stale) > 0 {
+		_, err := client.TxPipelined(func(pipe r.Pipeliner) error {
+			if len(stale) > 0 {
+				pipe.HDel(key, stale...)
+			}
+			if len(fields) > 0 {
+				pipe.HMSet(key, fields)
+			}
+			return nil
+		})
+		if err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, err)
+			return
+		}
+	}
+	ss.mu.Lock()
+	if flush {
+		ss.values = map[string]interface{}{}
+	}
+	for name, val := range data {
+		ss.values[name] = val
+	}
+	ss.mu.Unlock()
+}
+
+// Del named val from session
+func (ss *springSession) Del(name string) {
+	if err := ss.provider.p.client.HDel(ss.provider.sessionKey(ss.id), springAttrPrefix+name).Err(); err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	ss.mu.Lock()
+	delete(ss.values, name)
+	ss.mu.Unlock()
+}
+
+// Clear session's values, including those set by Spring services since it was loaded
+func (ss *springSession) Clear() {
+	key := ss.provider.sessionKey(ss.id)
+	names, err := ss.provider.p.client.HKeys(key).Result()
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	fields := make([]string, 0, len(names))
+	for _, name := range names {
+		if strings.HasPrefix(name, springAttrPrefix) {
+			fields = append(fields, name)
+		}
+	}
+	if len(fields) > 0 {
+		if err = ss.provider.p.client.HDel(key, fields...).Err(); err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, err)
+			return
+		}
+	}
+	ss.mu.Lock()
+	ss.values = map[string]interface{}{}
+	ss.mu.Unlock()
+}
@@ -0,0 +1,71 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	s "github.com/go-the-way/anoweb/session"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpringSessionProvider(t *testing.T) {
+	sp := SpringSessionProvider(redisOptions, nil, WithPrefixKey("_spring_:"))
+	require.Equal(t, "SESSION", sp.CookieName())
+
+	// as stored by Spring Session with GenericJackson2JsonRedisSerializer
+	id := "5b6a9a2e-0b7e-4f5e-9d0f-3f1c2a4b5c6d"
+	now := strconv.FormatInt(time.Now().UnixNano()/int64(time.Millisecond), 10)
+	require.NoError(t, sp.p.client.HMSet("_spring_:sessions:"+id, map[string]interface{}{
+		"creationTime":        now,
+		"lastAccessedTime":    now,
+		"maxInactiveInterval": "1800",
+		"sessionAttr:user":    `"bob"`,
+	}).Err())
+	req, _ := http.NewRequest("", "", nil)
+	req.AddCookie(&http.Cookie{Name: "SESSION", Value: base64.StdEncoding.EncodeToString([]byte(id))})
+	require.Equal(t, id, sp.GetId(req))
+	require.True(t, sp.Exists(id))
+	javaSession := sp.Get(id)
+	require.Equal(t, "bob", javaSession.Get("user"))
+	javaSession.Set("role", "admin")
+	require.Equal(t, `"admin"`, sp.p.client.HGet("_spring_:sessions:"+id, "sessionAttr:role").Val())
+	sp.Del(id)
+	require.False(t, sp.Exists(id))
+
+	currSession := sp.New(&s.Config{Valid: time.Minute}, nil)
+	currSession.Set("user", "alice")
+	require.Equal(t, int64(1), sp.p.client.Exists("_spring_:sessions:expires:"+currSession.Id()).Val())
+	require.Equal(t, "60", sp.p.client.HGet("_spring_:sessions:"+currSession.Id(), "maxInactiveInterval").Val())
+	ttl := sp.p.client.PTTL("_spring_:sessions:" + currSession.Id()).Val()
+	require.True(t, ttl > time.Minute && ttl <= time.Minute+springExpiryGrace)
+	expirations := sp.p.client.Keys("_spring_:expirations:*").Val()
+	require.Len(t, expirations, 1)
+	require.True(t, sp.p.client.SIsMember(expirations[0], "expires:"+currSession.Id()).Val())
+	require.Len(t, sp.GetAll(), 1)
+
+	currSession.SetAll(map[string]interface{}{"role": "admin"}, true)
+	require.Nil(t, sp.Get(currSession.Id()).Get("user"))
+	require.Equal(t, "admin", currSession.Get("role"))
+	require.Equal(t, `"admin"`, sp.p.client.HGet("_spring_:sessions:"+currSession.Id(), "sessionAttr:role").Val())
+
+	currSession.Clear()
+	require.Nil(t, sp.Get(currSession.Id()).Get("role"))
+	currSession.Invalidate()
+	require.Zero(t, sp.p.client.Exists("_spring_:sessions:"+currSession.Id(), "_spring_:sessions:expires:"+currSession.Id()).Val())
+	require.False(t, sp.p.client.SIsMember(expirations[0], "expires:"+currSession.Id()).Val())
+}
@@ -0,0 +1,146 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	r "github.com/go-redis/redis"
+)
+
+// AuthLevel is how strongly the user of a session proved their identity, higher levels being stronger
+type AuthLevel int
+
+const (
+	// AuthNone no proof of identity
+	AuthNone AuthLevel = iota
+	// AuthPassword a password
+	AuthPassword
+	// AuthSecondFactor a password and a second factor, such as a one-time code
+	AuthSecondFactor
+	// AuthWebAuthn a WebAuthn credential
+	AuthWebAuthn
+)
+
+// ErrStepUpRequired returned by RequireLevel when the user must authenticate at a higher level first
+var ErrStepUpRequired = errors.New("rsn: step-up authentication required")
+
+// parseAuthLevels return the elevations stored as "level:until,..." with until in unix milliseconds
+func parseAuthLevels(val string) map[AuthLevel]time.Time {
+	levels := map[AuthLevel]time.Time{}
+	for _, entry := range strings.Split(val, ",") {
+		parts := strings.SplitN(entry, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		level, err := strconv.Atoi(parts[0])
+		if err != nil {
+			continue
+		}
+		levels[AuthLevel(level)] = parseTime(parts[1])
+	}
+	return levels
+}
+
+// formatAuthLevels return levels as stored by parseAuthLevels, dropping those ended by now
+func formatAuthLevels(levels map[AuthLevel]time.Time, now time.Time) string {
+	entries := make([]string, 0, len(levels))
+	for level, until := range levels {
+		if until.After(now) {
+			entries = append(entries, strconv.Itoa(int(level))+":"+formatTime(until))
+		}
+	}
+	return strings.Join(entries, ",")
+}
+
+// authLevels return the elevations of session
+func (s *session) authLevels() (map[AuthLevel]time.Time, error) {
+	val, err := s.client.HGet(s.key, s.provider.field(authLevelsName)).Result()
+	if err != nil && err != r.Nil {
+		return nil, wrapErr(err)
+	}
+	return parseAuthLevels(val), nil
+}
+
+// Elevate record that the user of session just authenticated at level, which is granted until valid elapsed
+func (s *session) Elevate(level AuthLevel, valid time.Duration) error {
+	if s.invalidated {
+		return ErrSessionNotFound
+	}
+	levels, err := s.authLevels()
+	if err != nil {
+		return err
+	}
+	now := s.provider.now()
+	levels[level] = now.Add(valid)
+	return s.provider.criticalWrite(s.client, func(pipe r.Pipeliner) {
+		pipe.HSet(s.key, s.provider.field(authLevelsName), formatAuthLevels(levels, now))
+	})
+}
+
+// ElevatedUntil return when session stops being granted level or a higher one, zero time when it isn't
+func (s *session) ElevatedUntil(level AuthLevel) time.Time {
+	levels, err := s.authLevels()
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		return time.Time{}
+	}
+	until := time.Time{}
+	for granted, grantedUntil := range levels {
+		if granted >= level && grantedUntil.After(until) {
+			until = grantedUntil
+		}
+	}
+	if !until.After(s.provider.now()) {
+		return time.Time{}
+	}
+	return until
+}
+
+// AuthLevel return the highest level session is granted now
+func (s *session) AuthLevel() AuthLevel {
+	levels, err := s.authLevels()
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		return AuthNone
+	}
+	now, highest := s.provider.now(), AuthNone
+	for level, until := range levels {
+		if level > highest && until.After(now) {
+			highest = level
+		}
+	}
+	return highest
+}
+
+// RequireLevel return ErrStepUpRequired unless session is granted level or a higher one now
+func (s *session) RequireLevel(level AuthLevel) error {
+	if level <= AuthNone {
+		return nil
+	}
+	levels, err := s.authLevels()
+	if err != nil {
+		return err
+	}
+	now := s.provider.now()
+	for granted, until := range levels {
+		if granted >= level && until.After(now) {
+			return nil
+		}
+	}
+	return ErrStepUpRequired
+}
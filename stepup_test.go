@@ -0,0 +1,48 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"testing"
+	"time"
+
+	s "github.com/go-the-way/anoweb/session"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionStepUp(t *testing.T) {
+	clock := &fixedClock{time.Now()}
+	p := ProviderWithOptions(redisOptions, WithPrefixKey("_stepup_:"), WithClock(clock))
+	currSession := p.New(&s.Config{Valid: time.Hour}, nil).(Session)
+	require.Equal(t, AuthNone, currSession.AuthLevel())
+	require.NoError(t, currSession.RequireLevel(AuthNone))
+	require.Equal(t, ErrStepUpRequired, currSession.RequireLevel(AuthPassword))
+
+	require.NoError(t, currSession.Elevate(AuthPassword, time.Hour))
+	require.NoError(t, currSession.Elevate(AuthSecondFactor, time.Minute*5))
+	require.Equal(t, AuthSecondFactor, currSession.AuthLevel())
+	require.NoError(t, currSession.RequireLevel(AuthPassword))
+	require.NoError(t, currSession.RequireLevel(AuthSecondFactor))
+	require.Equal(t, ErrStepUpRequired, currSession.RequireLevel(AuthWebAuthn))
+	require.Equal(t, clock.now.Add(time.Minute*5).Unix(), currSession.ElevatedUntil(AuthSecondFactor).Unix())
+	require.Equal(t, clock.now.Add(time.Hour).Unix(), currSession.ElevatedUntil(AuthPassword).Unix())
+	currSession.Set(p.field(authLevelsName), "9:99999999999999")
+	require.Equal(t, AuthSecondFactor, currSession.AuthLevel())
+
+	clock.now = clock.now.Add(time.Minute * 10)
+	require.Equal(t, AuthPassword, currSession.AuthLevel())
+	require.Equal(t, ErrStepUpRequired, currSession.RequireLevel(AuthSecondFactor))
+	require.True(t, currSession.ElevatedUntil(AuthSecondFactor).IsZero())
+	require.Equal(t, ErrReadOnlySession, currSession.ReadOnly().Elevate(AuthWebAuthn, time.Minute))
+	p.Del(currSession.Id())
+}
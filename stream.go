@@ -0,0 +1,201 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	r "github.com/go-redis/redis"
+)
+
+const eventStreamPrefixKey = "events-sessions:"
+
+const (
+	// defaultStreamMaxLen the approximate length event streams are trimmed to
+	defaultStreamMaxLen = 10000
+	// streamBlock how long a read of the event stream waits for new events, failed events being
+	// handled again after each read
+	streamBlock = time.Second
+	// streamBatch the events read from the event stream at once
+	streamBatch = 100
+)
+
+// EventStream deliver the Created, Invalidated and Destroyed events of the sessions at least once, through
+// a redis stream read by a consumer group. Each event is added to the stream when it happens and acknowledged
+// once Handle returned nil, so events aren't lost when an instance crashes before handling them: they are
+// handled again by Consumer once restarted, or claimed by another consumer of Group after ClaimIdle.
+// Handle must thus tolerate seeing an event twice.
+type EventStream struct {
+	// Group the consumer group, every group getting all the events
+	Group string
+	// Consumer the name of the instance within Group, which must stay the same across restarts
+	Consumer string
+	// Handle called with every event, which is handled again later when it returns an error
+	Handle func(e Event) error
+	// MaxLen the approximate length the stream is trimmed to, 10000 when 0
+	MaxLen int64
+	// ClaimIdle how long the events of another consumer may stay unacknowledged before this one claims them,
+	// never when 0
+	ClaimIdle time.Duration
+}
+
+// getEventStreamKey return the key of the stream of lifecycle events
+func (p *provider) getEventStreamKey() string {
+	return eventStreamPrefixKey + p.keyPrefix
+}
+
+// eventStream the EventStream of a provider, with the key of its stream
+type eventStream struct {
+	EventStream
+	key string
+}
+
+// appendEvent add e to the event stream of WithEventStream when it is a lifecycle event
+func (p *provider) appendEvent(e Event) {
+	name, have := lifecycleEvents[e.Kind]
+	if p.eventStream == nil || !have {
+		return
+	}
+	maxLen := p.eventStream.MaxLen
+	if maxLen <= 0 {
+		maxLen = defaultStreamMaxLen
+	}
+	err := p.client.XAdd(&r.XAddArgs{
+		Stream:       p.eventStream.key,
+		MaxLenApprox: maxLen,
+		Values:       map[string]interface{}{"event": name, "sessionId": e.SessionId, "time": formatTime(e.Time)},
+	}).Err()
+	if err != nil {
+		p.report(err)
+	}
+}
+
+// streamEvent return the event of message, and whether it is one
+func streamEvent(message r.XMessage) (Event, bool) {
+	name, _ := message.Values["event"].(string)
+	id, _ := message.Values["sessionId"].(string)
+	millis, _ := message.Values["time"].(string)
+	for kind, kindName := range lifecycleEvents {
+		if kindName == name {
+			e := Event{Kind: kind, SessionId: id}
+			if ms, err := strconv.ParseInt(millis, 10, 64); err == nil {
+				e.Time = time.Unix(0, ms*int64(time.Millisecond))
+			}
+			return e, true
+		}
+	}
+	return Event{}, false
+}
+
+// consumeEvents handle the events of the stream of WithEventStream, for ever
+func (p *provider) consumeEvents() {
+	p.createGroup()
+	for {
+		// events read before but not acknowledged, after a crash or a failed Handle
+		p.readEvents("0", -1)
+		if p.eventStream.ClaimIdle > 0 {
+			p.claimEvents()
+		}
+		p.readEvents(">", streamBlock)
+	}
+}
+
+// createGroup create the consumer group of the stream, and the stream, unless done before
+func (p *provider) createGroup() {
+	es := p.eventStream
+	err := p.client.XGroupCreateMkStream(es.key, es.Group, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		p.report(err)
+	}
+}
+
+// readEvents read the events of the stream from id for the consumer and handle them
+func (p *provider) readEvents(id string, block time.Duration) {
+	es := p.eventStream
+	streams, err := p.client.XReadGroup(&r.XReadGroupArgs{
+		Group:    es.Group,
+		Consumer: es.Consumer,
+		Streams:  []string{es.key, id},
+		Count:    streamBatch,
+		Block:    block,
+	}).Result()
+	if err != nil {
+		switch {
+		case err == r.Nil:
+		case strings.HasPrefix(err.Error(), "NOGROUP"):
+			// the stream was deleted, e.g. by FLUSHDB
+			p.createGroup()
+		default:
+			p.report(err)
+			time.Sleep(streamBlock)
+		}
+		return
+	}
+	for _, stream := range streams {
+		p.handleEvents(stream.Messages)
+	}
+}
+
+// claimEvents take over the events other consumers left unacknowledged for longer than ClaimIdle, and handle them
+func (p *provider) claimEvents() {
+	es := p.eventStream
+	pending, err := p.client.XPendingExt(&r.XPendingExtArgs{
+		Stream: es.key,
+		Group:  es.Group,
+		Start:  "-",
+		End:    "+",
+		Count:  streamBatch,
+	}).Result()
+	if err != nil {
+		p.report(err)
+		return
+	}
+	ids := make([]string, 0, len(pending))
+	for _, entry := range pending {
+		if entry.Consumer != es.Consumer && entry.Idle >= es.ClaimIdle {
+			ids = append(ids, entry.Id)
+		}
+	}
+	if len(ids) == 0 {
+		return
+	}
+	messages, err := p.client.XClaim(&r.XClaimArgs{
+		Stream:   es.key,
+		Group:    es.Group,
+		Consumer: es.Consumer,
+		MinIdle:  es.ClaimIdle,
+		Messages: ids,
+	}).Result()
+	if err != nil {
+		p.report(err)
+		return
+	}
+	p.handleEvents(messages)
+}
+
+// handleEvents give messages to Handle, acknowledging those it handled
+func (p *provider) handleEvents(messages []r.XMessage) {
+	es := p.eventStream
+	for _, message := range messages {
+		if e, ok := streamEvent(message); ok {
+			if err := es.Handle(e); err != nil {
+				p.report(err)
+				continue
+			}
+		}
+		if err := p.client.XAck(es.key, es.Group, message.ID).Err(); err != nil {
+			p.report(err)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	s "github.com/go-the-way/anoweb/session"
+
+	"github.com/stretchr/testify/require"
+)
+
+// streamHandler record the events it handles, failing the first fail times
+type streamHandler struct {
+	mu      sync.Mutex
+	fail    int
+	handled []Event
+}
+
+func (h *streamHandler) handle(e Event) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.fail > 0 {
+		h.fail--
+		return errors.New("handler failed")
+	}
+	h.handled = append(h.handled, e)
+	return nil
+}
+
+func (h *streamHandler) events() []Event {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]Event(nil), h.handled...)
+}
+
+func TestProviderEventStream(t *testing.T) {
+	h := &streamHandler{fail: 1}
+	p := ProviderWithOptions(redisOptions, WithPrefixKey("_stream_:"),
+		WithEventStream(EventStream{Group: "audit", Consumer: "a", Handle: h.handle}))
+	currSession := p.New(&s.Config{Valid: time.Minute}, nil)
+	p.Del(currSession.Id())
+	require.Eventually(t, func() bool {
+		return len(h.events()) == 3
+	}, time.Second*5, time.Millisecond*10)
+	kinds := map[EventKind]bool{}
+	for _, e := range h.events() {
+		require.Equal(t, currSession.Id(), e.SessionId)
+		kinds[e.Kind] = true
+	}
+	require.Equal(t, map[EventKind]bool{EventCreated: true, EventInvalidated: true, EventDestroyed: true}, kinds)
+}
+
+func TestProviderEventStreamClaim(t *testing.T) {
+	crashed := &streamHandler{fail: 1 << 30}
+	p := ProviderWithOptions(redisOptions, WithPrefixKey("_stream_claim_:"),
+		WithEventStream(EventStream{Group: "audit", Consumer: "a", Handle: crashed.handle}))
+	currSession := p.New(&s.Config{Valid: time.Minute}, nil)
+	require.Eventually(t, func() bool {
+		pending, err := p.client.XPending(p.getEventStreamKey(), "audit").Result()
+		return err == nil && pending.Count == 1
+	}, time.Second*2, time.Millisecond*10)
+
+	h := &streamHandler{}
+	ProviderWithOptions(redisOptions, WithPrefixKey("_stream_claim_:"),
+		WithEventStream(EventStream{Group: "audit", Consumer: "b", Handle: h.handle, ClaimIdle: time.Millisecond}))
+	require.Eventually(t, func() bool {
+		return len(h.events()) == 1
+	}, time.Second*5, time.Millisecond*10)
+	require.Equal(t, currSession.Id(), h.events()[0].SessionId)
+	require.Equal(t, EventCreated, h.events()[0].Kind)
+	crashed.mu.Lock()
+	crashed.fail = 0
+	crashed.mu.Unlock()
+	p.Del(currSession.Id())
+}
@@ -0,0 +1,144 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+
+	r "github.com/go-redis/redis"
+
+	s "github.com/go-the-way/anoweb/session"
+)
+
+// tenantSeparator end the tenant part of the key prefix of a tenant view
+const tenantSeparator = ":"
+
+// ErrInvalidTenant returned by ForTenant for an empty tenant or one holding the separator
+var ErrInvalidTenant = errors.New("rsn: invalid tenant")
+
+// ErrSessionCapReached returned when creating a session while the cap set by WithMaxSessions is reached
+var ErrSessionCapReached = errors.New("rsn: too many sessions")
+
+// ForTenant return the view of p scoped to tenant, storing sessions under the key prefix of p
+// followed by tenant and a colon, e.g. session:acme:. Each tenant has its own sessions, indexes,
+// user sets and quota, while sharing the redis client and options of p.
+//
+// Passing WithDB, WithRedisOptions or WithClient routes the tenant to another database or server,
+// and WithValid gives it its own idle timeout, e.g. to keep "admin" sessions apart from "customer" ones.
+//
+// A view is created on the first call for a tenant and returned by later calls,
+// so opts such as WithMaxSessions only apply to that first call.
+func (p *provider) ForTenant(tenant string, opts ...Option) (*provider, error) {
+	if tenant == "" || strings.Contains(tenant, tenantSeparator) {
+		return nil, ErrInvalidTenant
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if view, have := p.tenants[tenant]; have {
+		return view, nil
+	}
+	view := *p
+	view.mu = &sync.Mutex{}
+	view.keyPrefix = p.keyPrefix + tenant + tenantSeparator
+	if p.keyFunc != nil {
+		view.keyFunc = func(id string) string {
+			return p.keyFunc(tenant + tenantSeparator + id)
+		}
+	}
+	view.sessions = map[string]s.Session{}
+	view.revokeWatchers = &revokeWatchers{watchers: map[string]map[uint64]func(){}}
+	view.tenants = nil
+	view.cleaning = false
+	view.client = nil
+	if p.cache != nil {
+		view.cache = newLocalCache(p.cache.max, p.cache.idle)
+	}
+	for _, opt := range opts {
+		opt(&view)
+	}
+	if view.client == nil {
+		view.client = p.client
+		if view.options != p.options {
+			view.client = r.NewClient(view.options)
+		}
+	}
+	if view.client != p.client {
+		view.readClient, view.scanClient, view.failover = nil, nil, nil
+		view.applyTimeouts()
+		view.instrument(view.client)
+	}
+	if view.client != p.client && view.replicas == p.replicas {
+		view.replicas = nil
+	}
+	view.syncSession()
+	if p.cleaning {
+		view.cleaning = true
+		view.subscribeInvalidation()
+	}
+	if p.tenants == nil {
+		p.tenants = map[string]*provider{}
+	}
+	p.tenants[tenant] = &view
+	return &view, nil
+}
+
+// tenantViews return the views created by ForTenant
+func (p *provider) tenantViews() []*provider {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	views := make([]*provider, 0, len(p.tenants))
+	for _, view := range p.tenants {
+		views = append(views, view)
+	}
+	return views
+}
+
+// keyMarker stands for the session id when splitting the keys built by a key func
+const keyMarker = "\x00"
+
+// ownId return the session id stored at key, or false when key belongs to a tenant view of p
+// or isn't a session key
+func (p *provider) ownId(key string) (string, bool) {
+	if p.keyFunc == nil {
+		id := strings.TrimPrefix(key, p.keyPrefix)
+		return id, !strings.Contains(id, tenantSeparator)
+	}
+	parts := strings.SplitN(p.keyFunc(keyMarker), keyMarker, 2)
+	if len(parts) != 2 || !strings.HasPrefix(key, parts[0]) || !strings.HasSuffix(key, parts[1]) ||
+		len(key) < len(parts[0])+len(parts[1]) {
+		return "", false
+	}
+	id := key[len(parts[0]) : len(key)-len(parts[1])]
+	return id, id != "" && !strings.Contains(id, tenantSeparator)
+}
+
+// Count return the number of live sessions, read from the expiry index
+func (p *provider) Count(ctx context.Context) (int64, error) {
+	count, err := p.client.WithContext(ctx).ZCount(p.getExpiryKey(), "("+formatTime(p.now()), "+inf").Result()
+	return count, wrapErr(err)
+}
+
+// sessionCapReached report whether the cap set by WithMaxSessions is reached, refusing new sessions
+func (p *provider) sessionCapReached() bool {
+	if p.maxSessions <= 0 {
+		return false
+	}
+	count, err := p.Count(context.Background())
+	if err != nil {
+		p.report(err)
+		return false
+	}
+	return count >= int64(p.maxSessions)
+}
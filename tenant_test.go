@@ -0,0 +1,98 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	rds "github.com/go-redis/redis"
+
+	s "github.com/go-the-way/anoweb/session"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProviderForTenant(t *testing.T) {
+	p := ProviderWithPrefixKey(redisOptions, "_tenant_:")
+	_, err := p.ForTenant("")
+	require.Equal(t, ErrInvalidTenant, err)
+	_, err = p.ForTenant("a:b")
+	require.Equal(t, ErrInvalidTenant, err)
+
+	acme, err := p.ForTenant("acme")
+	require.Nil(t, err)
+	again, err := p.ForTenant("acme")
+	require.Nil(t, err)
+	require.True(t, acme == again)
+
+	currSession := acme.New(&s.Config{Valid: time.Minute}, nil)
+	require.Equal(t, int64(1), p.client.Exists("_tenant_:acme:"+currSession.Id()).Val())
+	require.False(t, p.Exists(currSession.Id()))
+	require.True(t, acme.Exists(currSession.Id()))
+
+	ids, _, err := p.List(context.Background(), 0, 100)
+	require.Nil(t, err)
+	require.NotContains(t, ids, currSession.Id())
+	ids, _, err = acme.List(context.Background(), 0, 100)
+	require.Nil(t, err)
+	require.Contains(t, ids, currSession.Id())
+
+	count, err := acme.Count(context.Background())
+	require.Nil(t, err)
+	require.Equal(t, int64(1), count)
+	acme.Clear()
+	count, err = acme.Count(context.Background())
+	require.Nil(t, err)
+	require.Equal(t, int64(0), count)
+}
+
+func TestProviderMaxSessions(t *testing.T) {
+	p := ProviderWithPrefixKey(redisOptions, "_tenant_:")
+	quota, err := p.ForTenant("quota", WithMaxSessions(2))
+	require.Nil(t, err)
+	first := quota.New(&s.Config{Valid: time.Minute}, nil)
+	second := quota.New(&s.Config{Valid: time.Minute * 2}, nil)
+	require.Nil(t, quota.New(&s.Config{Valid: time.Minute * 3}, nil))
+	_, err = quota.newWithRequest(httptest.NewRequest(http.MethodGet, "/", nil), &s.Config{Valid: time.Minute}, nil, false)
+	require.Equal(t, ErrSessionCapReached, err)
+	require.True(t, quota.Exists(first.Id()))
+	require.True(t, quota.Exists(second.Id()))
+	count, err := quota.Count(context.Background())
+	require.Nil(t, err)
+	require.Equal(t, int64(2), count)
+	quota.Clear()
+}
+
+func TestProviderTenantRouting(t *testing.T) {
+	p := ProviderWithPrefixKey(redisOptions, "_tenant_:")
+	admin, err := p.ForTenant("admin", WithDB(1), WithValid(time.Minute*5))
+	require.Nil(t, err)
+	require.Equal(t, 1, admin.client.Options().DB)
+	require.Equal(t, 0, p.client.Options().DB)
+
+	currSession := admin.New(&s.Config{Valid: time.Minute}, nil)
+	require.Equal(t, int64(0), p.client.Exists("_tenant_:admin:"+currSession.Id()).Val())
+	require.Equal(t, int64(1), admin.client.Exists("_tenant_:admin:"+currSession.Id()).Val())
+	ttl, err := currSession.(Session).TTL()
+	require.Nil(t, err)
+	require.True(t, ttl > time.Minute*4)
+	admin.Clear()
+
+	client := rds.NewClient(redisOptions)
+	customer, err := p.ForTenant("customer", WithClient(client))
+	require.Nil(t, err)
+	require.True(t, customer.client == client)
+}
@@ -0,0 +1,72 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"time"
+
+	r "github.com/go-redis/redis"
+)
+
+// Timeouts bound the commands of each operation class, zero keeping the timeouts of the redis options
+type Timeouts struct {
+	// Read bound the reads of session values and existence checks
+	Read time.Duration
+	// Write bound the commands changing sessions
+	Write time.Duration
+	// Scan bound each SCAN of the admin walks such as List, DelByPattern or OnlineUsers
+	Scan time.Duration
+}
+
+// applyTimeouts give each operation class of WithTimeouts a client of its own bounded by its timeout.
+// The redis client doesn't bound commands by their context, only by socket deadlines, so the classes
+// can't share a connection pool.
+func (p *provider) applyTimeouts() {
+	if p.timeouts == nil {
+		return
+	}
+	if p.timeouts.Write > 0 {
+		p.client = newTimedClient(p.client, p.timeouts.Write)
+	}
+	if p.timeouts.Read > 0 {
+		p.readClient = newTimedClient(p.client, p.timeouts.Read)
+		p.instrument(p.readClient)
+	}
+	if p.timeouts.Scan > 0 {
+		p.scanClient = newTimedClient(p.client, p.timeouts.Scan)
+		p.instrument(p.scanClient)
+	}
+}
+
+// newTimedClient return a client to the server of client whose commands time out after timeout
+func newTimedClient(client *r.Client, timeout time.Duration) *r.Client {
+	options := *client.Options()
+	options.ReadTimeout = timeout
+	options.WriteTimeout = timeout
+	return r.NewClient(&options)
+}
+
+// reads return the client session values are read through
+func (p *provider) reads() *r.Client {
+	if p.readClient != nil {
+		return p.readClient
+	}
+	return p.client
+}
+
+// scans return the client the admin walks scan keys through
+func (p *provider) scans() *r.Client {
+	if p.scanClient != nil {
+		return p.scanClient
+	}
+	return p.client
+}
@@ -0,0 +1,43 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	s "github.com/go-the-way/anoweb/session"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProviderTimeouts(t *testing.T) {
+	p := ProviderWithOptions(redisOptions, WithPrefixKey("_timeouts_:"),
+		WithTimeouts(Timeouts{Read: time.Millisecond * 200, Write: time.Millisecond * 300, Scan: time.Second * 5}))
+	require.Equal(t, time.Millisecond*200, p.reads().Options().ReadTimeout)
+	require.Equal(t, time.Millisecond*300, p.client.Options().WriteTimeout)
+	require.Equal(t, time.Second*5, p.scans().Options().ReadTimeout)
+	currSession := p.New(&s.Config{Valid: time.Minute}, nil)
+	currSession.Set("apple", "100")
+	require.Equal(t, "100", currSession.Get("apple"))
+	require.True(t, p.Exists(currSession.Id()))
+	ids, _, err := p.List(context.Background(), 0, 100)
+	require.NoError(t, err)
+	require.Contains(t, ids, currSession.Id())
+	p.Del(currSession.Id())
+
+	// unset classes keep the client of the redis options
+	p = ProviderWithOptions(redisOptions, WithPrefixKey("_timeouts_:"), WithTimeouts(Timeouts{Read: time.Second}))
+	require.Equal(t, p.client, p.scans())
+	require.NotEqual(t, p.client, p.reads())
+}
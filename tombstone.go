@@ -0,0 +1,102 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"time"
+
+	r "github.com/go-redis/redis"
+)
+
+const tombstonePrefixKey = "tombstone-sessions:"
+
+// buryScript move a session hash to its tombstone key expiring after the soft delete window
+var buryScript = newScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return 0
+end
+redis.call('RENAME', KEYS[1], KEYS[2])
+redis.call('PEXPIRE', KEYS[2], ARGV[1])
+return 1
+`)
+
+// restoreScript move a tombstone back to the session key with its lifetime as ttl,
+// falling back to ARGV[2] for sessions stored without one
+var restoreScript = newScript(`
+if redis.call('EXISTS', KEYS[2]) == 0 or redis.call('EXISTS', KEYS[1]) == 1 then
+	return 0
+end
+redis.call('RENAME', KEYS[2], KEYS[1])
+local lifeTime = tonumber(redis.call('HGET', KEYS[1], ARGV[1])) or tonumber(ARGV[2])
+redis.call('PEXPIRE', KEYS[1], lifeTime)
+return lifeTime
+`)
+
+// getTombstoneKey return the key a soft deleted session id is kept under
+func (p *provider) getTombstoneKey(id string) string {
+	return tombstonePrefixKey + p.keyPrefix + id
+}
+
+// deleteKey delete the hash and document of session id, or bury the hash when soft delete
+// is enabled, leaving the document to expire, reporting whether it existed
+func (p *provider) deleteKey(id string) (bool, error) {
+	var deleted int64
+	var err error
+	if p.softDeleteWindow <= 0 {
+		deleted, err = p.client.Del(p.getRedisKey(id)).Result()
+		if err == nil {
+			err = p.client.Del(p.getDocumentKey(id)).Err()
+		}
+	} else {
+		window := int64(p.softDeleteWindow / time.Millisecond)
+		deleted, err = buryScript.Run(p.client, []string{p.getRedisKey(id), p.getTombstoneKey(id)}, window).Int64()
+	}
+	return deleted > 0, err
+}
+
+// Restore bring back a session deleted within the soft delete window, with its indexes and full lifetime.
+// ErrSessionNotFound is returned when no tombstone of id exists.
+func (p *provider) Restore(id string) error {
+	keys := []string{p.getRedisKey(id), p.getTombstoneKey(id)}
+	lifeTime, err := restoreScript.Run(p.client, keys, p.field(lifeTimeName), int64(p.softDeleteWindow/time.Millisecond)).Int64()
+	if err != nil {
+		return wrapErr(err)
+	}
+	if lifeTime == 0 {
+		return ErrSessionNotFound
+	}
+	p.indexExpiry(id, time.Duration(lifeTime)*time.Millisecond)
+	values, err := p.client.HGetAll(p.getRedisKey(id)).Result()
+	if err != nil {
+		return wrapErr(err)
+	}
+	_, err = p.client.Pipelined(func(pipe r.Pipeliner) error {
+		for field := range p.indexes {
+			if value, have := values[field]; have {
+				pipe.SAdd(p.getIndexKey(field, value), id)
+			}
+		}
+		if userId, have := values[p.field(userIdName)]; have {
+			pipe.SAdd(p.getUserKey(userId), id)
+		}
+		return nil
+	})
+	if err != nil {
+		return wrapErr(err)
+	}
+	currentSession := newSession(p, id)
+	currentSession.userId = values[p.field(userIdName)]
+	p.mu.Lock()
+	p.store(id, currentSession)
+	p.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,48 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	s "github.com/go-the-way/anoweb/session"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProviderRestore(t *testing.T) {
+	p := ProviderWithOptions(redisOptions, WithPrefixKey("_tombstone_:"), WithSoftDelete(time.Minute), WithIndex("role"))
+	currSession := p.New(&s.Config{Valid: time.Minute}, nil).(Session)
+	currSession.Set("role", "admin")
+	require.Nil(t, currSession.BindUser("51"))
+	p.Del(currSession.Id())
+	require.False(t, p.Exists(currSession.Id()))
+	require.Equal(t, int64(1), p.client.Exists(p.getTombstoneKey(currSession.Id())).Val())
+
+	require.Nil(t, p.Restore(currSession.Id()))
+	require.True(t, p.Exists(currSession.Id()))
+	require.Equal(t, "admin", currSession.Get("role"))
+	ttl, err := currSession.TTL()
+	require.Nil(t, err)
+	require.True(t, ttl > 50*time.Second)
+	found, err := p.Find(context.Background(), "role", "admin")
+	require.Nil(t, err)
+	require.Equal(t, 1, len(found))
+	infos, err := p.SessionsByUser(context.Background(), "51")
+	require.Nil(t, err)
+	require.Equal(t, 1, len(infos))
+
+	require.Equal(t, ErrSessionNotFound, p.Restore(currSession.Id()))
+	p.Del(currSession.Id())
+}
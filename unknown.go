@@ -0,0 +1,47 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"errors"
+	"net/http"
+)
+
+// UnknownIdPolicy decide what GetOrCreate, NewFromRequest and the middlewares do with a request
+// presenting the id of no live session, e.g. an expired or forged one
+type UnknownIdPolicy int
+
+const (
+	// CreateOnUnknown create a fresh session
+	CreateOnUnknown UnknownIdPolicy = iota
+	// RejectUnknown create no session and return ErrUnknownSession, the middlewares answering 401
+	RejectUnknown
+	// FlagUnknown fire the UnknownId listener and create a fresh session
+	FlagUnknown
+)
+
+// ErrUnknownSession returned under RejectUnknown when the request presents the id of no live session
+var ErrUnknownSession = errors.New("rsn: unknown session id")
+
+// unknownId apply the policy of WithUnknownIdPolicy to r presenting id, returning ErrUnknownSession
+// when no session may be created
+func (p *provider) unknownId(r *http.Request, id string) error {
+	switch p.unknownIdPolicy {
+	case RejectUnknown:
+		return ErrUnknownSession
+	case FlagUnknown:
+		if p.listener != nil && p.listener.UnknownId != nil {
+			p.listener.UnknownId(r, id)
+		}
+	}
+	return nil
+}
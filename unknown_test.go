@@ -0,0 +1,71 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	s "github.com/go-the-way/anoweb/session"
+
+	"github.com/stretchr/testify/require"
+)
+
+// unknownRequest return a request presenting the id of a session of p deleted meanwhile
+func unknownRequest(p *provider) (*http.Request, string) {
+	config := &s.Config{Valid: time.Minute}
+	gone := p.New(config, nil)
+	p.Del(gone.Id())
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(&http.Cookie{Name: p.CookieName(), Value: gone.Id()})
+	return req, gone.Id()
+}
+
+func TestProviderUnknownIdPolicy(t *testing.T) {
+	config := &s.Config{Valid: time.Minute}
+	{
+		p := ProviderWithPrefixKey(redisOptions, "_unknown_:")
+		req, id := unknownRequest(p)
+		currSession, err := p.GetOrCreate(req, config, nil)
+		require.Nil(t, err)
+		require.NotEqual(t, id, currSession.Id())
+		p.Del(currSession.Id())
+	}
+	{
+		p := ProviderWithOptions(redisOptions, WithPrefixKey("_unknown_:"), WithUnknownIdPolicy(RejectUnknown))
+		req, _ := unknownRequest(p)
+		currSession, err := p.GetOrCreate(req, config, nil)
+		require.Equal(t, ErrUnknownSession, err)
+		require.Nil(t, currSession)
+		_, _, err = p.NewFromRequest(req, config, nil)
+		require.Equal(t, ErrUnknownSession, err)
+
+		rec := httptest.NewRecorder()
+		Middleware(p, config)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			t.Fatal("handler reached")
+		})).ServeHTTP(rec, req)
+		require.Equal(t, http.StatusUnauthorized, rec.Code)
+	}
+	{
+		flagged := ""
+		p := ProviderWithOptions(redisOptions, WithPrefixKey("_unknown_:"), WithUnknownIdPolicy(FlagUnknown),
+			WithListener(&Listener{UnknownId: func(r *http.Request, id string) { flagged = id }}))
+		req, id := unknownRequest(p)
+		currSession, err := p.GetOrCreate(req, config, nil)
+		require.Nil(t, err)
+		require.NotEqual(t, id, currSession.Id())
+		require.Equal(t, id, flagged)
+		p.Del(currSession.Id())
+	}
+}
@@ -0,0 +1,237 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	r "github.com/go-redis/redis"
+)
+
+const userPrefixKey = "user-sessions:"
+
+// SessionInfo describe a live session of a user
+type SessionInfo struct {
+	Id             string
+	CreatedAt      time.Time
+	LastAccessedAt time.Time
+	IP             string
+	UserAgent      string
+	Device         string
+}
+
+// getUserKey return the key of the set holding the session ids of userId
+func (p *provider) getUserKey(userId string) string {
+	return p.getUserKeyPrefix() + userId
+}
+
+func (p *provider) getUserKeyPrefix() string {
+	return userPrefixKey + p.keyPrefix
+}
+
+// BindUser bind session to userId, moving it out of the set of any previously bound user.
+//
+// Binding is the point where a session becomes a login of userId, so the per-user cap
+// set by WithMaxSessionsPerUser is enforced here.
+func (s *session) BindUser(userId string) error {
+	if err := s.provider.enforceUserCap(s.id, userId); err != nil {
+		return wrapErr(err)
+	}
+	err := s.provider.criticalWrite(s.client, func(pipe r.Pipeliner) {
+		indexSetScript.Eval(pipe, []string{s.key}, s.provider.field(userIdName), userId, s.provider.getUserKeyPrefix(), s.id)
+	})
+	if err == nil || err == ErrNotReplicated {
+		s.userId = userId
+	}
+	return err
+}
+
+// UserId return the user id bound to session, or empty if none
+func (s *session) UserId() string {
+	val, err := s.client.HGet(s.key, s.provider.field(userIdName)).Result()
+	if err != nil && err != r.Nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+	}
+	return val
+}
+
+// unbindUser remove session id from the set of its bound user
+func (p *provider) unbindUser(id string) {
+	userId, err := p.client.HGet(p.getRedisKey(id), p.field(userIdName)).Result()
+	if err == r.Nil {
+		return
+	}
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	if err = p.client.SRem(p.getUserKey(userId), id).Err(); err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+	}
+}
+
+// userSessionIds return the ids of live sessions bound to userId.
+//
+// Ids of sessions which expired or were rebound meanwhile are dropped from the set.
+func (p *provider) userSessionIds(ctx context.Context, userId string) ([]string, error) {
+	client := p.client.WithContext(ctx)
+	userKey := p.getUserKey(userId)
+	ids, err := client.SMembers(userKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	live := make([]string, 0, len(ids))
+	for _, id := range ids {
+		val, err := client.HGet(p.getRedisKey(id), p.field(userIdName)).Result()
+		if err == r.Nil || (err == nil && val != userId) {
+			client.SRem(userKey, id)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		live = append(live, id)
+	}
+	return live, nil
+}
+
+// unbindExpired remove an already expired session from the set of its bound user
+func (p *provider) unbindExpired(currentSession *session) {
+	if currentSession.userId == "" {
+		return
+	}
+	if err := p.client.SRem(p.getUserKey(currentSession.userId), currentSession.id).Err(); err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+	}
+}
+
+// SessionsByUser return the live sessions bound to userId
+func (p *provider) SessionsByUser(ctx context.Context, userId string) ([]SessionInfo, error) {
+	ids, err := p.userSessionIds(ctx, userId)
+	if err != nil {
+		return nil, wrapErr(err)
+	}
+	cmds := make([]*r.SliceCmd, len(ids))
+	_, err = p.client.WithContext(ctx).Pipelined(func(pipe r.Pipeliner) error {
+		for i, id := range ids {
+			cmds[i] = pipe.HMGet(p.getRedisKey(id), p.field(createdAtName), p.field(accessedAtName), p.field(ipName), p.field(userAgentName), p.field(deviceName))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, wrapErr(err)
+	}
+	infos := make([]SessionInfo, 0, len(ids))
+	for i, id := range ids {
+		vals := cmds[i].Val()
+		if vals[0] == nil {
+			// expired after the index was read
+			continue
+		}
+		infos = append(infos, SessionInfo{
+			Id:             id,
+			CreatedAt:      parseTime(stringOf(vals[0])),
+			LastAccessedAt: parseTime(stringOf(vals[1])),
+			IP:             stringOf(vals[2]),
+			UserAgent:      stringOf(vals[3]),
+			Device:         stringOf(vals[4]),
+		})
+	}
+	return infos, nil
+}
+
+// stringOf return val as string, or empty if it is a missing field
+func stringOf(val interface{}) string {
+	str, _ := val.(string)
+	return str
+}
+
+// InvalidateUser delete every session bound to userId and announce it to peer instances
+func (p *provider) InvalidateUser(ctx context.Context, userId string) error {
+	ids, err := p.userSessionIds(ctx, userId)
+	if err != nil {
+		return wrapErr(err)
+	}
+	for _, id := range ids {
+		if err = ctx.Err(); err != nil {
+			return wrapErr(err)
+		}
+		p.destroy(id)
+	}
+	return nil
+}
+
+// destroy invalidate and delete session id, announcing it to peer instances
+func (p *provider) destroy(id string) {
+	p.mu.Lock()
+	if currentSession, have := p.sessions[id]; have {
+		markInvalidated(currentSession)
+	}
+	if _, err := p.del(id, false); err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+	}
+	p.mu.Unlock()
+	p.publishInvalidation(id)
+}
+
+// EvictionPolicy decide what happens when binding a session would exceed the per-user cap
+type EvictionPolicy int
+
+const (
+	// RejectNew refuse to bind the new session
+	RejectNew EvictionPolicy = iota
+	// EvictOldest delete the session of the user created first
+	EvictOldest
+	// EvictLeastRecentlyUsed delete the session of the user accessed least recently
+	EvictLeastRecentlyUsed
+)
+
+// ErrTooManySessions returned by BindUser when the user reached the session cap under RejectNew
+var ErrTooManySessions = errors.New("rsn: too many sessions for user")
+
+// enforceUserCap make room for one more session of userId besides id
+func (p *provider) enforceUserCap(id, userId string) error {
+	if p.maxUserSessions <= 0 {
+		return nil
+	}
+	infos, err := p.SessionsByUser(context.Background(), userId)
+	if err != nil {
+		return err
+	}
+	others := infos[:0]
+	for _, info := range infos {
+		if info.Id != id {
+			others = append(others, info)
+		}
+	}
+	excess := len(others) - p.maxUserSessions + 1
+	if excess <= 0 {
+		return nil
+	}
+	switch p.evictionPolicy {
+	case EvictOldest:
+		sort.Slice(others, func(i, j int) bool { return others[i].CreatedAt.Before(others[j].CreatedAt) })
+	case EvictLeastRecentlyUsed:
+		sort.Slice(others, func(i, j int) bool { return others[i].LastAccessedAt.Before(others[j].LastAccessedAt) })
+	default:
+		return ErrTooManySessions
+	}
+	for _, info := range others[:excess] {
+		p.destroy(info.Id)
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	s "github.com/go-the-way/anoweb/session"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionBindUser(t *testing.T) {
+	p := ProviderWithPrefixKey(redisOptions, "_user_:")
+	p.Clear()
+	config := &s.Config{Valid: time.Minute}
+	first := p.New(config, nil).(Session)
+	second := p.New(config, nil).(Session)
+	require.Nil(t, first.BindUser("42"))
+	require.Nil(t, second.BindUser("42"))
+	require.Equal(t, "42", first.UserId())
+
+	ids, err := p.userSessionIds(context.Background(), "42")
+	require.Nil(t, err)
+	require.ElementsMatch(t, []string{first.Id(), second.Id()}, ids)
+
+	second.Set(p.field(userIdName), "43")
+	second.Clear()
+	require.Equal(t, "42", second.UserId())
+
+	require.Nil(t, second.BindUser("43"))
+	p.Del(first.Id())
+	ids, err = p.userSessionIds(context.Background(), "42")
+	require.Nil(t, err)
+	require.Equal(t, 0, len(ids))
+
+	ids, err = p.userSessionIds(context.Background(), "43")
+	require.Nil(t, err)
+	require.Equal(t, []string{second.Id()}, ids)
+}
+
+func TestProviderSessionsByUser(t *testing.T) {
+	p := ProviderWithPrefixKey(redisOptions, "_user_:")
+	p.Clear()
+	config := &s.Config{Valid: time.Minute}
+	before := time.Now().Add(-time.Second)
+	currSession := p.New(config, nil).(Session)
+	require.Nil(t, currSession.BindUser("44"))
+	_ = p.New(config, nil)
+
+	infos, err := p.SessionsByUser(context.Background(), "44")
+	require.Nil(t, err)
+	require.Equal(t, 1, len(infos))
+	require.Equal(t, currSession.Id(), infos[0].Id)
+	require.True(t, infos[0].CreatedAt.After(before))
+	require.False(t, infos[0].LastAccessedAt.Before(infos[0].CreatedAt))
+
+	infos, err = p.SessionsByUser(context.Background(), "45")
+	require.Nil(t, err)
+	require.Equal(t, 0, len(infos))
+}
+
+func TestProviderInvalidateUser(t *testing.T) {
+	p := ProviderWithPrefixKey(redisOptions, "_user_:")
+	peer := ProviderWithPrefixKey(redisOptions, "_user_:")
+	destroyedCh := make(chan string, 2)
+	peer.Clean(nil, &s.Listener{Destroyed: func(session s.Session) {
+		destroyedCh <- session.Id()
+	}})
+	time.Sleep(time.Millisecond * 100)
+
+	config := &s.Config{Valid: time.Minute}
+	currSession := p.New(config, nil).(Session)
+	require.Nil(t, currSession.BindUser("46"))
+	other := p.New(config, nil)
+	peer.syncSession()
+
+	require.Nil(t, p.InvalidateUser(context.Background(), "46"))
+	require.True(t, currSession.Invalidated())
+	require.False(t, p.Exists(currSession.Id()))
+	require.True(t, p.Exists(other.Id()))
+
+	select {
+	case id := <-destroyedCh:
+		require.Equal(t, currSession.Id(), id)
+	case <-time.After(time.Second):
+		t.Error("peer session not destroyed")
+	}
+	require.False(t, peer.Exists(currSession.Id()))
+}
+
+func TestProviderMaxSessionsPerUser(t *testing.T) {
+	config := &s.Config{Valid: time.Minute}
+	{
+		p := ProviderWithOptions(redisOptions, WithPrefixKey("_user_cap_:"), WithMaxSessionsPerUser(1, RejectNew))
+		require.Nil(t, p.New(config, nil).(Session).BindUser("47"))
+		require.Equal(t, ErrTooManySessions, p.New(config, nil).(Session).BindUser("47"))
+		require.Nil(t, p.InvalidateUser(context.Background(), "47"))
+	}
+	{
+		p := ProviderWithOptions(redisOptions, WithPrefixKey("_user_cap_:"), WithMaxSessionsPerUser(2, EvictOldest))
+		oldest := p.New(config, nil).(Session)
+		require.Nil(t, oldest.BindUser("48"))
+		time.Sleep(time.Millisecond * 5)
+		newer := p.New(config, nil).(Session)
+		require.Nil(t, newer.BindUser("48"))
+		require.Nil(t, p.New(config, nil).(Session).BindUser("48"))
+		require.False(t, p.Exists(oldest.Id()))
+		require.True(t, p.Exists(newer.Id()))
+		require.Nil(t, p.InvalidateUser(context.Background(), "48"))
+	}
+	{
+		p := ProviderWithOptions(redisOptions, WithPrefixKey("_user_cap_:"), WithMaxSessionsPerUser(2, EvictLeastRecentlyUsed))
+		oldest := p.New(config, nil).(Session)
+		require.Nil(t, oldest.BindUser("49"))
+		time.Sleep(time.Millisecond * 5)
+		idle := p.New(config, nil).(Session)
+		require.Nil(t, idle.BindUser("49"))
+		time.Sleep(time.Millisecond * 5)
+		p.Refresh(oldest, config, nil)
+		require.Nil(t, p.New(config, nil).(Session).BindUser("49"))
+		require.True(t, p.Exists(oldest.Id()))
+		require.False(t, p.Exists(idle.Id()))
+		require.Nil(t, p.InvalidateUser(context.Background(), "49"))
+	}
+}
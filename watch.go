@@ -0,0 +1,124 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// ErrChangeFeedDisabled returned by Watch when changes aren't published, see WithChangeFeed
+var ErrChangeFeedDisabled = errors.New("rsn: change feed is not enabled")
+
+const changeChannelPrefix = "rsn-change:"
+
+// ChangeKind is the kind of a ChangeEvent
+type ChangeKind string
+
+const (
+	// ChangeSet values were set
+	ChangeSet ChangeKind = "set"
+	// ChangeDel values were deleted
+	ChangeDel ChangeKind = "del"
+	// ChangeDestroyed the session ended, no event follows
+	ChangeDestroyed ChangeKind = "destroyed"
+)
+
+// ChangeEvent describe a change of a watched session
+type ChangeEvent struct {
+	Kind  ChangeKind `json:"kind"`
+	Names []string   `json:"names,omitempty"`
+	Time  time.Time  `json:"time"`
+}
+
+// getChangeChannel return the channel the changes of session id are published on
+func (p *provider) getChangeChannel(id string) string {
+	return changeChannelPrefix + p.keyPrefix + id
+}
+
+// publishChange publish the change of names of session id when WithChangeFeed is set
+func (p *provider) publishChange(id string, kind ChangeKind, names []string) {
+	if !p.changeFeed || len(names) == 0 {
+		return
+	}
+	b, err := json.Marshal(ChangeEvent{Kind: kind, Names: names, Time: p.now()})
+	if err != nil {
+		p.report(err)
+		return
+	}
+	if err = p.client.Publish(p.getChangeChannel(id), b).Err(); err != nil {
+		p.report(err)
+	}
+}
+
+// Watch return the changes made to session id by any instance from now on, so long-lived connections
+// such as SSE streams or websockets can react to them. The channel is closed once ctx is done or the
+// session ends, after a ChangeDestroyed event; ends by expiry are seen while Clean is running.
+// Changes are published by instances with WithChangeFeed, otherwise ErrChangeFeedDisabled is returned.
+func (p *provider) Watch(ctx context.Context, id string) (<-chan ChangeEvent, error) {
+	if !p.changeFeed {
+		return nil, ErrChangeFeedDisabled
+	}
+	pubSub := p.client.Subscribe(p.getChangeChannel(id), p.getInvalidationChannel())
+	if _, err := pubSub.Receive(); err != nil {
+		_ = pubSub.Close()
+		return nil, wrapErr(err)
+	}
+	revoked := make(chan struct{})
+	cancel := p.OnRevoke(id, func() { close(revoked) })
+	events := make(chan ChangeEvent, 16)
+	go func() {
+		defer close(events)
+		defer cancel()
+		defer func() { _ = pubSub.Close() }()
+		send := func(event ChangeEvent) bool {
+			select {
+			case events <- event:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		messages := pubSub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-revoked:
+				send(ChangeEvent{Kind: ChangeDestroyed, Time: p.now()})
+				return
+			case msg, ok := <-messages:
+				if !ok {
+					return
+				}
+				if msg.Channel == p.getInvalidationChannel() {
+					if msg.Payload == id {
+						send(ChangeEvent{Kind: ChangeDestroyed, Time: p.now()})
+						return
+					}
+					continue
+				}
+				event := ChangeEvent{}
+				if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+					p.report(err)
+					continue
+				}
+				if !send(event) {
+					return
+				}
+			}
+		}
+	}()
+	return events, nil
+}
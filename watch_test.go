@@ -0,0 +1,56 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	s "github.com/go-the-way/anoweb/session"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProviderWatch(t *testing.T) {
+	_, err := Provider(redisOptions).Watch(context.Background(), "any")
+	require.Equal(t, ErrChangeFeedDisabled, err)
+
+	p := ProviderWithOptions(redisOptions, WithPrefixKey("_watch_:"), WithChangeFeed())
+	peer := ProviderWithOptions(redisOptions, WithPrefixKey("_watch_:"), WithChangeFeed())
+	currSession := p.New(&s.Config{Valid: time.Minute}, nil)
+	events, err := p.Watch(context.Background(), currSession.Id())
+	require.NoError(t, err)
+
+	peerSession := peer.Get(currSession.Id())
+	peerSession.Set("apple", "100")
+	event := <-events
+	require.Equal(t, ChangeSet, event.Kind)
+	require.Equal(t, []string{"apple"}, event.Names)
+	peerSession.Clear()
+	event = <-events
+	require.Equal(t, ChangeDel, event.Kind)
+	require.Equal(t, []string{"apple"}, event.Names)
+	peer.Del(currSession.Id())
+	require.Equal(t, ChangeDestroyed, (<-events).Kind)
+	_, open := <-events
+	require.False(t, open)
+
+	other := p.New(&s.Config{Valid: time.Minute}, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	events, err = p.Watch(ctx, other.Id())
+	require.NoError(t, err)
+	cancel()
+	_, open = <-events
+	require.False(t, open)
+	p.Del(other.Id())
+}
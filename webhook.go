@@ -0,0 +1,99 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	// WebhookSignatureHeader the header holding the hex HMAC-SHA256 of the body, keyed by the secret of the webhook
+	WebhookSignatureHeader = "X-Rsn-Signature"
+	// WebhookEventHeader the header holding the kind of the event posted
+	WebhookEventHeader = "X-Rsn-Event"
+	// defaultWebhookTimeout bound each post of a Webhook without Client, so a hung endpoint can't stall the publisher
+	defaultWebhookTimeout = 10 * time.Second
+)
+
+// webhookClient the client of the Webhooks without Client
+var webhookClient = &http.Client{Timeout: defaultWebhookTimeout}
+
+// Webhook post the Created, Invalidated and Destroyed events of a provider to URL, so external systems
+// such as a SIEM can follow the sessions without access to redis. Each event is posted alone as a JSON
+// EventPayload, signed in WebhookSignatureHeader when Secret is set, and retried on failure.
+type Webhook struct {
+	URL string
+	// Secret the HMAC-SHA256 key signing the body, nothing is signed when empty
+	Secret []byte
+	// Retries the posts retried after the first failed, waiting Backoff, doubled after each retry
+	Retries int
+	Backoff time.Duration
+	// Client the http client posting the events, one timing each post out after 10 seconds when nil.
+	// A Client without Timeout lets a hung endpoint hold the events queued behind it until they are dropped.
+	Client *http.Client
+}
+
+// WebhookSignature return the value of WebhookSignatureHeader for body signed with secret,
+// for receivers to check the events they are posted
+func WebhookSignature(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	_, _ = mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Publish post e, retrying as set, and return the last error once all attempts failed
+func (w *Webhook) Publish(e Event) error {
+	body, err := MarshalEvent(e)
+	if err != nil {
+		return err
+	}
+	backoff := w.Backoff
+	for attempt := 0; ; attempt++ {
+		if err = w.post(lifecycleEvents[e.Kind], body); err == nil || attempt >= w.Retries {
+			return err
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// post body once
+func (w *Webhook) post(kind string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookEventHeader, kind)
+	if len(w.Secret) > 0 {
+		req.Header.Set(WebhookSignatureHeader, WebhookSignature(w.Secret, body))
+	}
+	client := w.Client
+	if client == nil {
+		client = webhookClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("rsn: webhook %s answered %s", w.URL, resp.Status)
+	}
+	return nil
+}
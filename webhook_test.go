@@ -0,0 +1,90 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	s "github.com/go-the-way/anoweb/session"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProviderWebhook(t *testing.T) {
+	secret := []byte("secret")
+	var (
+		mu       sync.Mutex
+		attempts int
+		payloads []EventPayload
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.Header.Get(WebhookSignatureHeader) != WebhookSignature(secret, body) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var payload EventPayload
+		_ = json.Unmarshal(body, &payload)
+		require.Equal(t, payload.Event, r.Header.Get(WebhookEventHeader))
+		payloads = append(payloads, payload)
+	}))
+	defer server.Close()
+
+	p := ProviderWithOptions(redisOptions, WithPrefixKey("_webhook_:"),
+		WithWebhook(Webhook{URL: server.URL, Secret: secret, Retries: 2, Backoff: time.Millisecond}))
+	currSession := p.New(&s.Config{Valid: time.Minute}, nil)
+	p.Del(currSession.Id())
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(payloads) == 3
+	}, time.Second, time.Millisecond*10)
+	mu.Lock()
+	defer mu.Unlock()
+	require.Equal(t, 4, attempts)
+	for i, event := range []string{"created", "invalidated", "destroyed"} {
+		require.Equal(t, event, payloads[i].Event)
+		require.Equal(t, currSession.Id(), payloads[i].SessionId)
+	}
+}
+
+func TestWebhookTimeout(t *testing.T) {
+	require.Equal(t, defaultWebhookTimeout, webhookClient.Timeout)
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+	defaultClient := webhookClient
+	webhookClient = &http.Client{Timeout: 50 * time.Millisecond}
+	defer func() {
+		webhookClient = defaultClient
+	}()
+
+	started := time.Now()
+	require.Error(t, (&Webhook{URL: server.URL}).Publish(Event{Kind: EventCreated, SessionId: "id"}))
+	require.True(t, time.Since(started) < time.Second)
+}
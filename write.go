@@ -0,0 +1,349 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	// ErrReservedField returned when writing an internal field of the session
+	ErrReservedField = errors.New("rsn: reserved session field")
+	// ErrValueTooLarge returned when a value exceeds the field size limit
+	ErrValueTooLarge = errors.New("rsn: session value too large")
+	// ErrSessionTooLarge returned when a write would make the session exceed its size limit
+	ErrSessionTooLarge = errors.New("rsn: session too large")
+	// ErrTooManyFields returned when a write would make the session exceed its field count limit
+	ErrTooManyFields = errors.New("rsn: too many session fields")
+)
+
+const fieldsPrefixKey = "fields-sessions:"
+
+// getFieldsKey return the key of the sorted set scoring the fields of session id by write time
+func (p *provider) getFieldsKey(id string) string {
+	return fieldsPrefixKey + p.keyPrefix + id
+}
+
+// reservedList return the names of the internal and reserved fields joined by commas, as passed to writeScript
+func (p *provider) reservedList() string {
+	names := make([]string, 0, len(reservedNames)+len(p.reservedFields))
+	for name := range reservedNames {
+		names = append(names, p.field(name))
+	}
+	for name := range p.reservedFields {
+		names = append(names, name)
+	}
+	return strings.Join(names, ",")
+}
+
+// writeScript set fields of a session hash after checking the payload and field count
+// limits, and keep the value sets of indexed fields in step.
+//
+// KEYS are the session hash and the sorted set of field write times used for eviction.
+// ARGV holds the session id, the field size, total size and field count limits, whether
+// to evict instead of refusing, the reserved names joined by commas and the current time,
+// then a field, value and index key prefix triple per write, the prefix being empty for
+// fields which aren't indexed.
+var writeScript = newScript(`
+local maxField = tonumber(ARGV[2])
+local maxTotal = tonumber(ARGV[3])
+local maxFields = tonumber(ARGV[4])
+local writes = {}
+local writeCount = 0
+for i = 8, #ARGV, 3 do
+	if maxField > 0 and #ARGV[i + 1] > maxField then
+		return -1
+	end
+	if writes[ARGV[i]] == nil then
+		writeCount = writeCount + 1
+	end
+	writes[ARGV[i]] = ARGV[i + 1]
+end
+local evicted = {}
+if maxFields > 0 then
+	local reserved = {}
+	for name in string.gmatch(ARGV[6], '[^,]+') do
+		reserved[name] = true
+	end
+	local kept = {}
+	for _, name in ipairs(redis.call('HKEYS', KEYS[1])) do
+		if not reserved[name] and writes[name] == nil then
+			table.insert(kept, {name, tonumber(redis.call('ZSCORE', KEYS[2], name)) or 0})
+		end
+	end
+	local excess = #kept + writeCount - maxFields
+	if excess > 0 then
+		if ARGV[5] ~= '1' or writeCount > maxFields then
+			return -3
+		end
+		table.sort(kept, function(a, b) return a[2] < b[2] end)
+		for i = 1, excess do
+			evicted[kept[i][1]] = true
+		end
+	end
+end
+if maxTotal > 0 then
+	local total = 0
+	local fields = redis.call('HGETALL', KEYS[1])
+	for i = 1, #fields, 2 do
+		if writes[fields[i]] == nil and not evicted[fields[i]] then
+			total = total + #fields[i] + #fields[i + 1]
+		end
+	end
+	for name, value in pairs(writes) do
+		total = total + #name + #value
+	end
+	if total > maxTotal then
+		return -2
+	end
+end
+for name in pairs(evicted) do
+	redis.call('HDEL', KEYS[1], name)
+	redis.call('ZREM', KEYS[2], name)
+end
+for i = 8, #ARGV, 3 do
+	local old = redis.call('HGET', KEYS[1], ARGV[i])
+	redis.call('HSET', KEYS[1], ARGV[i], ARGV[i + 1])
+	if ARGV[i + 2] ~= '' then
+		if old then
+			redis.call('SREM', ARGV[i + 2] .. old, ARGV[1])
+		end
+		redis.call('SADD', ARGV[i + 2] .. ARGV[i + 1], ARGV[1])
+	end
+	if ARGV[5] == '1' then
+		redis.call('ZADD', KEYS[2], ARGV[7], ARGV[i])
+	end
+end
+if ARGV[5] == '1' then
+	local ttl = redis.call('PTTL', KEYS[1])
+	if ttl > 0 then
+		redis.call('PEXPIRE', KEYS[2], ttl)
+	end
+end
+return 1
+`)
+
+// replaceScript clear every field of a session hash but the reserved ones and set fields instead,
+// as clearScript then writeScript do, after checking the limits against the session as it will be,
+// so a refused write leaves the session untouched. The deleted names are returned.
+//
+// KEYS are the session hash and the sorted set of field write times used for eviction.
+// ARGV holds the session id, the field size, total size and field count limits, whether
+// field write times are kept, the reserved names joined by commas, the current time and the
+// number of indexed fields, then a field and index key prefix pair per indexed field, then
+// a field, value and index key prefix triple per write.
+var replaceScript = newScript(`
+local maxField = tonumber(ARGV[2])
+local maxTotal = tonumber(ARGV[3])
+local maxFields = tonumber(ARGV[4])
+local first = 9 + tonumber(ARGV[8]) * 2
+local writes = {}
+local writeCount = 0
+for i = first, #ARGV, 3 do
+	if maxField > 0 and #ARGV[i + 1] > maxField then
+		return -1
+	end
+	if writes[ARGV[i]] == nil then
+		writeCount = writeCount + 1
+	end
+	writes[ARGV[i]] = ARGV[i + 1]
+end
+if maxFields > 0 and writeCount > maxFields then
+	return -3
+end
+local reserved = {}
+for name in string.gmatch(ARGV[6], '[^,]+') do
+	reserved[name] = true
+end
+if maxTotal > 0 then
+	local total = 0
+	local fields = redis.call('HGETALL', KEYS[1])
+	for i = 1, #fields, 2 do
+		if reserved[fields[i]] and writes[fields[i]] == nil then
+			total = total + #fields[i] + #fields[i + 1]
+		end
+	end
+	for name, value in pairs(writes) do
+		total = total + #name + #value
+	end
+	if total > maxTotal then
+		return -2
+	end
+end
+for i = 9, first - 1, 2 do
+	local old = redis.call('HGET', KEYS[1], ARGV[i])
+	if old then
+		redis.call('SREM', ARGV[i + 1] .. old, ARGV[1])
+	end
+end
+local cleared = {}
+for _, name in ipairs(redis.call('HKEYS', KEYS[1])) do
+	if not reserved[name] then
+		redis.call('HDEL', KEYS[1], name)
+		table.insert(cleared, name)
+	end
+end
+redis.call('DEL', KEYS[2])
+for i = first, #ARGV, 3 do
+	redis.call('HSET', KEYS[1], ARGV[i], ARGV[i + 1])
+	if ARGV[i + 2] ~= '' then
+		redis.call('SADD', ARGV[i + 2] .. ARGV[i + 1], ARGV[1])
+	end
+	if ARGV[5] == '1' then
+		redis.call('ZADD', KEYS[2], ARGV[7], ARGV[i])
+	end
+end
+if ARGV[5] == '1' then
+	local ttl = redis.call('PTTL', KEYS[1])
+	if ttl > 0 then
+		redis.call('PEXPIRE', KEYS[2], ttl)
+	end
+end
+return cleared
+`)
+
+// SetValue set named val into session, returning ErrReservedField for internal fields
+// and ErrValueTooLarge, ErrSessionTooLarge or ErrTooManyFields when a limit refuses it
+func (s *session) SetValue(name string, val interface{}) error {
+	if s.provider.reserved(name) {
+		return ErrReservedField
+	}
+	return s.SetValues(map[string]interface{}{name: val}, false)
+}
+
+// SetValues set data into session, clearing its values first if flush, in the same step.
+// Internal fields in data are skipped, data is left untouched, and nothing is written when a limit refuses it.
+func (s *session) SetValues(data map[string]interface{}, flush bool) error {
+	if s.invalidated {
+		return ErrSessionNotFound
+	}
+	values := make(map[string]interface{}, len(data))
+	for name, val := range data {
+		if !s.provider.reserved(name) {
+			values[name] = val
+		}
+	}
+	if flush {
+		if !s.provider.coalesced() {
+			return s.replace(values)
+		}
+		s.Clear()
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	if err := s.write(values); err != nil {
+		return err
+	}
+	s.provider.valuesSet(s, values)
+	return nil
+}
+
+// replace clear the values of s and store values, which hold no internal field, in one step,
+// leaving s untouched when a limit refuses values
+func (s *session) replace(values map[string]interface{}) error {
+	p := s.provider
+	p.writeBehind.forget(s.key)
+	s.wrote()
+	evict := 0
+	if p.maxFields > 0 && p.evictFields {
+		evict = 1
+	}
+	args := make([]interface{}, 0, 8+len(p.indexes)*2+len(values)*3)
+	args = append(args, s.id, p.maxFieldSize, p.maxSessionSize, p.maxFields, evict, p.reservedList(), formatTime(p.now()), len(p.indexes))
+	for field := range p.indexes {
+		args = append(args, field, p.getIndexKeyPrefix(field))
+	}
+	for name, val := range values {
+		indexPrefix := ""
+		if p.indexed(name) {
+			indexPrefix = p.getIndexKeyPrefix(name)
+		}
+		args = append(args, name, val, indexPrefix)
+	}
+	replaced, err := replaceScript.Run(s.client, []string{s.key, p.getFieldsKey(s.id)}, args...).Result()
+	if err != nil {
+		return wrapErr(err)
+	}
+	if n, refused := replaced.(int64); refused {
+		return writeRefused(n)
+	}
+	p.valuesDel(s, clearedNames(replaced)...)
+	if len(values) > 0 {
+		p.valuesSet(s, values)
+	}
+	return nil
+}
+
+// write store values, which hold no internal field, queueing them with WithWriteBehind
+func (s *session) write(values map[string]interface{}) error {
+	if s.provider.coalesced() {
+		s.wrote()
+		s.provider.writeBehind.set(s.client, s.key, values)
+		return nil
+	}
+	return s.writeNow(values)
+}
+
+// writeNow store values, which hold no internal field, through writeScript when a limit or an index applies
+func (s *session) writeNow(values map[string]interface{}) error {
+	s.wrote()
+	if !s.provider.checkedWrites() {
+		return wrapErr(s.client.HMSet(s.key, values).Err())
+	}
+	written, err := writeScript.Run(s.client, []string{s.key, s.provider.getFieldsKey(s.id)}, s.writeArgs(values)...).Int64()
+	if err != nil {
+		return wrapErr(err)
+	}
+	return writeRefused(written)
+}
+
+// checkedWrites report whether writes go through writeScript, checking a limit or keeping an index
+func (p *provider) checkedWrites() bool {
+	return p.maxFieldSize > 0 || p.maxSessionSize > 0 || p.maxFields > 0 || len(p.indexes) > 0
+}
+
+// writeArgs return the ARGV of writeScript storing values into s
+func (s *session) writeArgs(values map[string]interface{}) []interface{} {
+	p := s.provider
+	evict, reservedNames := 0, ""
+	if p.maxFields > 0 {
+		reservedNames = p.reservedList()
+		if p.evictFields {
+			evict = 1
+		}
+	}
+	args := make([]interface{}, 0, 7+len(values)*3)
+	args = append(args, s.id, p.maxFieldSize, p.maxSessionSize, p.maxFields, evict, reservedNames, formatTime(p.now()))
+	for name, val := range values {
+		indexPrefix := ""
+		if p.indexed(name) {
+			indexPrefix = p.getIndexKeyPrefix(name)
+		}
+		args = append(args, name, val, indexPrefix)
+	}
+	return args
+}
+
+// writeRefused return the error of the writeScript result written, nil when the write was done
+func writeRefused(written int64) error {
+	switch written {
+	case -1:
+		return ErrValueTooLarge
+	case -2:
+		return ErrSessionTooLarge
+	case -3:
+		return ErrTooManyFields
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	s "github.com/go-the-way/anoweb/session"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionSetValue(t *testing.T) {
+	p := ProviderWithPrefixKey(redisOptions, "_write_:")
+	currSession := p.New(&s.Config{Valid: time.Minute}, nil).(Session)
+	require.Equal(t, ErrReservedField, currSession.SetValue(p.field(sessionIdName), "other"))
+	require.Nil(t, currSession.SetValue("apple", "100"))
+	require.Nil(t, currSession.SetValues(map[string]interface{}{p.field(sessionIdName): "other", "banana": "200"}, false))
+	require.Equal(t, currSession.Id(), currSession.Get(p.field(sessionIdName)))
+	require.Equal(t, "200", currSession.Get("banana"))
+	require.Nil(t, currSession.SetValues(map[string]interface{}{}, false))
+	p.Del(currSession.Id())
+}
+
+func TestSessionSetValuesFlush(t *testing.T) {
+	p := ProviderWithOptions(redisOptions, WithPrefixKey("_write_:"), WithIndex("role"))
+	currSession := p.New(&s.Config{Valid: time.Minute}, nil).(Session)
+	defer p.Del(currSession.Id())
+	require.NoError(t, currSession.SetValues(map[string]interface{}{"apple": "100", "role": "admin"}, false))
+
+	data := map[string]interface{}{p.field(sessionIdName): "other", "banana": 200}
+	require.NoError(t, currSession.SetValues(data, true))
+	require.Equal(t, map[string]interface{}{p.field(sessionIdName): "other", "banana": 200}, data)
+	require.Nil(t, currSession.Get("apple"))
+	require.Equal(t, "200", currSession.Get("banana"))
+	require.Equal(t, currSession.Id(), currSession.Get(p.field(sessionIdName)))
+	found, err := p.Find(context.Background(), "role", "admin")
+	require.NoError(t, err)
+	require.Empty(t, found)
+
+	require.NotPanics(t, func() { currSession.SetAll(map[string]interface{}{}, true) })
+	require.Nil(t, currSession.Get("banana"))
+	require.Equal(t, currSession.Id(), currSession.Get(p.field(sessionIdName)))
+}
+
+func TestSessionPayloadLimit(t *testing.T) {
+	p := ProviderWithOptions(redisOptions, WithPrefixKey("_write_:"), WithPayloadLimit(10, 200))
+	currSession := p.New(&s.Config{Valid: time.Minute}, nil).(Session)
+	require.Nil(t, currSession.SetValue("apple", strings.Repeat("a", 10)))
+	require.Equal(t, ErrValueTooLarge, currSession.SetValue("apple", strings.Repeat("a", 11)))
+	require.Equal(t, strings.Repeat("a", 10), currSession.Get("apple"))
+
+	data := map[string]interface{}{}
+	for _, name := range []string{"a1", "a2", "a3", "a4", "a5", "a6", "a7", "a8", "a9", "a10", "a11", "a12"} {
+		data[name] = strings.Repeat("b", 10)
+	}
+	require.Equal(t, ErrSessionTooLarge, currSession.SetValues(data, false))
+	require.Nil(t, currSession.Get("a1"))
+	require.Nil(t, currSession.SetValue("apple", "1"))
+	p.Del(currSession.Id())
+}
+
+func TestSessionRefusedFlush(t *testing.T) {
+	p := ProviderWithOptions(redisOptions, WithPrefixKey("_write_:"), WithPayloadLimit(10, 0), WithMaxFields(2, false), WithIndex("role"))
+	currSession := p.New(&s.Config{Valid: time.Minute}, nil).(Session)
+	defer p.Del(currSession.Id())
+	// room for the internal fields, apple and role
+	p.maxSessionSize = len("apple100roleadmin")
+	for name, val := range p.client.HGetAll(p.getRedisKey(currSession.Id())).Val() {
+		p.maxSessionSize += len(name) + len(val)
+	}
+	require.NoError(t, currSession.SetValues(map[string]interface{}{"apple": "100", "role": "admin"}, false))
+
+	require.Equal(t, ErrValueTooLarge, currSession.SetValues(map[string]interface{}{"apple": strings.Repeat("a", 11)}, true))
+	require.Equal(t, ErrTooManyFields, currSession.SetValues(map[string]interface{}{"a": "1", "b": "2", "c": "3"}, true))
+	require.Equal(t, ErrSessionTooLarge, currSession.SetValues(map[string]interface{}{"a": strings.Repeat("b", 10), "b": strings.Repeat("b", 10)}, true))
+	require.Equal(t, "100", currSession.Get("apple"))
+	require.Equal(t, "admin", currSession.Get("role"))
+	found, err := p.Find(context.Background(), "role", "admin")
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+
+	require.NoError(t, currSession.SetValues(map[string]interface{}{"cherry": "300"}, true))
+	require.Nil(t, currSession.Get("apple"))
+	require.Equal(t, "300", currSession.Get("cherry"))
+}
+
+func TestSessionMaxFields(t *testing.T) {
+	p := ProviderWithOptions(redisOptions, WithPrefixKey("_write_:"), WithMaxFields(2, false))
+	currSession := p.New(&s.Config{Valid: time.Minute}, nil).(Session)
+	require.Nil(t, currSession.SetValue("apple", "100"))
+	require.Nil(t, currSession.SetValue("banana", "200"))
+	require.Equal(t, ErrTooManyFields, currSession.SetValue("cherry", "300"))
+	require.Nil(t, currSession.SetValue("apple", "101"))
+	require.Nil(t, currSession.Get("cherry"))
+	p.Del(currSession.Id())
+
+	p = ProviderWithOptions(redisOptions, WithPrefixKey("_write_:"), WithMaxFields(2, true))
+	currSession = p.New(&s.Config{Valid: time.Minute}, nil).(Session)
+	require.Nil(t, currSession.SetValue("apple", "100"))
+	time.Sleep(time.Millisecond * 5)
+	require.Nil(t, currSession.SetValue("banana", "200"))
+	time.Sleep(time.Millisecond * 5)
+	require.Nil(t, currSession.SetValue("apple", "101"))
+	require.Nil(t, currSession.SetValue("cherry", "300"))
+	require.Nil(t, currSession.Get("banana"))
+	require.Equal(t, "101", currSession.Get("apple"))
+	require.Equal(t, "300", currSession.Get("cherry"))
+	require.Equal(t, ErrTooManyFields, currSession.SetValues(map[string]interface{}{"a": "1", "b": "2", "c": "3"}, false))
+	p.Del(currSession.Id())
+}
@@ -0,0 +1,212 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"sync"
+	"time"
+
+	r "github.com/go-redis/redis"
+)
+
+// writeBehindScript apply the coalesced writes of a session hash unless it is gone,
+// so a late flush never brings an expired or deleted session back without ttl.
+//
+// KEYS is the session hash. ARGV holds the count of fields to set, then a field and
+// value pair per set, then the fields to delete.
+var writeBehindScript = newScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return 0
+end
+local sets = tonumber(ARGV[1])
+for i = 2, sets * 2, 2 do
+	redis.call('HSET', KEYS[1], ARGV[i], ARGV[i + 1])
+end
+for i = sets * 2 + 2, #ARGV do
+	redis.call('HDEL', KEYS[1], ARGV[i])
+end
+return 1
+`)
+
+// pendingWrites the writes of a session hash waiting for the next flush, the last write of a field winning
+type pendingWrites struct {
+	client *r.Client
+	set    map[string]interface{}
+	del    map[string]struct{}
+}
+
+// writeQueue coalesce the writes of WithWriteBehind, flushed in one pipeline every interval,
+// or at once by the write finding max fields pending
+type writeQueue struct {
+	mu       sync.Mutex
+	flushMu  sync.Mutex
+	pending  map[string]*pendingWrites
+	count    int
+	max      int
+	interval time.Duration
+	report   func(err error)
+}
+
+func newWriteQueue(interval time.Duration, max int) *writeQueue {
+	return &writeQueue{pending: map[string]*pendingWrites{}, max: max, interval: interval}
+}
+
+// entry return the pending writes of key, added when missing. q.mu must be held.
+func (q *writeQueue) entry(client *r.Client, key string) *pendingWrites {
+	writes, have := q.pending[key]
+	if !have {
+		writes = &pendingWrites{client: client, set: map[string]interface{}{}, del: map[string]struct{}{}}
+		q.pending[key] = writes
+	}
+	return writes
+}
+
+// set queue values for key
+func (q *writeQueue) set(client *r.Client, key string, values map[string]interface{}) {
+	q.mu.Lock()
+	writes := q.entry(client, key)
+	for name, val := range values {
+		delete(writes.del, name)
+		writes.set[name] = val
+		q.count++
+	}
+	full := q.max > 0 && q.count >= q.max
+	q.mu.Unlock()
+	if full {
+		q.flush()
+	}
+}
+
+// del queue the deletion of names from key
+func (q *writeQueue) del(client *r.Client, key string, names ...string) {
+	q.mu.Lock()
+	writes := q.entry(client, key)
+	for _, name := range names {
+		delete(writes.set, name)
+		writes.del[name] = struct{}{}
+		q.count++
+	}
+	full := q.max > 0 && q.count >= q.max
+	q.mu.Unlock()
+	if full {
+		q.flush()
+	}
+}
+
+// forget drop the pending writes of names from key, or of every field when names is empty,
+// as they would be overwritten anyway
+func (q *writeQueue) forget(key string, names ...string) {
+	if q == nil {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	writes, have := q.pending[key]
+	if !have {
+		return
+	}
+	if len(names) == 0 {
+		delete(q.pending, key)
+		return
+	}
+	for _, name := range names {
+		delete(writes.set, name)
+		delete(writes.del, name)
+	}
+}
+
+// settle flush the queue when key has pending writes, so reads see them
+func (q *writeQueue) settle(key string) {
+	if q == nil {
+		return
+	}
+	q.mu.Lock()
+	_, have := q.pending[key]
+	q.mu.Unlock()
+	if have {
+		q.flush()
+	}
+}
+
+// flush apply the pending writes, one pipeline per client
+func (q *writeQueue) flush() {
+	q.flushMu.Lock()
+	defer q.flushMu.Unlock()
+	q.mu.Lock()
+	pending := q.pending
+	q.pending = map[string]*pendingWrites{}
+	q.count = 0
+	q.mu.Unlock()
+	byClient := map[*r.Client]map[string]*pendingWrites{}
+	for key, writes := range pending {
+		if len(writes.set) == 0 && len(writes.del) == 0 {
+			continue
+		}
+		if byClient[writes.client] == nil {
+			byClient[writes.client] = map[string]*pendingWrites{}
+		}
+		byClient[writes.client][key] = writes
+	}
+	for client, keys := range byClient {
+		_, err := client.Pipelined(func(pipe r.Pipeliner) error {
+			for key, writes := range keys {
+				args := make([]interface{}, 0, 1+len(writes.set)*2+len(writes.del))
+				args = append(args, len(writes.set))
+				for name, val := range writes.set {
+					args = append(args, name, val)
+				}
+				for name := range writes.del {
+					args = append(args, name)
+				}
+				writeBehindScript.Eval(pipe, []string{key}, args...)
+			}
+			return nil
+		})
+		if err != nil && q.report != nil {
+			q.report(err)
+		}
+	}
+}
+
+// run flush the queue every interval, for ever
+func (q *writeQueue) run() {
+	for {
+		time.Sleep(q.interval)
+		q.flush()
+	}
+}
+
+// coalesced report whether the values written and deleted go through the queue of WithWriteBehind,
+// which is bypassed when a size limit or an index has to check them in redis
+func (p *provider) coalesced() bool {
+	return p.writeBehind != nil && !p.checkedWrites()
+}
+
+// hdel delete the fields names of s, which aren't indexed, queueing the deletion with WithWriteBehind
+func (s *session) hdel(names ...string) error {
+	s.wrote()
+	if s.provider.coalesced() {
+		s.provider.writeBehind.del(s.client, s.key, names...)
+		return nil
+	}
+	return wrapErr(s.client.HDel(s.key, names...).Err())
+}
+
+// SyncSet set named val at once, bypassing the queue of WithWriteBehind, for fields which must
+// be durable when it returns. A write of name still queued is dropped. With WithWaitReplicas
+// it waits for the replicas as SetDurable does.
+func (s *session) SyncSet(name string, val interface{}) error {
+	if d := s.provider.durability; d != nil {
+		return s.SetDurable(name, val, d.replicas, d.timeout)
+	}
+	return s.SetDurable(name, val, 0, 0)
+}
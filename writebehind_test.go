@@ -0,0 +1,69 @@
+// Copyright 2022 rsn Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsn
+
+import (
+	"testing"
+	"time"
+
+	s "github.com/go-the-way/anoweb/session"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionWriteBehind(t *testing.T) {
+	p := ProviderWithOptions(redisOptions, WithPrefixKey("_write_behind_:"), WithWriteBehind(time.Hour, 0))
+	currSession := p.New(&s.Config{Valid: time.Minute}, nil).(*session)
+	currSession.Set("apple", "100")
+	currSession.Set("apple", "200")
+	currSession.Set("pear", "300")
+	require.False(t, p.client.HExists(currSession.key, "apple").Val())
+
+	// reads flush the writes queued
+	require.Equal(t, "200", currSession.Get("apple"))
+	require.Equal(t, "300", p.client.HGet(currSession.key, "pear").Val())
+
+	currSession.Del("pear")
+	require.True(t, p.client.HExists(currSession.key, "pear").Val())
+	require.NoError(t, currSession.SyncSet("banana", "400"))
+	require.Equal(t, "400", p.client.HGet(currSession.key, "banana").Val())
+	p.writeBehind.flush()
+	require.False(t, p.client.HExists(currSession.key, "pear").Val())
+
+	// a late flush doesn't bring a deleted session back
+	currSession.Set("apple", "500")
+	p.client.Del(currSession.key)
+	p.writeBehind.flush()
+	require.Zero(t, p.client.Exists(currSession.key).Val())
+	p.Del(currSession.Id())
+}
+
+func TestSessionWriteBehindMaxPending(t *testing.T) {
+	p := ProviderWithOptions(redisOptions, WithPrefixKey("_write_behind_:"), WithWriteBehind(time.Hour, 2))
+	currSession := p.New(&s.Config{Valid: time.Minute}, nil).(*session)
+	currSession.Set("apple", "100")
+	require.False(t, p.client.HExists(currSession.key, "apple").Val())
+	currSession.Set("pear", "200")
+	require.Equal(t, "100", p.client.HGet(currSession.key, "apple").Val())
+	require.Equal(t, "200", p.client.HGet(currSession.key, "pear").Val())
+	p.Del(currSession.Id())
+}
+
+func TestSessionWriteBehindInterval(t *testing.T) {
+	p := ProviderWithOptions(redisOptions, WithPrefixKey("_write_behind_:"), WithWriteBehind(time.Millisecond*20, 0))
+	currSession := p.New(&s.Config{Valid: time.Minute}, nil).(*session)
+	currSession.Set("apple", "100")
+	require.Eventually(t, func() bool {
+		return p.client.HGet(currSession.key, "apple").Val() == "100"
+	}, time.Second, time.Millisecond*10)
+	p.Del(currSession.Id())
+}